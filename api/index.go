@@ -2,11 +2,18 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/email"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/geoip"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/handler"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/repository/sqlite"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/webhook"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/config"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/services"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+
+	_ "time/tzdata" // Stats tz= must work on images without zoneinfo (alpine, Vercel)
 )
 
 var mux http.Handler
@@ -21,9 +28,36 @@ func init() {
 		panic(err)
 	}
 
-	service := services.NewLinkService(repo)
-	collectionService := services.NewCollectionService(repo)
-	mux = handler.NewRouter(cfg, service, collectionService)
+	// Initialize webhooks. Events are queued here; there is no background worker on
+	// Vercel, so deliveries are sent by `cli deliver-webhooks` from a scheduled job.
+	webhookService := services.NewWebhookService(repo, webhook.NewHTTPSender(cfg.WebhookAllowPrivate))
+
+	linkOpts := []services.LinkServiceOption{
+		services.WithEventPublisher(webhookService),
+		services.WithTrackedParams(cfg.TrackedParams),
+		services.WithDedupWindow(time.Duration(cfg.VisitDedupSeconds) * time.Second),
+		services.WithClickIDs(cfg.ClickIDParam, cfg.JWTSecret),
+	}
+	if cfg.GeoIPDBPath != "" {
+		geo, err := geoip.NewMaxMindResolver(cfg.GeoIPDBPath)
+		if err != nil {
+			panic(err)
+		}
+		linkOpts = append(linkOpts, services.WithGeoResolver(geo))
+	}
+
+	// No visit writer here: serverless instances may freeze right after the response,
+	// so visits are written synchronously within the request
+	service := services.NewLinkService(repo, linkOpts...)
+	collectionService := services.NewCollectionService(repo, services.WithCollectionEvents(webhookService), services.WithCollectionLinks(service))
+
+	// Alert rules are managed here and evaluated by `cli evaluate-alerts` from a scheduled job
+	var mailer ports.Mailer
+	if cfg.SMTPHost != "" {
+		mailer = email.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	alertService := services.NewAlertService(repo, webhookService, mailer)
+	mux = handler.NewRouter(cfg, service, collectionService, webhookService, alertService)
 }
 
 // Handler is the entrypoint for Vercel
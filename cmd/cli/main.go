@@ -8,18 +8,29 @@ import (
 	"log"
 	"os"
 
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/archive"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/email"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/repository/sqlite"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/webhook"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/config"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/services"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
 )
 
 func main() {
 	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
 	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
 	importFile := importCmd.String("file", "", "JSON file to import")
+	compactCmd := flag.NewFlagSet("compact", flag.ExitOnError)
+	pruneCmd := flag.NewFlagSet("prune", flag.ExitOnError)
+	pruneDays := pruneCmd.Int("days", 0, "Delete raw visits older than this many days (default VISIT_RETENTION_DAYS)")
+	pruneArchive := pruneCmd.String("archive-dir", "", "Archive pruned visits here (default VISIT_ARCHIVE_DIR)")
+	deliverCmd := flag.NewFlagSet("deliver-webhooks", flag.ExitOnError)
+	alertsCmd := flag.NewFlagSet("evaluate-alerts", flag.ExitOnError)
 
 	if len(os.Args) < 2 {
-		fmt.Println("expected 'export' or 'import' subcommands")
+		fmt.Println("expected 'export', 'import', 'compact', 'prune', 'deliver-webhooks' or 'evaluate-alerts' subcommands")
 		os.Exit(1)
 	}
 
@@ -40,8 +51,31 @@ func main() {
 			os.Exit(1)
 		}
 		doImport(repo, *importFile)
+	case "compact":
+		compactCmd.Parse(os.Args[2:])
+		doCompact(repo)
+	case "prune":
+		pruneCmd.Parse(os.Args[2:])
+		days, archiveDir := cfg.VisitRetentionDays, cfg.VisitArchiveDir
+		if *pruneDays > 0 {
+			days = *pruneDays
+		}
+		if *pruneArchive != "" {
+			archiveDir = *pruneArchive
+		}
+		if days <= 0 {
+			pruneCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		doPrune(repo, days, archiveDir)
+	case "deliver-webhooks":
+		deliverCmd.Parse(os.Args[2:])
+		doDeliverWebhooks(repo, cfg)
+	case "evaluate-alerts":
+		alertsCmd.Parse(os.Args[2:])
+		doEvaluateAlerts(repo, cfg)
 	default:
-		fmt.Println("expected 'export' or 'import' subcommands")
+		fmt.Println("expected 'export', 'import', 'compact', 'prune', 'deliver-webhooks' or 'evaluate-alerts' subcommands")
 		os.Exit(1)
 	}
 }
@@ -107,3 +141,64 @@ func doImport(repo *sqlite.SQLiteRepository, filename string) {
 	}
 	log.Printf("Imported %d links", count)
 }
+
+// doCompact rolls up visits into the stats rollup tables (for deployments without the server's background job, e.g. Vercel cron)
+func doCompact(repo *sqlite.SQLiteRepository) {
+	days, err := services.NewLinkService(repo).CompactStats(context.Background())
+	if err != nil {
+		log.Fatalf("Compaction failed: %v", err)
+	}
+	log.Printf("Compacted %d days of visits", days)
+}
+
+// doPrune deletes raw visits older than days, archiving them to archiveDir first if set
+func doPrune(repo *sqlite.SQLiteRepository, days int, archiveDir string) {
+	var visitArchive ports.VisitArchive
+	if archiveDir != "" {
+		a, err := archive.NewNDJSONArchive(archiveDir)
+		if err != nil {
+			log.Fatalf("Failed to open archive: %v", err)
+		}
+		visitArchive = a
+	}
+
+	pruned, err := services.NewLinkService(repo, services.WithRetention(days, visitArchive)).PruneVisits(context.Background())
+	if err != nil {
+		log.Fatalf("Prune failed: %v", err)
+	}
+	log.Printf("Pruned %d visits", pruned)
+}
+
+// doDeliverWebhooks sends due webhook deliveries and prunes finished ones past
+// WEBHOOK_RETENTION_DAYS (for deployments without the server's background jobs, e.g. Vercel cron)
+func doDeliverWebhooks(repo *sqlite.SQLiteRepository, cfg *config.Config) {
+	webhookService := services.NewWebhookService(repo, webhook.NewHTTPSender(cfg.WebhookAllowPrivate),
+		services.WithDeliveryRetention(cfg.WebhookRetentionDays))
+	attempted, err := webhookService.DeliverPending(context.Background())
+	if err != nil {
+		log.Fatalf("Webhook delivery failed: %v", err)
+	}
+	log.Printf("Attempted %d webhook deliveries", attempted)
+
+	pruned, err := webhookService.PruneDeliveries(context.Background())
+	if err != nil {
+		log.Fatalf("Webhook delivery pruning failed: %v", err)
+	}
+	log.Printf("Pruned %d webhook deliveries", pruned)
+}
+
+// doEvaluateAlerts checks alert rules and sends notifications (for deployments without the server's background job, e.g. Vercel cron).
+// Webhook alerts are queued; run deliver-webhooks afterwards to send them.
+func doEvaluateAlerts(repo *sqlite.SQLiteRepository, cfg *config.Config) {
+	var mailer ports.Mailer
+	if cfg.SMTPHost != "" {
+		mailer = email.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	webhookService := services.NewWebhookService(repo, webhook.NewHTTPSender(cfg.WebhookAllowPrivate))
+
+	triggered, err := services.NewAlertService(repo, webhookService, mailer).EvaluateAlerts(context.Background())
+	if err != nil {
+		log.Fatalf("Alert evaluation failed: %v", err)
+	}
+	log.Printf("Triggered %d alerts", triggered)
+}
@@ -26,7 +26,7 @@ func TestIntegration(t *testing.T) {
 	service := services.NewLinkService(repo)
 
 	// 3. Setup Handler
-	h := handler.NewHTTPHandler(service)
+	h := handler.NewHTTPHandler(service, nil)
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /api/v1/links", h.Create)
 	mux.HandleFunc("GET /api/v1/links", h.List)
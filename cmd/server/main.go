@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/archive"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/email"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/geoip"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/handler"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/repository/sqlite"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/adapters/webhook"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/config"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/services"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+
+	_ "time/tzdata" // Stats tz= must work on images without zoneinfo (alpine, Vercel)
 )
 
 func main() {
@@ -20,12 +32,98 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Initialize webhooks; services emit events, delivery happens in the background. Click
+	// events are queued like visits so redirects don't wait on delivery inserts.
+	webhookService := services.NewWebhookService(repo, webhook.NewHTTPSender(cfg.WebhookAllowPrivate),
+		services.WithClickQueue(cfg.VisitQueueSize, cfg.VisitFlushInterval),
+		services.WithDeliveryRetention(cfg.WebhookRetentionDays))
+
+	linkOpts := []services.LinkServiceOption{
+		services.WithEventPublisher(webhookService),
+		services.WithTrackedParams(cfg.TrackedParams),
+		services.WithDedupWindow(time.Duration(cfg.VisitDedupSeconds) * time.Second),
+		services.WithClickIDs(cfg.ClickIDParam, cfg.JWTSecret),
+	}
+
+	// Initialize GeoIP (optional)
+	if cfg.GeoIPDBPath != "" {
+		geo, err := geoip.NewMaxMindResolver(cfg.GeoIPDBPath)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		defer geo.Close()
+		linkOpts = append(linkOpts, services.WithGeoResolver(geo))
+	}
+
+	// Initialize visit retention (optional)
+	if cfg.VisitRetentionDays > 0 {
+		var visitArchive ports.VisitArchive
+		if cfg.VisitArchiveDir != "" {
+			a, err := archive.NewNDJSONArchive(cfg.VisitArchiveDir)
+			if err != nil {
+				log.Fatalf("Failed to open visit archive: %v", err)
+			}
+			visitArchive = a
+		}
+		linkOpts = append(linkOpts, services.WithRetention(cfg.VisitRetentionDays, visitArchive))
+	}
+
+	// Initialize buffered visit writer (optional)
+	var visitWriter *services.VisitWriter
+	if cfg.VisitQueueSize > 0 {
+		visitWriter = services.NewVisitWriter(repo, cfg.VisitQueueSize, cfg.VisitBatchSize, cfg.VisitFlushInterval)
+		linkOpts = append(linkOpts, services.WithVisitWriter(visitWriter))
+	}
+
+	// Initialize live visit stream
+	broker := services.NewVisitBroker(1000)
+	linkOpts = append(linkOpts, services.WithVisitBroker(broker))
+
 	// Initialize Service
-	service := services.NewLinkService(repo)
-	collectionService := services.NewCollectionService(repo)
+	service := services.NewLinkService(repo, linkOpts...)
+	collectionService := services.NewCollectionService(repo, services.WithCollectionEvents(webhookService), services.WithCollectionLinks(service))
+
+	// Initialize alerts; email is optional, webhook alerts go through the webhook queue
+	var mailer ports.Mailer
+	if cfg.SMTPHost != "" {
+		mailer = email.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	alertService := services.NewAlertService(repo, webhookService, mailer)
+
+	// Background jobs stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.RollupInterval > 0 {
+		go runEvery(ctx, "Stats compaction", cfg.RollupInterval, func(ctx context.Context) error {
+			_, err := service.CompactStats(ctx)
+			return err
+		})
+	}
+	go runEvery(ctx, "Webhook delivery", 5*time.Second, func(ctx context.Context) error {
+		_, err := webhookService.DeliverPending(ctx)
+		return err
+	})
+	if cfg.WebhookRetentionDays > 0 {
+		go runEvery(ctx, "Webhook delivery pruning", time.Hour, func(ctx context.Context) error {
+			_, err := webhookService.PruneDeliveries(ctx)
+			return err
+		})
+	}
+	if cfg.AlertInterval > 0 {
+		go runEvery(ctx, "Alert evaluation", cfg.AlertInterval, func(ctx context.Context) error {
+			_, err := alertService.EvaluateAlerts(ctx)
+			return err
+		})
+	}
+	if cfg.VisitRetentionDays > 0 {
+		go runEvery(ctx, "Visit pruning", time.Hour, func(ctx context.Context) error {
+			_, err := service.PruneVisits(ctx)
+			return err
+		})
+	}
 
 	// Initialize Router
-	mux := handler.NewRouter(cfg, service, collectionService)
+	mux := handler.NewRouter(cfg, service, collectionService, webhookService, alertService)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -33,9 +131,47 @@ func main() {
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
+	// Open SSE streams would otherwise hold up Shutdown until its timeout
+	server.RegisterOnShutdown(broker.Close)
+
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	// Stop taking requests first, then drain queued visits so none are lost on deploy
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	if visitWriter != nil {
+		if err := visitWriter.Close(shutdownCtx); err != nil {
+			log.Printf("Visit queue not fully flushed: %v", err)
+		}
+	}
+	if err := webhookService.Close(shutdownCtx); err != nil {
+		log.Printf("Webhook click queue not fully flushed: %v", err)
+	}
+}
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
+// runEvery runs job immediately and then every interval until ctx is done
+func runEvery(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := job(ctx); err != nil {
+			log.Printf("%s failed: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
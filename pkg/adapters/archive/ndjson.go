@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// NDJSONArchive writes visits as gzip-compressed NDJSON, one file per UTC day:
+// <dir>/visits-YYYY-MM-DD.ndjson.gz
+type NDJSONArchive struct {
+	dir string
+}
+
+func NewNDJSONArchive(dir string) (*NDJSONArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &NDJSONArchive{dir: dir}, nil
+}
+
+// WriteDay writes to a temporary file and renames it into place once fully synced,
+// so a crash never leaves a truncated archive behind. An existing file for the day is
+// replaced; days without visits produce no file.
+func (a *NDJSONArchive) WriteDay(ctx context.Context, day time.Time, fill func(write func(*domain.Visit) error) error) error {
+	name := filepath.Join(a.dir, "visits-"+day.UTC().Format("2006-01-02")+".ndjson.gz")
+
+	tmp, err := os.CreateTemp(a.dir, ".visits-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	buf := bufio.NewWriter(gz)
+	enc := json.NewEncoder(buf)
+
+	written := 0
+	err = fill(func(v *domain.Visit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		written++
+		return enc.Encode(v)
+	})
+	if err != nil {
+		return err
+	}
+	if written == 0 {
+		// Nothing to keep for days without visits
+		return nil
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Ensure interface compliance
+var _ ports.VisitArchive = (*NDJSONArchive)(nil)
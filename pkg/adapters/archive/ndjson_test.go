@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// fillWith writes the given visits, then returns err
+func fillWith(visits []*domain.Visit, err error) func(write func(*domain.Visit) error) error {
+	return func(write func(*domain.Visit) error) error {
+		for _, v := range visits {
+			if err := write(v); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func TestWriteDay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	a, err := NewNDJSONArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	name := filepath.Join(dir, "visits-2026-03-14.ndjson.gz")
+	visits := []*domain.Visit{{ID: 1, LinkID: 7, Referer: "https://a.example/"}, {ID: 2, LinkID: 7, Country: "TH"}}
+
+	if err := a.WriteDay(ctx, day, fillWith(visits, nil)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(gz)
+	for _, want := range visits {
+		var got domain.Visit
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != want.ID || got.Referer != want.Referer || got.Country != want.Country {
+			t.Errorf("archived %+v, want %+v", got, want)
+		}
+	}
+	if dec.More() {
+		t.Error("archive has extra lines")
+	}
+
+	// A failed rewrite keeps the existing file and leaves no temporary file behind
+	if err := a.WriteDay(ctx, day, fillWith(visits[:1], errors.New("read failed"))); err == nil {
+		t.Error("WriteDay succeeded despite the failing fill")
+	}
+	if info, err := os.Stat(name); err != nil || info.Size() == 0 {
+		t.Errorf("existing archive lost: %v", err)
+	}
+
+	// Days without visits produce no file
+	if err := a.WriteDay(ctx, day.AddDate(0, 0, 1), fillWith(nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("archive dir has %d entries, want only the written day", len(entries))
+	}
+}
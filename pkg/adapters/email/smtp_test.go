@@ -0,0 +1,144 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts one session and records the envelope and message
+type fakeSMTPServer struct {
+	listener net.Listener
+	from     string
+	rcpts    []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: l, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP fake")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpts = append(s.rcpts, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+
+	mailer := NewSMTPMailer(host, port, "", "", "alerts@example.com")
+	err := mailer.Send(context.Background(), []string{"a@example.com", "b@example.com"}, "Alert: /promo reached 1000 clicks", "line one\nline two\n")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-server.done
+
+	if server.from != "alerts@example.com" {
+		t.Errorf("MAIL FROM = %q", server.from)
+	}
+	if strings.Join(server.rcpts, ",") != "a@example.com,b@example.com" {
+		t.Errorf("RCPT TO = %v", server.rcpts)
+	}
+	for _, want := range []string{
+		"From: alerts@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Alert: /promo reached 1000 clicks\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("message missing %q:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestSMTPMailerRejectedRecipient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(strings.ToUpper(line), "RCPT") {
+				conn.Write([]byte("550 No such user\r\n"))
+			} else {
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	err = NewSMTPMailer(host, port, "", "", "alerts@example.com").Send(context.Background(), []string{"nobody@example.com"}, "s", "b")
+	if err == nil || !strings.Contains(err.Error(), "nobody@example.com") {
+		t.Fatalf("Send error = %v, want rejected recipient", err)
+	}
+}
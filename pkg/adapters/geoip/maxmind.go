@@ -0,0 +1,55 @@
+package geoip
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// MaxMindResolver looks up visitor locations in a local MaxMind (GeoLite2/GeoIP2) City database
+type MaxMindResolver struct {
+	reader *geoip2.Reader
+}
+
+func NewMaxMindResolver(path string) (*MaxMindResolver, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindResolver{reader: reader}, nil
+}
+
+// Lookup returns the location for ip, which may include a port (e.g. r.RemoteAddr).
+// Unknown or unparsable addresses resolve to an empty location.
+func (r *MaxMindResolver) Lookup(ip string) (*domain.Location, error) {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return &domain.Location{}, nil
+	}
+
+	record, err := r.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := &domain.Location{
+		Country: record.Country.IsoCode,
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		loc.Region = record.Subdivisions[0].Names["en"]
+	}
+	return loc, nil
+}
+
+func (r *MaxMindResolver) Close() error {
+	return r.reader.Close()
+}
+
+// Ensure interface compliance
+var _ ports.GeoResolver = (*MaxMindResolver)(nil)
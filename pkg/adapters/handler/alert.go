@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+type AlertHandler struct {
+	service ports.AlertService
+}
+
+func NewAlertHandler(service ports.AlertService) *AlertHandler {
+	return &AlertHandler{service: service}
+}
+
+func (h *AlertHandler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	req := domain.AlertRule{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.service.CreateAlertRule(r.Context(), &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// ListAlertRules returns every rule, or those of one link with ?link_id=
+func (h *AlertHandler) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	var linkID int64
+	if idStr := r.URL.Query().Get("link_id"); idStr != "" {
+		var err error
+		if linkID, err = strconv.ParseInt(idStr, 10, 64); err != nil {
+			http.Error(w, "Invalid link_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rules, err := h.service.ListAlertRules(r.Context(), linkID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": rules})
+}
+
+func (h *AlertHandler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.service.GetAlertRule(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateAlertRule changes the fields present in the body; the others keep their values
+func (h *AlertHandler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.service.GetAlertRule(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule, err = h.service.UpdateAlertRule(r.Context(), id, rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *AlertHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteAlertRule(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
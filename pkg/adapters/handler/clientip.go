@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver extracts the originating client IP of a request.
+// Forwarding headers (Forwarded, X-Forwarded-For, X-Real-IP) are only honored
+// when the immediate peer is inside one of the trusted proxy CIDRs, otherwise
+// any client could spoof its address.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver builds a resolver trusting the given CIDRs or bare IPs
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, entry := range trustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			resolver.trusted = append(resolver.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// ClientIP returns the client address for r. A nil resolver trusts no proxies.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	peer := stripPort(r.RemoteAddr)
+	if c == nil || !c.isTrusted(peer) {
+		return peer
+	}
+
+	// Walk the proxy chain from the nearest hop outwards and return the
+	// first address that is not one of our own proxies.
+	chain := forwardedFor(r.Header.Values("Forwarded"))
+	if len(chain) == 0 {
+		chain = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	if len(chain) > 0 {
+		for i := len(chain) - 1; i >= 0; i-- {
+			if !c.isTrusted(chain[i]) {
+				return chain[i]
+			}
+		}
+		return chain[0]
+	}
+
+	if realIP := stripPort(strings.TrimSpace(r.Header.Get("X-Real-IP"))); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return peer
+}
+
+func (c *ClientIPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range c.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// xForwardedFor flattens X-Forwarded-For headers into a list of valid IPs, client first
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if ip := stripPort(strings.TrimSpace(hop)); net.ParseIP(ip) != nil {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers.
+// Obfuscated identifiers and "unknown" are skipped.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				ip := stripPort(strings.Trim(val, `"`))
+				if net.ParseIP(ip) != nil {
+					chain = append(chain, ip)
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// stripPort removes an optional port and IPv6 brackets, e.g. "[::1]:8080" -> "::1"
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
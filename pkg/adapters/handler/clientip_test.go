@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Failed to build resolver: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "Direct client",
+			remoteAddr: "203.0.113.7:5555",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "Untrusted peer cannot spoof",
+			remoteAddr: "203.0.113.7:5555",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "Trusted proxy X-Forwarded-For",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "Chain skips trusted hops but not spoofed ones",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 192.168.1.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "Forwarded header takes precedence",
+			remoteAddr: "10.1.2.3:5555",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711";proto=https`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			expected: "2001:db8::1",
+		},
+		{
+			name:       "X-Real-IP fallback",
+			remoteAddr: "192.168.1.1:5555",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.9"},
+			expected:   "198.51.100.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/open/abc", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if got := resolver.ClientIP(req); got != tt.expected {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
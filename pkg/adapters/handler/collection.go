@@ -2,34 +2,64 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/config"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
 )
 
 type CollectionHandler struct {
-	service ports.CollectionService
+	service       ports.CollectionService
+	clientIP      *ClientIPResolver
+	tokenKey      []byte // Signs preview links and access cookies
+	secureCookies bool
+	baseURL       string // Configured BASE_URL; empty falls back to the request's Host
 }
 
-func NewCollectionHandler(service ports.CollectionService) *CollectionHandler {
-	return &CollectionHandler{service: service}
+func NewCollectionHandler(service ports.CollectionService, cfg *config.Config, clientIP *ClientIPResolver) *CollectionHandler {
+	return &CollectionHandler{
+		service:       service,
+		clientIP:      clientIP,
+		tokenKey:      collectionTokenKey([]byte(cfg.JWTSecret)),
+		secureCookies: cfg.AppEnv == "production",
+		baseURL:       cfg.BaseURL,
+	}
+}
+
+type collectionRequest struct {
+	Title       string                      `json:"title"`
+	Slug        string                      `json:"slug"`
+	Description string                      `json:"description"`
+	AvatarURL   string                      `json:"avatar_url"`
+	Appearance  domain.CollectionAppearance `json:"appearance"`
+	Visibility  string                      `json:"visibility"`
+	Password    string                      `json:"password"`
+	PublishAt   *time.Time                  `json:"publish_at"`
+	UnpublishAt *time.Time                  `json:"unpublish_at"`
+	IsTemplate  bool                        `json:"is_template"`
 }
 
-type createCollectionRequest struct {
-	Title       string `json:"title"`
-	Slug        string `json:"slug"`
-	Description string `json:"description"`
+func (req collectionRequest) collection() *domain.Collection {
+	return &domain.Collection{Title: req.Title, Slug: req.Slug, Description: req.Description, AvatarURL: req.AvatarURL, Appearance: req.Appearance,
+		Visibility: req.Visibility, Password: req.Password, PublishAt: req.PublishAt, UnpublishAt: req.UnpublishAt, IsTemplate: req.IsTemplate}
 }
 
 func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
-	var req createCollectionRequest
+	var req collectionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	collection, err := h.service.CreateCollection(r.Context(), req.Title, req.Slug, req.Description)
+	collection, err := h.service.CreateCollection(r.Context(), req.collection())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -50,8 +80,18 @@ func (h *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Reque
 		limit = 10
 	}
 	search := r.URL.Query().Get("search")
+	visibility := r.URL.Query().Get("visibility")
+	var template *bool
+	if templateStr := r.URL.Query().Get("template"); templateStr != "" {
+		isTemplate, err := strconv.ParseBool(templateStr)
+		if err != nil {
+			http.Error(w, "Invalid template (true or false)", http.StatusBadRequest)
+			return
+		}
+		template = &isTemplate
+	}
 
-	collections, total, err := h.service.ListCollections(r.Context(), page, limit, search)
+	collections, total, err := h.service.ListCollections(r.Context(), page, limit, search, visibility, template)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -90,6 +130,7 @@ func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(collection)
 }
 
+// UpdateCollection changes the fields present in the body; the others keep their values
 func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -98,13 +139,26 @@ func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var req createCollectionRequest
+	existing, err := h.service.GetCollection(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Appearance fields not in the body keep their values too; so does the password
+	req := collectionRequest{Title: existing.Title, Slug: existing.Slug, Description: existing.Description, AvatarURL: existing.AvatarURL,
+		Appearance: existing.Appearance, Visibility: existing.Visibility, PublishAt: existing.PublishAt, UnpublishAt: existing.UnpublishAt,
+		IsTemplate: existing.IsTemplate}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	collection, err := h.service.UpdateCollection(r.Context(), id, req.Title, req.Slug, req.Description)
+	collection, err := h.service.UpdateCollection(r.Context(), id, req.collection())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -131,9 +185,16 @@ func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Requ
 }
 
 type addLinkRequest struct {
-	LinkID int64 `json:"link_id"`
+	LinkID       int64  `json:"link_id"`
+	Position     int    `json:"position"` // 1-based; omitted appends
+	Label        string `json:"label"`
+	Icon         string `json:"icon"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Highlight    bool   `json:"highlight"`
 }
 
+// AddLink adds a link to a collection, optionally with a label, icon, thumbnail and
+// highlight for this collection only. Responds 201 with the link item.
 func (h *CollectionHandler) AddLink(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	collectionID, err := strconv.ParseInt(idStr, 10, 64)
@@ -148,12 +209,184 @@ func (h *CollectionHandler) AddLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.AddLink(r.Context(), collectionID, req.LinkID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	item := &domain.CollectionItem{
+		Type:         domain.ItemLink,
+		LinkID:       req.LinkID,
+		Label:        req.Label,
+		Icon:         req.Icon,
+		ThumbnailURL: req.ThumbnailURL,
+		Highlight:    req.Highlight,
+	}
+	item, err = h.service.AddItem(r.Context(), collectionID, item, req.Position)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrLinkInCollection) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+type reorderLinksRequest struct {
+	LinkIDs []int64 `json:"link_ids"`
+}
+
+// ReorderLinks sets the order of a collection's links (PUT /api/v1/collections/{id}/links/order).
+// The body lists every link of the collection in the new order; other blocks stay in place.
+func (h *CollectionHandler) ReorderLinks(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Collection ID", http.StatusBadRequest)
+		return
+	}
+
+	var req reorderLinksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ReorderLinks(r.Context(), collectionID, req.LinkIDs); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrCollectionOrderMismatch) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type addItemRequest struct {
+	domain.CollectionItem
+	Position int `json:"position"` // 1-based; omitted appends
+}
+
+// AddItem adds a block (link, heading, text, image, social icons or embed) to a
+// collection (POST /api/v1/collections/{id}/items)
+func (h *CollectionHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Collection ID", http.StatusBadRequest)
+		return
+	}
+
+	var req addItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.service.AddItem(r.Context(), collectionID, &req.CollectionItem, req.Position)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrLinkInCollection) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// UpdateItem changes the fields of an item present in the body; the others keep their
+// values (PUT /api/v1/collections/{id}/items/{itemID})
+func (h *CollectionHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Collection ID", http.StatusBadRequest)
+		return
+	}
+	itemID, err := strconv.ParseInt(r.PathValue("itemID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Item ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.service.GetItem(r.Context(), collectionID, itemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := *existing
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.service.UpdateItem(r.Context(), collectionID, itemID, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func (h *CollectionHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Collection ID", http.StatusBadRequest)
+		return
+	}
+	itemID, err := strconv.ParseInt(r.PathValue("itemID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Item ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RemoveItem(r.Context(), collectionID, itemID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reorderItemsRequest struct {
+	ItemIDs []int64 `json:"item_ids"`
+}
+
+// ReorderItems sets the order of a collection's blocks (PUT /api/v1/collections/{id}/items/order).
+// The body lists every item of the collection in the new order.
+func (h *CollectionHandler) ReorderItems(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid Collection ID", http.StatusBadRequest)
+		return
+	}
+
+	var req reorderItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ReorderItems(r.Context(), collectionID, req.ItemIDs); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrCollectionOrderMismatch) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *CollectionHandler) RemoveLink(w http.ResponseWriter, r *http.Request) {
@@ -179,14 +412,177 @@ func (h *CollectionHandler) RemoveLink(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetPublicCollection serves the link-in-bio page of a collection (GET /u/{slug}). It is
+// HTML unless JSON is asked for with ?format=json or an Accept: application/json header.
 func (h *CollectionHandler) GetPublicCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.showPage(w, r, !wantsJSON(r))
+	if !ok {
+		return
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(collection)
+		return
+	}
+	renderCollectionPage(w, collection, pageOptions{OEmbedURL: h.oEmbedURL(r, collection)})
+}
+
+// showPage loads the collection of a page request and applies its visibility. Drafts and
+// pages outside their publishing window are not found unless a valid ?preview= token is
+// given; password-protected pages must be unlocked first, asking for the password with a
+// form when passwordForm is set. Other views are recorded unless ?no_stat is set. It
+// returns false when the response has been written.
+func (h *CollectionHandler) showPage(w http.ResponseWriter, r *http.Request, passwordForm bool) (*domain.Collection, bool) {
+	collection, ok := h.publicCollection(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	preview := h.validCollectionToken(r.URL.Query().Get("preview"), collection, previewAudience)
+	if !preview {
+		if !collection.IsLive(time.Now()) {
+			http.NotFound(w, r)
+			return nil, false
+		}
+		if collection.Visibility == domain.VisibilityPassword && !h.unlocked(r, collection) {
+			if passwordForm {
+				renderPasswordPage(w, collection, http.StatusUnauthorized, "")
+			} else {
+				http.Error(w, "Password required", http.StatusUnauthorized)
+			}
+			return nil, false
+		}
+	}
+
+	if preview || collection.Visibility != domain.VisibilityPublic {
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+
+	// Previews and no_stat requests aren't counted; a failed write never blocks the page
+	if !preview && r.URL.Query().Get("no_stat") == "" {
+		input := domain.VisitInput{
+			Referer:   r.Header.Get("Referer"),
+			UserAgent: r.UserAgent(),
+			IP:        h.clientIP.ClientIP(r),
+		}
+		if err := h.service.RecordView(r.Context(), collection, input); err != nil {
+			log.Printf("Failed to record view of collection %d: %v", collection.ID, err)
+		}
+	}
+	return collection, true
+}
+
+// UnlockCollection checks the password posted from a protected page (POST /u/{slug}) and
+// on success sets a cookie that unlocks the page for a day
+func (h *CollectionHandler) UnlockCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.publicCollection(w, r)
+	if !ok {
+		return
+	}
+	if collection.Visibility != domain.VisibilityPassword || !collection.IsLive(time.Now()) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ok, wait := h.service.VerifyPassword(collection, r.PostFormValue("password"), h.clientIP.ClientIP(r))
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		renderPasswordPage(w, collection, http.StatusTooManyRequests, fmt.Sprintf("Too many attempts, please try again in %d seconds.", seconds))
+		return
+	}
+	if !ok {
+		renderPasswordPage(w, collection, http.StatusUnauthorized, "Incorrect password, please try again.")
+		return
+	}
+
+	token, expires, err := h.signCollectionToken(collection, accessAudience, accessTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookieName(collection),
+		Value:    token,
+		Expires:  expires,
+		Path:     "/u/",
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/u/"+collection.Slug, http.StatusSeeOther)
+}
+
+// publicCollection loads the collection of a /u/{slug} request, writing the error response
+// when there is none
+func (h *CollectionHandler) publicCollection(w http.ResponseWriter, r *http.Request) (*domain.Collection, bool) {
 	slug := r.PathValue("slug")
 	if slug == "" {
 		http.Error(w, "Slug required", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
 	collection, err := h.service.GetCollectionBySlug(r.Context(), slug)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if collection == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return collection, true
+}
+
+// CollectionStats returns a collection page's views, clicks, click-through rate and clicks
+// per link item (GET /api/v1/collections/{id}/stats?from=&to=)
+func (h *CollectionHandler) CollectionStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var from, to time.Time
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		if from, err = parseTimeParam(fromStr, time.UTC); err != nil {
+			http.Error(w, "Invalid from (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = parseTimeParam(toStr, time.UTC); err != nil {
+			http.Error(w, "Invalid to (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetStats(r.Context(), id, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// CreatePreviewLink issues a link that shows the collection's page for an hour whatever
+// its visibility and schedule (POST /api/v1/collections/{id}/preview)
+func (h *CollectionHandler) CreatePreviewLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.service.GetCollection(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -196,9 +592,16 @@ func (h *CollectionHandler) GetPublicCollection(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// TODO: Fetch links for the collection here if they are not already populated
-	// For now, returning the collection object. Ideally we should expand links.
+	token, expires, err := h.signCollectionToken(collection, previewAudience, previewTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":        "/u/" + url.PathEscape(collection.Slug) + "?preview=" + url.QueryEscape(token),
+		"expires_at": expires.UTC(),
+	})
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+const (
+	previewAudience = "collection-preview" // Preview links of unpublished pages
+	accessAudience  = "collection-access"  // Cookies of unlocked password-protected pages
+
+	previewTTL = time.Hour
+	accessTTL  = 24 * time.Hour
+)
+
+var collectionPasswordTemplate = template.Must(template.ParseFS(templateFS, "templates/collection_password.html"))
+
+// collectionTokenKey derives the key of preview and access tokens from the JWT secret, so
+// they can never pass as an auth_token
+func collectionTokenKey(jwtSecret []byte) []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("collection-pages"))
+	return mac.Sum(nil)
+}
+
+// passwordFingerprint ties access tokens to the current password, so changing it locks
+// visitors out again
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// signCollectionToken issues a token for the collection valid for ttl
+func (h *CollectionHandler) signCollectionToken(collection *domain.Collection, audience string, ttl time.Duration) (string, time.Time, error) {
+	expires := time.Now().Add(ttl)
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(collection.ID, 10),
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(expires),
+	}
+	if audience == accessAudience {
+		claims.ID = passwordFingerprint(collection.PasswordHash)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.tokenKey)
+	return token, expires, err
+}
+
+// validCollectionToken reports whether token was issued for the collection and audience
+// and hasn't expired
+func (h *CollectionHandler) validCollectionToken(token string, collection *domain.Collection, audience string) bool {
+	if token == "" {
+		return false
+	}
+	claims := &jwt.RegisteredClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return h.tokenKey, nil
+	}, jwt.WithAudience(audience), jwt.WithExpirationRequired(), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil || !parsed.Valid || claims.Subject != strconv.FormatInt(collection.ID, 10) {
+		return false
+	}
+	return audience != accessAudience || claims.ID == passwordFingerprint(collection.PasswordHash)
+}
+
+// accessCookieName is the cookie holding the access token of an unlocked collection
+func accessCookieName(collection *domain.Collection) string {
+	return "collection_access_" + strconv.FormatInt(collection.ID, 10)
+}
+
+// unlocked reports whether the visitor entered the collection's password
+func (h *CollectionHandler) unlocked(r *http.Request, collection *domain.Collection) bool {
+	cookie, err := r.Cookie(accessCookieName(collection))
+	return err == nil && h.validCollectionToken(cookie.Value, collection, accessAudience)
+}
+
+// passwordPage is the data rendered by templates/collection_password.html
+type passwordPage struct {
+	Collection *domain.Collection
+	Style      pageStyle
+	Error      string
+}
+
+// renderPasswordPage asks for the password of a protected collection
+func renderPasswordPage(w http.ResponseWriter, collection *domain.Collection, status int, message string) {
+	var buf bytes.Buffer
+	page := passwordPage{Collection: collection, Style: newPageStyle(collection.Appearance), Error: message}
+	if err := collectionPasswordTemplate.Execute(&buf, page); err != nil {
+		log.Printf("Failed to render password page of %q: %v", collection.Slug, err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
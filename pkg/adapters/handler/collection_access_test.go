@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestCollectionTokens(t *testing.T) {
+	secret := []byte("secret")
+	h := &CollectionHandler{tokenKey: collectionTokenKey(secret)}
+	collection := &domain.Collection{ID: 7, Visibility: domain.VisibilityPassword, PasswordHash: "hash-1"}
+
+	preview, _, err := h.signCollectionToken(collection, previewAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.validCollectionToken(preview, collection, previewAudience) {
+		t.Error("preview token rejected")
+	}
+	if h.validCollectionToken(preview, &domain.Collection{ID: 8}, previewAudience) {
+		t.Error("preview token accepted for another collection")
+	}
+	if h.validCollectionToken(preview, collection, accessAudience) {
+		t.Error("preview token accepted as access token")
+	}
+	if _, err := jwt.Parse(preview, func(*jwt.Token) (interface{}, error) { return secret, nil }); err == nil {
+		t.Error("preview token passes as an auth token")
+	}
+
+	access, _, err := h.signCollectionToken(collection, accessAudience, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.validCollectionToken(access, collection, accessAudience) {
+		t.Error("access token rejected")
+	}
+	changed := *collection
+	changed.PasswordHash = "hash-2"
+	if h.validCollectionToken(access, &changed, accessAudience) {
+		t.Error("access token survived a password change")
+	}
+
+	expired, _, _ := h.signCollectionToken(collection, previewAudience, -time.Minute)
+	if h.validCollectionToken(expired, collection, previewAudience) {
+		t.Error("expired token accepted")
+	}
+}
+
+func TestRenderPasswordPage(t *testing.T) {
+	collection := &domain.Collection{Slug: "jane", Title: "Jane", Description: "Secret things", Visibility: domain.VisibilityPassword}
+
+	rr := httptest.NewRecorder()
+	renderPasswordPage(rr, collection, http.StatusUnauthorized, "Incorrect password, please try again.")
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{`<form method="post" action="/u/jane">`, "Incorrect password", `name="robots" content="noindex"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page is missing %s", want)
+		}
+	}
+	if strings.Contains(body, "Secret things") {
+		t.Error("password page shows the collection's content")
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// maxImportSize caps the body of a collection import
+const maxImportSize = 1 << 20
+
+// CloneCollection copies a collection to a new slug as a draft
+// (POST /api/v1/collections/{id}/clone with {"slug", "title", "links": "share"|"duplicate"})
+func (h *CollectionHandler) CloneCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var opts domain.CopyOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.service.CloneCollection(r.Context(), id, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// ExportCollection downloads a collection with its items and appearance as JSON
+// (GET /api/v1/collections/{id}/export)
+func (h *CollectionHandler) ExportCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.service.ExportCollection(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if export == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="collection-%d.json"`, id))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+// ImportCollection creates a collection from an export
+// (POST /api/v1/collections/import?slug=&title=&links=share|duplicate)
+func (h *CollectionHandler) ImportCollection(w http.ResponseWriter, r *http.Request) {
+	var export domain.CollectionExport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportSize)).Decode(&export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := domain.CopyOptions{Slug: query.Get("slug"), Title: query.Get("title"), Links: query.Get("links")}
+	collection, err := h.service.ImportCollection(r.Context(), &export, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
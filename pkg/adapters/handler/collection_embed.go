@@ -0,0 +1,226 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+//go:embed static/embed.js
+var embedScript []byte
+
+// pageLayouts are the layouts of embedded pages besides the regular list
+var pageLayouts = []string{"compact", "grid"}
+
+const (
+	defaultEmbedWidth  = 480
+	defaultEmbedHeight = 600
+)
+
+// EmbedCollection serves the collection page for an iframe on another site
+// (GET /u/{slug}/embed?layout=compact|grid&header=0). Visibility applies as on the page,
+// but password-protected pages can't be unlocked from the frame.
+func (h *CollectionHandler) EmbedCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.showPage(w, r, false)
+	if !ok {
+		return
+	}
+
+	opts := pageOptions{Embed: true, HideHeader: r.URL.Query().Get("header") == "0"}
+	if layout := r.URL.Query().Get("layout"); slices.Contains(pageLayouts, layout) {
+		opts.Layout = layout
+	}
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	renderCollectionPage(w, collection, opts)
+}
+
+// EmbedScript serves the loader that turns <div data-collection="slug"> elements into
+// auto-sizing iframes (GET /embed.js)
+func (h *CollectionHandler) EmbedScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(embedScript)
+}
+
+// OEmbed describes how to embed a collection page (GET /oembed?url=...). Only pages of
+// this host that anyone can see are embeddable; JSON is the only format.
+func (h *CollectionHandler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		http.Error(w, "Only the json format is supported", http.StatusNotImplemented)
+		return
+	}
+	base, shared := h.absoluteBase(w, r)
+	host := strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://")
+	slug, ok := pageSlug(query.Get("url"), host)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	collection, err := h.service.GetCollectionBySlug(r.Context(), slug)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if collection == nil || !collection.IsLive(time.Now()) || collection.Visibility == domain.VisibilityPassword {
+		http.NotFound(w, r)
+		return
+	}
+
+	width := embedDimension(query.Get("maxwidth"), defaultEmbedWidth)
+	height := embedDimension(query.Get("maxheight"), defaultEmbedHeight)
+	title := collection.Title
+	if title == "" {
+		title = collection.Slug
+	}
+	src := base + "/u/" + url.PathEscape(collection.Slug) + "/embed"
+
+	resp := map[string]interface{}{
+		"version":       "1.0",
+		"type":          "rich",
+		"title":         title,
+		"provider_name": host,
+		"provider_url":  base,
+		"width":         width,
+		"height":        height,
+		"cache_age":     3600,
+		"html": fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0" loading="lazy"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(title)),
+	}
+	if collection.AvatarURL != "" {
+		resp["thumbnail_url"] = collection.AvatarURL
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	cacheControl(w, shared, 3600)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// oEmbedURL is the oEmbed endpoint describing the collection's page, advertised by
+// public pages only
+func (h *CollectionHandler) oEmbedURL(r *http.Request, collection *domain.Collection) string {
+	if collection.Visibility != domain.VisibilityPublic {
+		return ""
+	}
+	base := h.baseURL
+	if base == "" {
+		base = requestBaseURL(r)
+	}
+	pageURL := base + "/u/" + url.PathEscape(collection.Slug)
+	return base + "/oembed?format=json&url=" + url.QueryEscape(pageURL)
+}
+
+// pageSlug returns the slug of a collection page URL on host, e.g. https://host/u/jane
+func pageSlug(raw, host string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, host) {
+		return "", false
+	}
+	slug, ok := strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/u/")
+	slug = strings.TrimSuffix(slug, "/embed")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return "", false
+	}
+	return slug, true
+}
+
+// embedDimension is the default size, reduced to the consumer's maximum when smaller
+func embedDimension(max string, def int) int {
+	if n, err := strconv.Atoi(max); err == nil && n > 0 && n < def {
+		return n
+	}
+	return def
+}
+
+// widgetCollection is the public JSON of a collection for client-side widgets: what the
+// page shows, with absolute URLs
+type widgetCollection struct {
+	Slug        string                      `json:"slug"`
+	Title       string                      `json:"title"`
+	Description string                      `json:"description,omitempty"`
+	AvatarURL   string                      `json:"avatar_url,omitempty"`
+	Appearance  domain.CollectionAppearance `json:"appearance"`
+	PageURL     string                      `json:"page_url"`
+	Items       []widgetItem                `json:"items"`
+}
+
+type widgetItem struct {
+	Type         string           `json:"type"`
+	Title        string           `json:"title,omitempty"` // Link button text
+	URL          string           `json:"url,omitempty"`   // Link target, image source
+	Text         string           `json:"text,omitempty"`
+	Icon         string           `json:"icon,omitempty"`
+	ThumbnailURL string           `json:"thumbnail_url,omitempty"`
+	Highlight    bool             `json:"highlight,omitempty"`
+	EmbedURL     string           `json:"embed_url,omitempty"`
+	Socials      []pageSocial     `json:"socials,omitempty"`
+	Style        domain.ItemStyle `json:"style"`
+}
+
+// newWidgetCollection resolves the collection's items as the page renders them
+func newWidgetCollection(collection *domain.Collection, baseURL string) widgetCollection {
+	page := newCollectionPage(collection, pageOptions{})
+	widget := widgetCollection{
+		Slug:        collection.Slug,
+		Title:       collection.Title,
+		Description: collection.Description,
+		AvatarURL:   collection.AvatarURL,
+		Appearance:  collection.Appearance,
+		PageURL:     baseURL + "/u/" + collection.Slug,
+		Items:       make([]widgetItem, 0, len(page.Items)),
+	}
+	for _, item := range page.Items {
+		wi := widgetItem{
+			Type:         item.Type,
+			Title:        item.Title,
+			URL:          item.URL,
+			Text:         item.Text,
+			Icon:         item.Icon,
+			ThumbnailURL: item.Thumbnail,
+			Highlight:    item.Highlight,
+			EmbedURL:     item.Embed,
+			Socials:      item.Socials,
+			Style:        item.Style,
+		}
+		if item.Type == domain.ItemLink {
+			wi.URL = baseURL + item.URL
+		}
+		widget.Items = append(widget.Items, wi)
+	}
+	return widget
+}
+
+// CollectionWidget serves a collection as JSON any site may fetch
+// (GET /api/v1/public/collections/{slug}). Drafts, pages outside their publishing window
+// and password-protected pages are not available.
+func (h *CollectionHandler) CollectionWidget(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	collection, ok := h.publicCollection(w, r)
+	if !ok {
+		return
+	}
+	if !collection.IsLive(time.Now()) || collection.Visibility == domain.VisibilityPassword {
+		http.NotFound(w, r)
+		return
+	}
+
+	base, shared := h.absoluteBase(w, r)
+	if collection.Visibility != domain.VisibilityPublic {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	cacheControl(w, shared && collection.Visibility == domain.VisibilityPublic, 60)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWidgetCollection(collection, base))
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+func TestRenderEmbeddedCollectionPage(t *testing.T) {
+	collection := &domain.Collection{
+		Slug:  "jane",
+		Title: "Jane",
+		Items: []domain.CollectionItem{
+			{ID: 11, Type: domain.ItemLink, Link: &domain.Link{ShortCode: "abc123", Title: "My blog", OriginalURL: "https://blog.example.com/"}},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	renderCollectionPage(rr, collection, pageOptions{Embed: true, Layout: "grid", HideHeader: true})
+	body := rr.Body.String()
+	for _, want := range []string{
+		`<base target="_blank">`,
+		`<body class="embed grid">`,
+		`type: "collection-embed"`,
+		`href="/open/abc123?ci=11"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("embedded page is missing %s", want)
+		}
+	}
+	if strings.Contains(body, "<h1>") {
+		t.Error("header was rendered although hidden")
+	}
+
+	rr = httptest.NewRecorder()
+	renderCollectionPage(rr, collection, pageOptions{OEmbedURL: "https://sho.rt/oembed?format=json&url=https%3A%2F%2Fsho.rt%2Fu%2Fjane"})
+	body = rr.Body.String()
+	if !strings.Contains(body, `<link rel="alternate" type="application/json+oembed" href="https://sho.rt/oembed?format=json&amp;url=https%3A%2F%2Fsho.rt%2Fu%2Fjane">`) {
+		t.Error("page is missing the oEmbed discovery link")
+	}
+	if strings.Contains(body, "collection-embed") || strings.Contains(body, "<base") {
+		t.Error("regular page has embed markup")
+	}
+}
+
+func TestPageSlug(t *testing.T) {
+	tests := []struct {
+		url  string
+		slug string
+		ok   bool
+	}{
+		{"https://sho.rt/u/jane", "jane", true},
+		{"http://SHO.RT/u/jane/", "jane", true},
+		{"https://sho.rt/u/jane/embed?layout=grid", "jane", true},
+		{"https://other.example/u/jane", "", false},
+		{"https://sho.rt/u/jane/feed.xml", "", false},
+		{"https://sho.rt/open/abc123", "", false},
+		{"https://sho.rt/u/", "", false},
+		{"javascript://sho.rt/u/jane", "", false},
+	}
+	for _, tt := range tests {
+		slug, ok := pageSlug(tt.url, "sho.rt")
+		if slug != tt.slug || ok != tt.ok {
+			t.Errorf("pageSlug(%q) = %q, %v, want %q, %v", tt.url, slug, ok, tt.slug, tt.ok)
+		}
+	}
+}
+
+func TestNewWidgetCollection(t *testing.T) {
+	collection := &domain.Collection{
+		Slug: "jane",
+		Items: []domain.CollectionItem{
+			{Type: domain.ItemHeading, Text: "Writing"},
+			{ID: 11, Type: domain.ItemLink, Icon: "✍️", Link: &domain.Link{ShortCode: "abc123", OriginalURL: "https://blog.example.com/"}},
+			{Type: domain.ItemEmbed, URL: "https://evil.test/player"},
+		},
+	}
+
+	widget := newWidgetCollection(collection, "https://sho.rt")
+	if widget.PageURL != "https://sho.rt/u/jane" || len(widget.Items) != 2 {
+		t.Fatalf("widget = %+v, want the page URL and the heading and link", widget)
+	}
+	link := widget.Items[1]
+	if link.URL != "https://sho.rt/open/abc123?ci=11" || link.Title != "blog.example.com" || link.Icon != "✍️" {
+		t.Errorf("link item = %+v", link)
+	}
+}
+
+// slugService serves a single collection by slug
+type slugService struct {
+	ports.CollectionService
+	collection *domain.Collection
+}
+
+func (s *slugService) GetCollectionBySlug(ctx context.Context, slug string) (*domain.Collection, error) {
+	if slug != s.collection.Slug {
+		return nil, nil
+	}
+	return s.collection, nil
+}
+
+func TestAbsoluteURLsIgnoreForgedHost(t *testing.T) {
+	service := &slugService{collection: &domain.Collection{Slug: "jane", Title: "Jane", Visibility: domain.VisibilityPublic}}
+	oembed := func(h *CollectionHandler, pageURL string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/oembed?format=json&url="+url.QueryEscape(pageURL), nil)
+		req.Host = "evil.example"
+		rr := httptest.NewRecorder()
+		h.OEmbed(rr, req)
+		return rr
+	}
+
+	configured := &CollectionHandler{service: service, baseURL: "https://sho.rt"}
+	rr := oembed(configured, "https://sho.rt/u/jane")
+	if rr.Code != 200 {
+		t.Fatalf("oEmbed with BASE_URL = %d, want 200", rr.Code)
+	}
+	if body := rr.Body.String(); strings.Contains(body, "evil.example") || !strings.Contains(body, "https://sho.rt/u/jane/embed") {
+		t.Errorf("oEmbed with BASE_URL did not use it: %s", body)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control with BASE_URL = %q", got)
+	}
+	if rr := oembed(configured, "https://evil.example/u/jane"); rr.Code != 404 {
+		t.Errorf("oEmbed for another host = %d, want 404", rr.Code)
+	}
+
+	fallback := &CollectionHandler{service: service}
+	rr = oembed(fallback, "https://evil.example/u/jane")
+	if rr.Code != 200 {
+		t.Fatalf("oEmbed without BASE_URL = %d, want 200", rr.Code)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "private, max-age=3600" {
+		t.Errorf("Cache-Control without BASE_URL = %q", got)
+	}
+	if got := rr.Header().Values("Vary"); !slices.Contains(got, "Host") {
+		t.Errorf("Vary without BASE_URL = %v, want Host", got)
+	}
+}
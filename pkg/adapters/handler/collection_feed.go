@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// collectionFeed is a collection's links as a feed, newest first
+type collectionFeed struct {
+	Title       string
+	Description string
+	HomeURL     string // The collection's page
+	FeedURL     string // This feed
+	Icon        string
+	Updated     time.Time
+	Entries     []feedEntry
+}
+
+type feedEntry struct {
+	ID          string
+	Title       string
+	URL         string    // Short URL
+	ExternalURL string    // Destination
+	Published   time.Time // Added to the collection
+	Updated     time.Time
+}
+
+// feedFormats are the feeds served at /u/{slug}/{name}
+var feedFormats = map[string]struct {
+	contentType string
+	encode      func(collectionFeed) ([]byte, error)
+}{
+	"feed.xml":  {"application/atom+xml; charset=utf-8", collectionFeed.atom},
+	"rss.xml":   {"application/rss+xml; charset=utf-8", collectionFeed.rss},
+	"feed.json": {"application/feed+json; charset=utf-8", collectionFeed.jsonFeed},
+}
+
+// newCollectionFeed lists the collection's link items, with URLs under baseURL. Updated
+// is the latest change to the collection, its items or their links.
+func newCollectionFeed(collection *domain.Collection, baseURL, feedName string) collectionFeed {
+	feed := collectionFeed{
+		Title:       collection.Title,
+		Description: collection.Description,
+		HomeURL:     baseURL + "/u/" + collection.Slug,
+		FeedURL:     baseURL + "/u/" + collection.Slug + "/" + feedName,
+		Icon:        collection.AvatarURL,
+		Updated:     collection.UpdatedAt,
+		Entries:     []feedEntry{},
+	}
+	if feed.Title == "" {
+		feed.Title = collection.Slug
+	}
+
+	for _, item := range collection.Items {
+		if item.Type != domain.ItemLink || item.Link == nil {
+			continue
+		}
+		shortURL := baseURL + "/open/" + item.Link.ShortCode
+		entry := feedEntry{
+			ID:          shortURL,
+			Title:       item.ButtonText(),
+			URL:         shortURL,
+			ExternalURL: item.Link.OriginalURL,
+			Published:   item.CreatedAt,
+			Updated:     item.UpdatedAt,
+		}
+		if item.Link.UpdatedAt.After(entry.Updated) {
+			entry.Updated = item.Link.UpdatedAt
+		}
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	sort.SliceStable(feed.Entries, func(i, j int) bool {
+		return feed.Entries[i].Published.After(feed.Entries[j].Published)
+	})
+	return feed
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Icon     string      `xml:"icon,omitempty"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Links     []atomLink `xml:"link"`
+}
+
+// atom encodes the feed as Atom 1.0
+func (f collectionFeed) atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.HomeURL,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Icon:     f.Icon,
+		Links: []atomLink{
+			{Rel: "alternate", Type: "text/html", Href: f.HomeURL},
+			{Rel: "self", Type: "application/atom+xml", Href: f.FeedURL},
+		},
+	}
+	for _, e := range f.Entries {
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "alternate", Href: e.URL},
+				{Rel: "related", Href: e.ExternalURL},
+			},
+		})
+	}
+	return encodeXML(doc)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rss encodes the feed as RSS 2.0
+func (f collectionFeed) rss() ([]byte, error) {
+	doc := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.HomeURL,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.FeedURL},
+		},
+	}
+	if doc.Channel.Description == "" {
+		doc.Channel.Description = f.Title
+	}
+	for _, e := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			Description: e.ExternalURL,
+			GUID:        rssGUID{IsPermaLink: true, Value: e.ID},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return encodeXML(doc)
+}
+
+func encodeXML(doc interface{}) ([]byte, error) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	ExternalURL   string `json:"external_url,omitempty"`
+	Title         string `json:"title"`
+	ContentText   string `json:"content_text"`
+	DatePublished string `json:"date_published"`
+	DateModified  string `json:"date_modified"`
+}
+
+// jsonFeed encodes the feed as JSON Feed 1.1
+func (f collectionFeed) jsonFeed() ([]byte, error) {
+	items := make([]jsonFeedItem, 0, len(f.Entries))
+	for _, e := range f.Entries {
+		items = append(items, jsonFeedItem{
+			ID:            e.ID,
+			URL:           e.URL,
+			ExternalURL:   e.ExternalURL,
+			Title:         e.Title,
+			ContentText:   e.ExternalURL,
+			DatePublished: e.Published.UTC().Format(time.RFC3339),
+			DateModified:  e.Updated.UTC().Format(time.RFC3339),
+		})
+	}
+	doc := map[string]interface{}{
+		"version":       "https://jsonfeed.org/version/1.1",
+		"title":         f.Title,
+		"home_page_url": f.HomeURL,
+		"feed_url":      f.FeedURL,
+		"items":         items,
+	}
+	if f.Description != "" {
+		doc["description"] = f.Description
+	}
+	if f.Icon != "" {
+		doc["icon"] = f.Icon
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// requestBaseURL is the scheme and host the request was made to, for absolute URLs
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// absoluteBase is the scheme and host for absolute URLs in a response: BASE_URL when
+// configured, else the request's. Anyone can set the Host header, so a request-derived
+// base varies the response by Host and shared reports it must not reach shared caches.
+func (h *CollectionHandler) absoluteBase(w http.ResponseWriter, r *http.Request) (base string, shared bool) {
+	if h.baseURL != "" {
+		return h.baseURL, true
+	}
+	w.Header().Add("Vary", "Host")
+	w.Header().Add("Vary", "X-Forwarded-Proto")
+	return requestBaseURL(r), false
+}
+
+// cacheControl lets a response be cached for maxAge seconds, by shared caches only if shared
+func cacheControl(w http.ResponseWriter, shared bool, maxAge int) {
+	scope := "private"
+	if shared {
+		scope = "public"
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+}
+
+// CollectionFeed serves a collection's links as Atom (/u/{slug}/feed.xml), RSS
+// (/u/{slug}/rss.xml) or JSON Feed (/u/{slug}/feed.json). Feeds follow the page's
+// visibility and answer conditional requests from their ETag and Last-Modified.
+func (h *CollectionHandler) CollectionFeed(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("feed")
+	format, ok := feedFormats[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	collection, ok := h.publicCollection(w, r)
+	if !ok {
+		return
+	}
+	if !collection.IsLive(time.Now()) {
+		http.NotFound(w, r)
+		return
+	}
+	if collection.Visibility == domain.VisibilityPassword && !h.unlocked(r, collection) {
+		http.Error(w, "Password required", http.StatusUnauthorized)
+		return
+	}
+
+	base, shared := h.absoluteBase(w, r)
+	feed := newCollectionFeed(collection, base, name)
+	body, err := format.encode(feed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	// From the body, so entries that disappear without a newer timestamp (e.g. a deleted
+	// link) still change it
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+	if collection.Visibility != domain.VisibilityPublic {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	cacheControl(w, shared && collection.Visibility == domain.VisibilityPublic, 300)
+	// Handles If-None-Match and If-Modified-Since
+	http.ServeContent(w, r, name, feed.Updated, bytes.NewReader(body))
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestCollectionFeed(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 12, 0, 0, 0, time.UTC) }
+	collection := &domain.Collection{
+		Slug:      "reading",
+		Title:     "Reading <list>",
+		UpdatedAt: day(3),
+		Items: []domain.CollectionItem{
+			{ID: 1, Type: domain.ItemLink, CreatedAt: day(1), UpdatedAt: day(1),
+				Link: &domain.Link{ShortCode: "old111", Title: "Older", OriginalURL: "https://a.example.com/x?a=1&b=2", UpdatedAt: day(6)}},
+			{ID: 2, Type: domain.ItemHeading, Text: "Not a link", CreatedAt: day(4), UpdatedAt: day(4)},
+			{ID: 3, Type: domain.ItemLink, Label: "Newer", CreatedAt: day(2), UpdatedAt: day(5),
+				Link: &domain.Link{ShortCode: "new222", OriginalURL: "https://b.example.com/"}},
+		},
+	}
+
+	feed := newCollectionFeed(collection, "https://sho.rt", "feed.xml")
+	if !feed.Updated.Equal(day(6)) {
+		t.Errorf("Updated = %v, want the latest link change", feed.Updated)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].Title != "Newer" || feed.Entries[1].URL != "https://sho.rt/open/old111" {
+		t.Fatalf("entries = %+v, want the two links newest first", feed.Entries)
+	}
+
+	atom, err := feed.atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var atomDoc atomFeed
+	if err := xml.Unmarshal(atom, &atomDoc); err != nil {
+		t.Fatalf("invalid Atom: %v\n%s", err, atom)
+	}
+	if atomDoc.Title != "Reading <list>" || len(atomDoc.Entries) != 2 || atomDoc.Updated != "2026-05-06T12:00:00Z" {
+		t.Errorf("Atom feed = %+v", atomDoc)
+	}
+
+	rss, err := feed.rss()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rssDoc rssFeed
+	if err := xml.Unmarshal(rss, &rssDoc); err != nil {
+		t.Fatalf("invalid RSS: %v\n%s", err, rss)
+	}
+	if len(rssDoc.Channel.Items) != 2 || rssDoc.Channel.Items[1].Description != "https://a.example.com/x?a=1&b=2" {
+		t.Errorf("RSS items = %+v", rssDoc.Channel.Items)
+	}
+	if !strings.Contains(string(rss), `<pubDate>Sat, 02 May 2026 12:00:00 +0000</pubDate>`) {
+		t.Errorf("RSS dates are not RFC 1123:\n%s", rss)
+	}
+
+	jsonFeed, err := feed.jsonFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonDoc struct {
+		Version string         `json:"version"`
+		FeedURL string         `json:"feed_url"`
+		Items   []jsonFeedItem `json:"items"`
+	}
+	if err := json.Unmarshal(jsonFeed, &jsonDoc); err != nil {
+		t.Fatal(err)
+	}
+	if jsonDoc.Version != "https://jsonfeed.org/version/1.1" || jsonDoc.FeedURL != "https://sho.rt/u/reading/feed.xml" || len(jsonDoc.Items) != 2 {
+		t.Errorf("JSON Feed = %+v", jsonDoc)
+	}
+}
+
+func TestCollectionFeedETagFollowsEntries(t *testing.T) {
+	updated := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	link := func(id int64, code string) domain.CollectionItem {
+		return domain.CollectionItem{ID: id, Type: domain.ItemLink, CreatedAt: updated, UpdatedAt: updated,
+			Link: &domain.Link{ShortCode: code, OriginalURL: "https://" + code + ".example.com/", UpdatedAt: updated}}
+	}
+	service := &slugService{collection: &domain.Collection{Slug: "jane", Visibility: domain.VisibilityPublic, UpdatedAt: updated,
+		Items: []domain.CollectionItem{link(1, "aaa111"), link(2, "bbb222")}}}
+	h := &CollectionHandler{service: service, baseURL: "https://sho.rt"}
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/u/jane/feed.json", nil)
+		req.SetPathValue("slug", "jane")
+		req.SetPathValue("feed", "feed.json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		h.CollectionFeed(rr, req)
+		return rr
+	}
+
+	etag := get("").Header().Get("ETag")
+	if rr := get(etag); rr.Code != 304 {
+		t.Fatalf("unchanged feed = %d, want 304", rr.Code)
+	}
+
+	// A deleted link drops out of the items without a newer timestamp
+	service.collection.Items = service.collection.Items[:1]
+	rr := get(etag)
+	if rr.Code != 200 || strings.Contains(rr.Body.String(), "bbb222") {
+		t.Errorf("feed without a link = %d, want 200 without it", rr.Code)
+	}
+	if rr.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after an entry disappeared")
+	}
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var collectionTemplate = template.Must(template.ParseFS(templateFS, "templates/collection.html"))
+
+// collectionPage is the data rendered by templates/collection.html
+type collectionPage struct {
+	Collection *domain.Collection
+	Items      []pageItem
+	Style      pageStyle
+	Options    pageOptions
+}
+
+// pageOptions vary how a collection page is rendered
+type pageOptions struct {
+	Embed      bool   // Framed on another site: links open in a new tab, height is reported to the parent
+	Layout     string // One of pageLayouts; "" is the regular list
+	HideHeader bool   // Leaves out the avatar, title and description
+	OEmbedURL  string // Advertised for oEmbed discovery
+}
+
+// pageStyle is a collection's appearance resolved against its theme
+type pageStyle struct {
+	Background      string
+	Text            string
+	Muted           string
+	Button          string
+	ButtonText      string
+	ButtonBorder    string
+	Radius          string
+	Outline         bool
+	Font            template.CSS // From pageFonts, so quotes in font names survive escaping
+	BackgroundImage string
+	CustomCSS       template.CSS // Sanitized with domain.SanitizeCSS
+}
+
+// pageThemes are the built-in themes' colors, keyed by domain.CollectionThemes
+var pageThemes = map[string]pageStyle{
+	domain.ThemeDefault: {Background: "#f5f5f4", Text: "#1c1917", Muted: "#57534e", Button: "#ffffff", ButtonText: "#1c1917", ButtonBorder: "#d6d3d1"},
+	domain.ThemeDark:    {Background: "#18181b", Text: "#fafafa", Muted: "#a1a1aa", Button: "#27272a", ButtonText: "#fafafa", ButtonBorder: "#3f3f46"},
+	domain.ThemeMinimal: {Background: "#ffffff", Text: "#111111", Muted: "#666666", Button: "#ffffff", ButtonText: "#111111", ButtonBorder: "#111111"},
+	domain.ThemeOcean:   {Background: "#0c4a6e", Text: "#f0f9ff", Muted: "#bae6fd", Button: "#e0f2fe", ButtonText: "#0c4a6e", ButtonBorder: "#7dd3fc"},
+	domain.ThemeSunset:  {Background: "#fff7ed", Text: "#431407", Muted: "#9a3412", Button: "#f97316", ButtonText: "#ffffff", ButtonBorder: "#ea580c"},
+}
+
+var pageFonts = map[string]template.CSS{
+	domain.FontSystem:  `system-ui, -apple-system, "Segoe UI", Roboto, sans-serif`,
+	domain.FontSerif:   `Georgia, "Times New Roman", serif`,
+	domain.FontMono:    `ui-monospace, "SF Mono", Menlo, Consolas, monospace`,
+	domain.FontRounded: `ui-rounded, "SF Pro Rounded", "Nunito", system-ui, sans-serif`,
+}
+
+var buttonRadius = map[string]string{
+	domain.ButtonRounded: "12px",
+	domain.ButtonPill:    "999px",
+	domain.ButtonSquare:  "0",
+	domain.ButtonOutline: "12px",
+}
+
+// newPageStyle applies the appearance settings over the theme. Unknown names (from rows
+// written before validation) fall back to the defaults.
+func newPageStyle(a domain.CollectionAppearance) pageStyle {
+	style, ok := pageThemes[a.Theme]
+	if !ok {
+		style = pageThemes[domain.ThemeDefault]
+	}
+	override := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	override(&style.Background, a.BackgroundColor)
+	override(&style.Text, a.TextColor)
+	override(&style.Button, a.ButtonColor)
+	override(&style.ButtonBorder, a.ButtonColor)
+	override(&style.ButtonText, a.ButtonTextColor)
+
+	if style.Font = pageFonts[a.Font]; style.Font == "" {
+		style.Font = pageFonts[domain.FontSystem]
+	}
+	if style.Radius = buttonRadius[a.ButtonStyle]; style.Radius == "" {
+		style.Radius = buttonRadius[domain.ButtonRounded]
+	}
+	style.Outline = a.ButtonStyle == domain.ButtonOutline
+	style.BackgroundImage = a.BackgroundImageURL
+	style.CustomCSS = template.CSS(domain.SanitizeCSS(a.CustomCSS))
+	return style
+}
+
+// pageItem is a collection item as shown on the public page
+type pageItem struct {
+	Type      string
+	Title     string // Link button text
+	URL       string // Link target, image source
+	Text      string // Heading and text content, image alt text
+	Icon      string // Link icon (emoji)
+	Thumbnail string // Link thumbnail image
+	Highlight bool   // Link is featured
+	Embed     string // Player URL of embeds
+	Socials   []pageSocial
+	Style     domain.ItemStyle
+}
+
+type pageSocial struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// socialLabels are the accessible names of social icons
+var socialLabels = map[string]string{
+	"instagram": "Instagram",
+	"x":         "X",
+	"tiktok":    "TikTok",
+	"youtube":   "YouTube",
+	"facebook":  "Facebook",
+	"linkedin":  "LinkedIn",
+	"github":    "GitHub",
+	"threads":   "Threads",
+	"twitch":    "Twitch",
+	"email":     "Email",
+	"website":   "Website",
+}
+
+func newCollectionPage(collection *domain.Collection, opts pageOptions) collectionPage {
+	page := collectionPage{Collection: collection, Style: newPageStyle(collection.Appearance), Options: opts}
+	for _, item := range collection.Items {
+		pi := pageItem{Type: item.Type, Text: item.Text, URL: item.URL, Style: item.Style}
+		switch item.Type {
+		case domain.ItemLink:
+			if item.Link == nil {
+				continue
+			}
+			pi.Title = item.ButtonText()
+			pi.Icon, pi.Thumbnail, pi.Highlight = item.Icon, item.ThumbnailURL, item.Highlight
+			// Through the short link so the click is counted, and attributed to this item
+			pi.URL = "/open/" + item.Link.ShortCode + "?" + domain.CollectionItemParam + "=" + strconv.FormatInt(item.ID, 10)
+		case domain.ItemEmbed:
+			var ok bool
+			if pi.Embed, ok = domain.EmbedSource(item.URL); !ok {
+				continue
+			}
+		case domain.ItemSocial:
+			for _, profile := range item.Socials {
+				label := socialLabels[profile.Platform]
+				if label == "" {
+					label = profile.Platform
+				}
+				pi.Socials = append(pi.Socials, pageSocial{Label: label, URL: profile.URL})
+			}
+		}
+		page.Items = append(page.Items, pi)
+	}
+	return page
+}
+
+// renderCollectionPage writes the collection as an HTML link-in-bio page. It renders into
+// a buffer first so a template error still produces a clean 500.
+func renderCollectionPage(w http.ResponseWriter, collection *domain.Collection, opts pageOptions) {
+	var buf bytes.Buffer
+	if err := collectionTemplate.Execute(&buf, newCollectionPage(collection, opts)); err != nil {
+		log.Printf("Failed to render collection %q: %v", collection.Slug, err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// wantsJSON reports whether the client asked for JSON instead of a page, with
+// ?format=json or an Accept header preferring application/json over HTML
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestRenderCollectionPage(t *testing.T) {
+	collection := &domain.Collection{
+		Slug:        "jane",
+		Title:       "Jane <Doe>",
+		Description: "Links & things",
+		AvatarURL:   "https://cdn.example.com/jane.png",
+		Items: []domain.CollectionItem{
+			{Type: domain.ItemHeading, Text: "Writing", Style: domain.ItemStyle{Align: "left", TextColor: "#ff0066"}},
+			{ID: 11, Type: domain.ItemLink, Link: &domain.Link{ShortCode: "abc123", Title: "My blog", OriginalURL: "https://blog.example.com/"}},
+			{ID: 12, Type: domain.ItemLink, Link: &domain.Link{ShortCode: "xyz789", OriginalURL: "https://www.shop.example.com/sale"}},
+			{ID: 13, Type: domain.ItemLink, Label: "Shop the drop", Icon: "🔥", ThumbnailURL: "https://cdn.example.com/drop.jpg", Highlight: true,
+				Link: &domain.Link{ShortCode: "drop01", Title: "Spring sale", OriginalURL: "https://shop.example.com/drop"}},
+			{Type: domain.ItemText, Text: "Thanks for visiting"},
+			{Type: domain.ItemImage, URL: "https://cdn.example.com/cover.jpg", Text: "Cover"},
+			{Type: domain.ItemSocial, Socials: []domain.SocialProfile{{Platform: "github", URL: "https://github.com/jane"}}},
+			{Type: domain.ItemEmbed, URL: "https://youtu.be/dQw4w9WgXcQ"},
+			{Type: domain.ItemEmbed, URL: "https://evil.test/player"}, // not a supported provider: skipped
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	renderCollectionPage(rr, collection, pageOptions{})
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"<h1>Jane &lt;Doe&gt;</h1>",
+		"Links &amp; things",
+		`src="https://cdn.example.com/jane.png"`,
+		`<h2 style="color: #ff0066;">Writing</h2>`,
+		`style="text-align: left"`,
+		`<a class="button" href="/open/abc123?ci=11">My blog</a>`,
+		`<a class="button" href="/open/xyz789?ci=12">shop.example.com</a>`, // untitled links show their domain
+		`<a class="button has-thumb highlight" href="/open/drop01?ci=13"><img class="thumb" src="https://cdn.example.com/drop.jpg" alt="" loading="lazy"><span class="icon" aria-hidden="true">🔥</span>Shop the drop</a>`,
+		"<p>Thanks for visiting</p>",
+		`<img src="https://cdn.example.com/cover.jpg" alt="Cover"`,
+		`<a href="https://github.com/jane" title="GitHub" aria-label="GitHub">GitHub</a>`,
+		`<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page is missing %s", want)
+		}
+	}
+	if strings.Index(body, "abc123") > strings.Index(body, "xyz789") {
+		t.Error("links are out of order")
+	}
+	if strings.Contains(body, "evil.test") {
+		t.Error("unsupported embed was rendered")
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		target, accept string
+		want           bool
+	}{
+		{"/u/jane", "", false},
+		{"/u/jane", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/u/jane", "application/json", true},
+		{"/u/jane?format=json", "", true},
+		{"/u/jane?format=html", "application/json", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsJSON(req); got != tt.want {
+			t.Errorf("wantsJSON(%s, Accept %q) = %v, want %v", tt.target, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRenderCollectionPageAppearance(t *testing.T) {
+	collection := &domain.Collection{
+		Slug: "brand",
+		Appearance: domain.CollectionAppearance{
+			Theme:              domain.ThemeDark,
+			ButtonColor:        "#ff0066",
+			Font:               domain.FontSerif,
+			ButtonStyle:        domain.ButtonPill,
+			BackgroundImageURL: "https://cdn.example.com/bg.jpg",
+			CustomCSS:          "h1 { letter-spacing: 2px; }</style><script>alert(1)</script>",
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	renderCollectionPage(rr, collection, pageOptions{})
+	body := rr.Body.String()
+	for _, want := range []string{
+		"--background: #18181b;", // from the dark theme
+		"--button: #ff0066;",
+		"--radius: 999px;",
+		`--font: Georgia, "Times New Roman", serif;`,
+		"background-image: url(https://cdn.example.com/bg.jpg);",
+		"h1 { letter-spacing: 2px; }",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page is missing %s", want)
+		}
+	}
+	if strings.Contains(body, "<script>") || strings.Count(body, "</style>") != 1 {
+		t.Error("custom CSS escaped its style element")
+	}
+}
+
+func TestPageThemesCoverDomainThemes(t *testing.T) {
+	for _, theme := range domain.CollectionThemes {
+		if _, ok := pageThemes[theme]; !ok {
+			t.Errorf("no page theme for %q", theme)
+		}
+	}
+	for _, font := range domain.CollectionFonts {
+		if _, ok := pageFonts[font]; !ok {
+			t.Errorf("no font stack for %q", font)
+		}
+	}
+	for _, style := range domain.ButtonStyles {
+		if _, ok := buttonRadius[style]; !ok {
+			t.Errorf("no radius for button style %q", style)
+		}
+	}
+}
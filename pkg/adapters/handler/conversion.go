@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// transparentGIF is a 1x1 transparent GIF served by the conversion pixel
+var transparentGIF = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff\x21\xf9\x04\x01\x00\x00\x00\x00\x2c\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02\x44\x01\x00\x3b")
+
+type conversionRequest struct {
+	ClickID  string  `json:"click_id"`
+	Event    string  `json:"event"`
+	Revenue  float64 `json:"revenue"`
+	Currency string  `json:"currency"`
+	OrderID  string  `json:"order_id"`
+}
+
+// Postback records a server-to-server conversion (GET or POST /api/v1/public/conversions).
+// Parameters come from the query string, a form body or a JSON body. Responds 201 with the
+// conversion, or 200 when the same click, event and order was already recorded.
+func (h *HTTPHandler) Postback(w http.ResponseWriter, r *http.Request) {
+	var input domain.ConversionInput
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req conversionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		input = domain.ConversionInput(req)
+	} else {
+		var err error
+		if input, err = conversionInput(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	conversion, created, err := h.service.RecordConversion(r.Context(), input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(conversion)
+}
+
+// Pixel records a conversion from an <img> on the destination page
+// (GET /api/v1/public/conversions/pixel.gif). It always answers with the image, so a
+// bad or repeated request never shows a broken image.
+func (h *HTTPHandler) Pixel(w http.ResponseWriter, r *http.Request) {
+	if input, err := conversionInput(r); err == nil {
+		_, _, _ = h.service.RecordConversion(r.Context(), input)
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+	w.Write(transparentGIF)
+}
+
+// conversionInput reads conversion parameters from the query string and form body
+func conversionInput(r *http.Request) (domain.ConversionInput, error) {
+	if err := r.ParseForm(); err != nil {
+		return domain.ConversionInput{}, err
+	}
+	input := domain.ConversionInput{
+		ClickID:  r.Form.Get("click_id"),
+		Event:    r.Form.Get("event"),
+		Currency: r.Form.Get("currency"),
+		OrderID:  r.Form.Get("order_id"),
+	}
+	if revenue := r.Form.Get("revenue"); revenue != "" {
+		var err error
+		if input.Revenue, err = strconv.ParseFloat(revenue, 64); err != nil {
+			return input, errors.New("Invalid revenue")
+		}
+	}
+	return input, nil
+}
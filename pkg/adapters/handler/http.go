@@ -1,21 +1,25 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
 )
 
 type HTTPHandler struct {
-	service ports.LinkService
+	service  ports.LinkService
+	clientIP *ClientIPResolver
 }
 
-func NewHTTPHandler(service ports.LinkService) *HTTPHandler {
-	return &HTTPHandler{service: service}
+func NewHTTPHandler(service ports.LinkService, clientIP *ClientIPResolver) *HTTPHandler {
+	return &HTTPHandler{service: service, clientIP: clientIP}
 }
 
 // CreateLinkRequest payload
@@ -66,25 +70,37 @@ func (h *HTTPHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	originalURL, err := h.service.GetOriginalURL(r.Context(), code)
+	link, err := h.service.GetLinkByShortCode(r.Context(), code)
 	if err != nil {
 		http.Error(w, "Link not found", http.StatusNotFound)
 		return
 	}
 
-	// Async track visit (only if query param "no_stat" is not set)
+	// Track visit (only if query param "no_stat" is not set). With a visit writer this only
+	// enqueues; a full queue drops the visit rather than delaying the redirect.
+	var clickID string
 	if r.URL.Query().Get("no_stat") == "" {
-		go func() {
-			// Use background context as request context will be cancelled
-			// Getting IP/UserAgent
-			referer := r.Header.Get("Referer")
-			userAgent := r.UserAgent()
-			ip := r.RemoteAddr // naive
-			_ = h.service.RecordVisit(context.Background(), code, referer, userAgent, ip)
-		}()
+		input := domain.VisitInput{
+			Referer:   r.Header.Get("Referer"),
+			UserAgent: r.UserAgent(),
+			IP:        h.clientIP.ClientIP(r),
+			Query:     firstValues(r.URL.Query()),
+		}
+		clickID, _ = h.service.RecordLinkVisit(r.Context(), link, input)
 	}
 
-	http.Redirect(w, r, originalURL, http.StatusFound)
+	http.Redirect(w, r, h.service.DestinationURL(link, clickID), http.StatusFound)
+}
+
+// firstValues flattens query values, keeping the first value of each key
+func firstValues(values url.Values) map[string]string {
+	flat := make(map[string]string, len(values))
+	for key, vals := range values {
+		if len(vals) > 0 {
+			flat[key] = vals[0]
+		}
+	}
+	return flat
 }
 
 // Get Public Link (without redirect, for metadata resolution)
@@ -118,30 +134,55 @@ func (h *HTTPHandler) Track(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	referer := r.Header.Get("Referer")
+	input := domain.VisitInput{
+		Referer:   r.Header.Get("Referer"),
+		UserAgent: r.UserAgent(),
+		IP:        h.clientIP.ClientIP(r),
+		Query:     map[string]string{},
+	}
 
-	// if body has custom_ref, use it instead of referer
+	// if body has custom_ref, use it instead of referer.
+	// Other string fields (utm_source, ...) are passed on as visit parameters.
 	var body map[string]interface{}
 	// Ignore decode error as body might be empty
 	_ = json.NewDecoder(r.Body).Decode(&body)
-	if customRef, ok := body["custom_ref"]; ok {
-		if strRef, ok := customRef.(string); ok && strRef != "" {
-			referer = strRef
+	for key, value := range body {
+		strValue, ok := value.(string)
+		if !ok || strValue == "" {
+			continue
 		}
+		if key == "custom_ref" {
+			input.Referer = strValue
+			continue
+		}
+		input.Query[key] = strValue
 	}
 
-	// Async or Sync tracking
-	userAgent := r.UserAgent()
-	ip := r.RemoteAddr
+	link, err := h.service.GetLinkByShortCode(r.Context(), code)
+	if err != nil {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
 
-	if err := h.service.RecordVisit(r.Context(), code, referer, userAgent, ip); err != nil {
+	clickID, err := h.service.RecordLinkVisit(r.Context(), link, input)
+	if err != nil {
+		if errors.Is(err, domain.ErrVisitQueueFull) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		// Just log error or ignore, don't break flow?
 		// For now return error to client so they know
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// The caller redirects to url, which carries the click ID for conversion tracking
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted) // 202 Accepted
+	json.NewEncoder(w).Encode(map[string]string{
+		"click_id": clickID,
+		"url":      h.service.DestinationURL(link, clickID),
+	})
 }
 
 // Get Stats for a Link
@@ -159,6 +200,49 @@ func (h *HTTPHandler) Stats(w http.ResponseWriter, r *http.Request) {
 	if ref := query.Get("referer"); ref != "" {
 		filters["referer"] = ref
 	}
+	if country := query.Get("country"); country != "" {
+		filters["country"] = strings.ToUpper(country)
+	}
+	for _, key := range []string{"utm_source", "utm_medium", "utm_campaign"} {
+		if value := query.Get(key); value != "" {
+			filters[key] = value
+		}
+	}
+	if top, err := strconv.Atoi(query.Get("top")); err == nil && top > 0 {
+		// Size of referrer and location breakdowns (default 10)
+		filters["top"] = min(top, 100)
+	}
+
+	// Bucket boundaries (and year/month/day, from/to dates) are interpreted in tz
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, "Invalid tz", http.StatusBadRequest)
+			return
+		}
+	}
+	filters["location"] = loc
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = domain.GranularityDay
+	}
+	switch granularity {
+	case domain.GranularityHour, domain.GranularityDay, domain.GranularityWeek, domain.GranularityMonth:
+	default:
+		http.Error(w, "Invalid granularity (hour, day, week, month)", http.StatusBadRequest)
+		return
+	}
+	filters["granularity"] = granularity
+
+	switch query.Get("compare") {
+	case "", "false", "0":
+	case "previous", "true", "1":
+		filters["compare"] = true
+	default:
+		http.Error(w, "Invalid compare (previous)", http.StatusBadRequest)
+		return
+	}
 
 	if yearStr := query.Get("year"); yearStr != "" {
 		year, _ := strconv.Atoi(yearStr)
@@ -172,7 +256,7 @@ func (h *HTTPHandler) Stats(w http.ResponseWriter, r *http.Request) {
 			day, _ = strconv.Atoi(d)
 		}
 
-		start := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		start := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
 		var end time.Time
 
 		if query.Get("day") != "" {
@@ -187,8 +271,31 @@ func (h *HTTPHandler) Stats(w http.ResponseWriter, r *http.Request) {
 		filters["end_date"] = end
 	}
 
+	// from/to take precedence over year/month/day
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, err := parseTimeParam(fromStr, loc)
+		if err != nil {
+			http.Error(w, "Invalid from (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		filters["start_date"] = from
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		to, err := parseTimeParam(toStr, loc)
+		if err != nil {
+			http.Error(w, "Invalid to (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		filters["end_date"] = to
+	}
+
+	// The range, with defaults resolved, is checked by the service
 	stats, err := h.service.GetLinkStats(r.Context(), id, filters)
 	if err != nil {
+		if errors.Is(err, domain.ErrStatsRangeOrder) || errors.Is(err, domain.ErrStatsRangeTooLarge) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -196,6 +303,14 @@ func (h *HTTPHandler) Stats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// parseTimeParam accepts an RFC 3339 timestamp, or a date (midnight in loc)
+func parseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
 // Get Dashboard
 func (h *HTTPHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
@@ -209,10 +324,94 @@ func (h *HTTPHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	geo, err := h.service.GetDashboardGeo(r.Context(), search, tag, domainFilter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	resp := map[string]interface{}{
 		"top_links":           links,
 		"total_system_clicks": total,
+		"geo":                 geo,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// aggregateGroupings maps the aggregate stats paths to their grouping
+var aggregateGroupings = map[string]string{
+	"tags":    domain.AggregateByTag,
+	"domains": domain.AggregateByDomain,
+	"months":  domain.AggregateByMonth,
+}
+
+// ClickAggregates totals clicks per tag, destination domain or creation month
+// (GET /api/v1/stats/{grouping}) over the links matching the dashboard filters
+func (h *HTTPHandler) ClickAggregates(w http.ResponseWriter, r *http.Request) {
+	groupBy, ok := aggregateGroupings[r.PathValue("grouping")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	filters := map[string]interface{}{
+		"search": query.Get("search"),
+		"tag":    query.Get("tag"),
+		"domain": query.Get("domain"),
+	}
+
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, "Invalid tz", http.StatusBadRequest)
+			return
+		}
+	}
+	filters["location"] = loc
+
+	var from, to time.Time
+	if fromStr := query.Get("from"); fromStr != "" {
+		var err error
+		if from, err = parseTimeParam(fromStr, loc); err != nil {
+			http.Error(w, "Invalid from (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		filters["start_date"] = from
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		var err error
+		if to, err = parseTimeParam(toStr, loc); err != nil {
+			http.Error(w, "Invalid to (RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		filters["end_date"] = to
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	aggregates, total, err := h.service.GetClickAggregates(r.Context(), groupBy, limit, filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"group_by":     groupBy,
+		"data":         aggregates,
+		"total_clicks": total,
+	}
+	if !from.IsZero() {
+		resp["from"] = from
+	}
+	if !to.IsZero() {
+		resp["to"] = to
 	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
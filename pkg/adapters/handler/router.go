@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/config"
@@ -9,10 +10,20 @@ import (
 )
 
 // NewRouter creates and configures the main application router
-func NewRouter(cfg *config.Config, service ports.LinkService, collectionService ports.CollectionService) http.Handler {
+func NewRouter(cfg *config.Config, service ports.LinkService, collectionService ports.CollectionService, webhookService ports.WebhookService, alertService ports.AlertService) http.Handler {
+	// Resolve client IPs behind trusted proxies (Vercel, load balancer).
+	// An invalid list falls back to trusting no proxy at all.
+	clientIP, err := NewClientIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		log.Printf("Ignoring TRUSTED_PROXIES: %v", err)
+		clientIP = nil
+	}
+
 	// Initialize Handlers
-	h := NewHTTPHandler(service)
-	ch := NewCollectionHandler(collectionService)
+	h := NewHTTPHandler(service, clientIP)
+	ch := NewCollectionHandler(collectionService, cfg, clientIP)
+	wh := NewWebhookHandler(webhookService)
+	ah := NewAlertHandler(alertService)
 
 	// Initialize Middleware
 	mw := NewMiddleware(cfg)
@@ -25,37 +36,78 @@ func NewRouter(cfg *config.Config, service ports.LinkService, collectionService
 
 	// Public Routes
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
-		res := map[string]string{
+		res := map[string]interface{}{
 			"message": "ok",
 		}
+		if queue := service.VisitQueueStats(); queue != nil {
+			res["visit_queue"] = queue
+		}
 		_ = json.NewEncoder(w).Encode(&res)
 		w.WriteHeader(http.StatusOK)
 	})
 	mux.HandleFunc("GET /open/{short_code}", h.Redirect)
 	mux.HandleFunc("GET /u/{slug}", ch.GetPublicCollection)
+	mux.HandleFunc("POST /u/{slug}", ch.UnlockCollection)
+	mux.HandleFunc("GET /u/{slug}/{feed}", ch.CollectionFeed)
+	mux.HandleFunc("GET /u/{slug}/embed", ch.EmbedCollection)
+	mux.HandleFunc("GET /embed.js", ch.EmbedScript)
+	mux.HandleFunc("GET /oembed", ch.OEmbed)
 	mux.HandleFunc("GET /auth/google/login", authHandler.Login)
 	mux.HandleFunc("GET /auth/google/callback", authHandler.Callback)
 	mux.HandleFunc("GET /auth/logout", authHandler.Logout)
 	mux.HandleFunc("GET /api/v1/public/links/{short_code}", h.GetPublicByShortCode)
+	mux.HandleFunc("GET /api/v1/public/collections/{slug}", ch.CollectionWidget)
 	mux.HandleFunc("POST /api/v1/public/links/{short_code}/track", h.Track)
+	mux.HandleFunc("GET /api/v1/public/conversions", h.Postback)
+	mux.HandleFunc("POST /api/v1/public/conversions", h.Postback)
+	mux.HandleFunc("GET /api/v1/public/conversions/pixel.gif", h.Pixel)
 
 	// Protected Routes (API & Dashboard)
 	protectedMux := http.NewServeMux()
 	protectedMux.HandleFunc("POST /api/v1/links", h.Create)
 	protectedMux.HandleFunc("GET /api/v1/links", h.List)
 	protectedMux.HandleFunc("GET /api/v1/links/{id}/stats", h.Stats)
+	protectedMux.HandleFunc("GET /api/v1/links/{id}/visits", h.ListLinkVisits)
+	protectedMux.HandleFunc("GET /api/v1/visits", h.ExportVisits)
+	protectedMux.HandleFunc("GET /api/v1/stream/visits", h.StreamVisits)
 	protectedMux.HandleFunc("GET /api/v1/dashboard", h.Dashboard)
+	protectedMux.HandleFunc("GET /api/v1/stats/{grouping}", h.ClickAggregates)
 	protectedMux.HandleFunc("PUT /api/v1/links/{id}", h.Update)
 	protectedMux.HandleFunc("DELETE /api/v1/links/{id}", h.Delete)
 
 	// Collection Routes
 	protectedMux.HandleFunc("POST /api/v1/collections", ch.CreateCollection)
 	protectedMux.HandleFunc("GET /api/v1/collections", ch.ListCollections)
+	protectedMux.HandleFunc("POST /api/v1/collections/import", ch.ImportCollection)
 	protectedMux.HandleFunc("GET /api/v1/collections/{id}", ch.GetCollection)
 	protectedMux.HandleFunc("PUT /api/v1/collections/{id}", ch.UpdateCollection)
 	protectedMux.HandleFunc("DELETE /api/v1/collections/{id}", ch.DeleteCollection)
+	protectedMux.HandleFunc("POST /api/v1/collections/{id}/preview", ch.CreatePreviewLink)
+	protectedMux.HandleFunc("POST /api/v1/collections/{id}/clone", ch.CloneCollection)
+	protectedMux.HandleFunc("GET /api/v1/collections/{id}/export", ch.ExportCollection)
+	protectedMux.HandleFunc("GET /api/v1/collections/{id}/stats", ch.CollectionStats)
 	protectedMux.HandleFunc("POST /api/v1/collections/{id}/links", ch.AddLink)
+	protectedMux.HandleFunc("PUT /api/v1/collections/{id}/links/order", ch.ReorderLinks)
 	protectedMux.HandleFunc("DELETE /api/v1/collections/{id}/links/{linkID}", ch.RemoveLink)
+	protectedMux.HandleFunc("POST /api/v1/collections/{id}/items", ch.AddItem)
+	protectedMux.HandleFunc("PUT /api/v1/collections/{id}/items/order", ch.ReorderItems)
+	protectedMux.HandleFunc("PUT /api/v1/collections/{id}/items/{itemID}", ch.UpdateItem)
+	protectedMux.HandleFunc("DELETE /api/v1/collections/{id}/items/{itemID}", ch.RemoveItem)
+
+	// Webhook Routes
+	protectedMux.HandleFunc("POST /api/v1/webhooks", wh.CreateWebhook)
+	protectedMux.HandleFunc("GET /api/v1/webhooks", wh.ListWebhooks)
+	protectedMux.HandleFunc("GET /api/v1/webhooks/{id}", wh.GetWebhook)
+	protectedMux.HandleFunc("PUT /api/v1/webhooks/{id}", wh.UpdateWebhook)
+	protectedMux.HandleFunc("DELETE /api/v1/webhooks/{id}", wh.DeleteWebhook)
+	protectedMux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", wh.ListDeliveries)
+
+	// Alert Routes
+	protectedMux.HandleFunc("POST /api/v1/alerts", ah.CreateAlertRule)
+	protectedMux.HandleFunc("GET /api/v1/alerts", ah.ListAlertRules)
+	protectedMux.HandleFunc("GET /api/v1/alerts/{id}", ah.GetAlertRule)
+	protectedMux.HandleFunc("PUT /api/v1/alerts/{id}", ah.UpdateAlertRule)
+	protectedMux.HandleFunc("DELETE /api/v1/alerts/{id}", ah.DeleteAlertRule)
 
 	// Apply Middleware to Protected Routes
 	// Note: We match /api/v1/ to capture all API requests.
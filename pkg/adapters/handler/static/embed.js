@@ -0,0 +1,52 @@
+// Embeds collection pages: every <div data-collection="slug"> becomes an iframe of
+// /u/{slug}/embed that grows with its content. Optional attributes: data-layout
+// (list, compact, grid), data-header="0" to hide the header, data-max-width.
+(function () {
+	var script = document.currentScript;
+	if (!script) {
+		return;
+	}
+	var origin = new URL(script.src).origin;
+
+	function mount(el) {
+		if (el.getAttribute("data-collection-mounted")) {
+			return;
+		}
+		el.setAttribute("data-collection-mounted", "1");
+
+		var slug = el.getAttribute("data-collection");
+		var params = new URLSearchParams();
+		var layout = el.getAttribute("data-layout");
+		if (layout) {
+			params.set("layout", layout);
+		}
+		if (el.getAttribute("data-header") === "0") {
+			params.set("header", "0");
+		}
+		var query = params.toString();
+
+		var frame = document.createElement("iframe");
+		frame.src = origin + "/u/" + encodeURIComponent(slug) + "/embed" + (query ? "?" + query : "");
+		frame.title = el.getAttribute("data-title") || slug;
+		frame.loading = "lazy";
+		frame.style.width = "100%";
+		frame.style.maxWidth = el.getAttribute("data-max-width") || "480px";
+		frame.style.height = "600px";
+		frame.style.border = "0";
+		el.appendChild(frame);
+	}
+
+	document.querySelectorAll("[data-collection]").forEach(mount);
+
+	window.addEventListener("message", function (event) {
+		var data = event.data;
+		if (event.origin !== origin || !data || data.type !== "collection-embed" || typeof data.height !== "number") {
+			return;
+		}
+		document.querySelectorAll("[data-collection] iframe").forEach(function (frame) {
+			if (frame.contentWindow === event.source) {
+				frame.style.height = Math.ceil(data.height) + "px";
+			}
+		});
+	});
+})();
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamHeartbeat keeps idle SSE connections open through proxies
+const streamHeartbeat = 15 * time.Second
+
+// StreamVisits pushes live visits as Server-Sent Events (GET /api/v1/stream/visits).
+// Optional link_id and tag narrow the stream; Last-Event-ID (header, or last_event_id
+// query param) replays recent events missed while disconnected.
+func (h *HTTPHandler) StreamVisits(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var linkID int64
+	if idStr := query.Get("link_id"); idStr != "" {
+		var err error
+		if linkID, err = strconv.ParseInt(idStr, 10, 64); err != nil {
+			http.Error(w, "Invalid link_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	lastID, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	replay, events, err := h.service.SubscribeVisits(r.Context(), lastID, linkID, query.Get("tag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+
+	// Ask EventSource to reconnect quickly after a dropped connection
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, event := range replay {
+		if writeSSE(w, event.ID, "visit", event) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Fell behind or server shutting down; the client reconnects and resumes
+				return
+			}
+			if writeSSE(w, event.ID, "visit", event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes one event; data is JSON-encoded on a single line
+func writeSSE(w http.ResponseWriter, id int64, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, payload)
+	return err
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// visitCSVHeader is the column order of CSV visit exports
+var visitCSVHeader = []string{
+	"id", "link_id", "created_at", "referer", "referer_domain", "referer_channel", "user_agent", "ip_hash",
+	"country", "region", "city", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "params", "click_id",
+}
+
+// ListLinkVisits returns the raw visits of one link (GET /api/v1/links/{id}/visits)
+func (h *HTTPHandler) ListLinkVisits(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	filters, cursor, err := visitFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters["link_id"] = id
+
+	h.writeVisits(w, r, filters, cursor, fmt.Sprintf("visits-link-%d", id))
+}
+
+// ExportVisits returns raw visits across all links (GET /api/v1/visits), optionally limited to a tag
+func (h *HTTPHandler) ExportVisits(w http.ResponseWriter, r *http.Request) {
+	filters, cursor, err := visitFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filters["tag"] = tag
+	}
+
+	h.writeVisits(w, r, filters, cursor, "visits")
+}
+
+// visitFilters parses from/to (RFC 3339, or dates in tz) and the cursor
+func visitFilters(r *http.Request) (map[string]interface{}, int64, error) {
+	filters := make(map[string]interface{})
+	query := r.URL.Query()
+
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, 0, errors.New("Invalid tz")
+		}
+	}
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, err := parseTimeParam(fromStr, loc)
+		if err != nil {
+			return nil, 0, errors.New("Invalid from (RFC 3339 or YYYY-MM-DD)")
+		}
+		filters["start_date"] = from
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		to, err := parseTimeParam(toStr, loc)
+		if err != nil {
+			return nil, 0, errors.New("Invalid to (RFC 3339 or YYYY-MM-DD)")
+		}
+		filters["end_date"] = to
+	}
+
+	var cursor int64
+	if c := query.Get("cursor"); c != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(c, 10, 64); err != nil || cursor < 0 {
+			return nil, 0, errors.New("Invalid cursor")
+		}
+	}
+	return filters, cursor, nil
+}
+
+// writeVisits responds with one JSON page (default), or streams every visit from the
+// cursor on as CSV or NDJSON. When the range reaches into pruned days, the
+// X-Visits-Pruned-Until header (and pruned_until in JSON) tells where raw visits start.
+func (h *HTTPHandler) writeVisits(w http.ResponseWriter, r *http.Request, filters map[string]interface{}, cursor int64, filename string) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" && format != "ndjson" {
+		http.Error(w, "Invalid format (json, csv or ndjson)", http.StatusBadRequest)
+		return
+	}
+
+	// Visits before the pruning watermark are only in the archive
+	prunedUntil, err := h.service.VisitsPrunedUntil(r.Context(), filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !prunedUntil.IsZero() {
+		w.Header().Set("X-Visits-Pruned-Until", prunedUntil.Format(time.RFC3339))
+	}
+
+	if format == "" || format == "json" {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		visits, next, err := h.service.ListVisits(r.Context(), filters, cursor, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"data":        visits,
+			"next_cursor": "",
+		}
+		if next > 0 {
+			resp["next_cursor"] = strconv.FormatInt(next, 10)
+		}
+		if !prunedUntil.IsZero() {
+			resp["pruned_until"] = prunedUntil
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Exports can outlast the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		_ = cw.Write(visitCSVHeader)
+		err = h.service.ExportVisits(r.Context(), filters, cursor, func(v *domain.Visit) error {
+			return cw.Write(visitCSVRecord(v))
+		})
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		err = h.service.ExportVisits(r.Context(), filters, cursor, func(v *domain.Visit) error {
+			return enc.Encode(v)
+		})
+	}
+
+	// Headers are already sent; the client sees a truncated body
+	if err != nil {
+		log.Printf("Visit export aborted: %v", err)
+	}
+}
+
+func visitCSVRecord(v *domain.Visit) []string {
+	params := ""
+	if len(v.Params) > 0 {
+		b, _ := json.Marshal(v.Params)
+		params = string(b)
+	}
+	record := []string{
+		strconv.FormatInt(v.ID, 10), strconv.FormatInt(v.LinkID, 10), v.CreatedAt.UTC().Format(time.RFC3339),
+		v.Referer, v.RefererDomain, v.RefererChannel, v.UserAgent, v.IPHash,
+		v.Country, v.Region, v.City, v.UTMSource, v.UTMMedium, v.UTMCampaign, v.UTMTerm, v.UTMContent, params, v.ClickID,
+	}
+	for i, cell := range record {
+		record[i] = csvSafe(cell)
+	}
+	return record
+}
+
+// csvSafe keeps spreadsheets from evaluating visitor-controlled cells (referers, UTM
+// values, ...) as formulas by prefixing those that start like one with a quote
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// visitService serves a fixed list of visits
+type visitService struct {
+	ports.LinkService
+	visits      []domain.Visit
+	prunedUntil time.Time
+}
+
+func (s *visitService) ExportVisits(ctx context.Context, filters map[string]interface{}, cursor int64, fn func(*domain.Visit) error) error {
+	for i := range s.visits {
+		if err := fn(&s.visits[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *visitService) VisitsPrunedUntil(ctx context.Context, filters map[string]interface{}) (time.Time, error) {
+	return s.prunedUntil, nil
+}
+
+func TestVisitExportFormats(t *testing.T) {
+	at := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	svc := &visitService{
+		visits: []domain.Visit{
+			{ID: 1, LinkID: 7, Referer: "https://a.example/", UTMSource: "news", CreatedAt: at},
+			{ID: 2, LinkID: 7, Referer: "=HYPERLINK(\"https://evil.example\")", UserAgent: "@SUM(1)", UTMCampaign: "-2+3",
+				UTMTerm: "+1", UTMContent: "\tx", Params: map[string]string{"ref": "x"}, CreatedAt: at},
+		},
+		prunedUntil: at.AddDate(0, 0, -1),
+	}
+	h := NewHTTPHandler(svc, nil)
+	export := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/links/7/visits?format="+format, nil)
+		req.SetPathValue("id", "7")
+		w := httptest.NewRecorder()
+		h.ListLinkVisits(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s export: status %d: %s", format, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Visits-Pruned-Until"); got != "2026-03-31T10:00:00Z" {
+			t.Errorf("%s export: X-Visits-Pruned-Until = %q", format, got)
+		}
+		return w
+	}
+
+	records, err := csv.NewReader(export("csv").Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || len(records[0]) != len(visitCSVHeader) {
+		t.Fatalf("CSV = %v, want a header and two rows", records)
+	}
+	row := make(map[string]string)
+	for i, column := range visitCSVHeader {
+		row[column] = records[2][i]
+	}
+	want := map[string]string{
+		"id": "2", "created_at": "2026-04-01T10:00:00Z", "referer": "'=HYPERLINK(\"https://evil.example\")", "user_agent": "'@SUM(1)",
+		"utm_campaign": "'-2+3", "utm_term": "'+1", "utm_content": "'\tx", "params": `{"ref":"x"}`,
+	}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("CSV %s = %q, want %q", column, row[column], value)
+		}
+	}
+	if records[1][3] != "https://a.example/" {
+		t.Errorf("plain referer = %q, want it unchanged", records[1][3])
+	}
+
+	scanner := bufio.NewScanner(export("ndjson").Body)
+	var lines []domain.Visit
+	for scanner.Scan() {
+		var v domain.Visit
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, v)
+	}
+	// NDJSON keeps values as they are
+	if len(lines) != 2 || lines[1].Referer != svc.visits[1].Referer || lines[1].Params["ref"] != "x" {
+		t.Errorf("NDJSON = %+v", lines)
+	}
+}
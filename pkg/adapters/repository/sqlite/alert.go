@@ -0,0 +1,311 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func migrateAlerts(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS alert_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL DEFAULT '',
+		link_id INTEGER NOT NULL DEFAULT 0, -- 0 for every link
+		type TEXT NOT NULL,
+		threshold INTEGER NOT NULL DEFAULT 0,
+		change_percent REAL NOT NULL DEFAULT 0,
+		direction TEXT NOT NULL DEFAULT '',
+		window_hours INTEGER NOT NULL DEFAULT 0,
+		baseline_windows INTEGER NOT NULL DEFAULT 0,
+		channels JSON NOT NULL,
+		emails JSON NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		last_triggered_at TEXT,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	-- Links a rule is currently firing for, so each crossing notifies once
+	CREATE TABLE IF NOT EXISTS alert_firing (
+		rule_id INTEGER NOT NULL,
+		link_id INTEGER NOT NULL,
+		triggered_at TEXT NOT NULL,
+		PRIMARY KEY (rule_id, link_id),
+		FOREIGN KEY(rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE
+	);
+
+	-- Global rules count recent visits across all links
+	CREATE INDEX IF NOT EXISTS idx_visits_created ON visits(created_at);
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+const alertRuleColumns = `id, name, link_id, type, threshold, change_percent, direction, window_hours, baseline_windows,
+	channels, emails, active, last_triggered_at, created_at, updated_at`
+
+func scanAlertRule(row interface{ Scan(...interface{}) error }) (*domain.AlertRule, error) {
+	var a domain.AlertRule
+	var channelsJSON, emailsJSON []byte
+	var lastTriggered sql.NullString
+	var createdAt, updatedAt string
+	if err := row.Scan(&a.ID, &a.Name, &a.LinkID, &a.Type, &a.Threshold, &a.ChangePercent, &a.Direction, &a.WindowHours,
+		&a.BaselineWindows, &channelsJSON, &emailsJSON, &a.Active, &lastTriggered, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal(channelsJSON, &a.Channels)
+	_ = json.Unmarshal(emailsJSON, &a.Emails)
+	if lastTriggered.Valid {
+		t, _ := time.ParseInLocation(sqliteTimeLayout, lastTriggered.String, time.UTC)
+		a.LastTriggeredAt = &t
+	}
+	a.CreatedAt, _ = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	a.UpdatedAt, _ = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC)
+	return &a, nil
+}
+
+func (r *SQLiteRepository) CreateAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	channelsJSON, err := json.Marshal(rule.Channels)
+	if err != nil {
+		return err
+	}
+	emailsJSON, err := json.Marshal(rule.Emails)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, `INSERT INTO alert_rules (name, link_id, type, threshold, change_percent, direction, window_hours,
+		baseline_windows, channels, emails, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Name, rule.LinkID, rule.Type, rule.Threshold, rule.ChangePercent, rule.Direction, rule.WindowHours,
+		rule.BaselineWindows, channelsJSON, emailsJSON, rule.Active,
+		rule.CreatedAt.UTC().Format(sqliteTimeLayout), rule.UpdatedAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return err
+	}
+	rule.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r *SQLiteRepository) GetAlertRule(ctx context.Context, id int64) (*domain.AlertRule, error) {
+	a, err := scanAlertRule(r.db.QueryRowContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListAlertRules supports the filters "active" (bool) and "link_id" (int64)
+func (r *SQLiteRepository) ListAlertRules(ctx context.Context, filters map[string]interface{}) ([]domain.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE 1=1`
+	args := []interface{}{}
+	if active, ok := filters["active"].(bool); ok {
+		query += " AND active = ?"
+		args = append(args, active)
+	}
+	if linkID, ok := filters["link_id"].(int64); ok {
+		query += " AND link_id = ?"
+		args = append(args, linkID)
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []domain.AlertRule{}
+	for rows.Next() {
+		a, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *a)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateAlertRule replaces a rule's settings. Its firing state is reset, so links
+// matching the new settings notify again.
+func (r *SQLiteRepository) UpdateAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	channelsJSON, err := json.Marshal(rule.Channels)
+	if err != nil {
+		return err
+	}
+	emailsJSON, err := json.Marshal(rule.Emails)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE alert_rules SET name = ?, link_id = ?, type = ?, threshold = ?, change_percent = ?, direction = ?,
+		window_hours = ?, baseline_windows = ?, channels = ?, emails = ?, active = ?, updated_at = ? WHERE id = ?`,
+		rule.Name, rule.LinkID, rule.Type, rule.Threshold, rule.ChangePercent, rule.Direction, rule.WindowHours,
+		rule.BaselineWindows, channelsJSON, emailsJSON, rule.Active, rule.UpdatedAt.UTC().Format(sqliteTimeLayout), rule.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alert_firing WHERE rule_id = ?`, rule.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAlertRule removes the rule together with its firing state
+func (r *SQLiteRepository) DeleteAlertRule(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alert_firing WHERE rule_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListFiringAlerts returns the links a rule is currently firing for, with when it fired
+func (r *SQLiteRepository) ListFiringAlerts(ctx context.Context, ruleID int64) (map[int64]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT link_id, triggered_at FROM alert_firing WHERE rule_id = ?`, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	firing := make(map[int64]time.Time)
+	for rows.Next() {
+		var linkID int64
+		var triggeredAt string
+		if err := rows.Scan(&linkID, &triggeredAt); err != nil {
+			return nil, err
+		}
+		firing[linkID], _ = time.ParseInLocation(sqliteTimeLayout, triggeredAt, time.UTC)
+	}
+	return firing, rows.Err()
+}
+
+// SetAlertFiring marks a rule as firing for a link and records the rule's last trigger time
+func (r *SQLiteRepository) SetAlertFiring(ctx context.Context, ruleID, linkID int64, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	atStr := at.UTC().Format(sqliteTimeLayout)
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO alert_firing (rule_id, link_id, triggered_at) VALUES (?, ?, ?)`,
+		ruleID, linkID, atStr); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE alert_rules SET last_triggered_at = ? WHERE id = ?`, atStr, ruleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClearAlertFiring re-arms a rule for a link once its condition no longer holds
+func (r *SQLiteRepository) ClearAlertFiring(ctx context.Context, ruleID, linkID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM alert_firing WHERE rule_id = ? AND link_id = ?`, ruleID, linkID)
+	return err
+}
+
+// LinkClickTotals returns lifetime clicks of one link, or of every link when linkID is 0.
+// Deleted links are left out.
+func (r *SQLiteRepository) LinkClickTotals(ctx context.Context, linkID int64) (map[int64]int64, error) {
+	query := `SELECT id, COALESCE(clicks, 0) FROM links WHERE deleted_at IS NULL`
+	args := []interface{}{}
+	if linkID > 0 {
+		query += " AND id = ?"
+		args = append(args, linkID)
+	}
+	return r.queryLinkCounts(ctx, query, args...)
+}
+
+// CountClicksByLink returns clicks per link in [from, to), for one link or every link
+// when linkID is 0. A zero from or to leaves that end open. Links without clicks are
+// left out. Whole hours before the compaction watermark come from hourly rollups, since
+// raw visits there may have been pruned.
+func (r *SQLiteRepository) CountClicksByLink(ctx context.Context, linkID int64, from, to time.Time) (map[int64]int64, error) {
+	watermark, err := r.CompactedUntil(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rollupStart, rollupEnd := ceilHour(from), watermark
+	if !to.IsZero() && to.Truncate(time.Hour).Before(rollupEnd) {
+		rollupEnd = to.Truncate(time.Hour)
+	}
+	useRollup := rollupStart.Before(rollupEnd)
+
+	query := `SELECT v.link_id AS link_id, COUNT(*) AS c FROM visits v JOIN links l ON l.id = v.link_id WHERE l.deleted_at IS NULL`
+	args := []interface{}{}
+	if !from.IsZero() {
+		query += " AND v.created_at >= ?"
+		args = append(args, from.UTC().Format(sqliteTimeLayout))
+	}
+	if !to.IsZero() {
+		query += " AND v.created_at < ?"
+		args = append(args, to.UTC().Format(sqliteTimeLayout))
+	}
+	if useRollup {
+		query += " AND (v.created_at < ? OR v.created_at >= ?)"
+		args = append(args, rollupStart.UTC().Format(sqliteTimeLayout), rollupEnd.UTC().Format(sqliteTimeLayout))
+	}
+	if linkID > 0 {
+		query += " AND v.link_id = ?"
+		args = append(args, linkID)
+	}
+	query += " GROUP BY v.link_id"
+
+	if useRollup {
+		query += ` UNION ALL SELECT ru.link_id AS link_id, SUM(ru.clicks) AS c FROM visit_rollups_hourly ru JOIN links l ON l.id = ru.link_id
+			WHERE l.deleted_at IS NULL AND ru.bucket >= ? AND ru.bucket < ?`
+		args = append(args, rollupStart.UTC().Format(sqliteTimeLayout), rollupEnd.UTC().Format(sqliteTimeLayout))
+		if linkID > 0 {
+			query += " AND ru.link_id = ?"
+			args = append(args, linkID)
+		}
+		query += " GROUP BY ru.link_id"
+	}
+
+	return r.queryLinkCounts(ctx, `SELECT link_id, SUM(c) FROM (`+query+`) GROUP BY link_id`, args...)
+}
+
+func (r *SQLiteRepository) queryLinkCounts(ctx context.Context, query string, args ...interface{}) (map[int64]int64, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		var linkID, count int64
+		if err := rows.Scan(&linkID, &count); err != nil {
+			return nil, err
+		}
+		counts[linkID] = count
+	}
+	return counts, rows.Err()
+}
+
+func ceilHour(t time.Time) time.Time {
+	hour := t.Truncate(time.Hour)
+	if hour.Equal(t) {
+		return hour
+	}
+	return hour.Add(time.Hour)
+}
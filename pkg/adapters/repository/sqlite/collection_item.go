@@ -0,0 +1,354 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func migrateCollectionItems(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS collection_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		link_id INTEGER, -- link items only
+		content JSON NOT NULL DEFAULT '{}', -- text, url, socials
+		style JSON NOT NULL DEFAULT '{}',
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+		FOREIGN KEY(link_id) REFERENCES links(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_collection_items_order ON collection_items(collection_id, sort_order);
+	-- A link appears at most once per collection
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_collection_items_link ON collection_items(collection_id, link_id) WHERE link_id IS NOT NULL;
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Link memberships from the former collection_links table become link items. Copying
+	// skips rows already copied, so an interrupted migration can simply run again.
+	var legacy int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'collection_links'`).Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	_, err := db.Exec(`INSERT INTO collection_items (collection_id, type, link_id, sort_order, created_at, updated_at)
+		SELECT cl.collection_id, ?, cl.link_id,
+			ROW_NUMBER() OVER (PARTITION BY cl.collection_id ORDER BY cl.sort_order, cl.rowid), ?, ?
+		FROM collection_links cl
+		WHERE NOT EXISTS (SELECT 1 FROM collection_items ci WHERE ci.collection_id = cl.collection_id AND ci.link_id = cl.link_id)`,
+		domain.ItemLink, now, now)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`DROP TABLE collection_links`)
+	return err
+}
+
+// itemContent is the type-specific content of an item, stored as JSON
+type itemContent struct {
+	Label        string                 `json:"label,omitempty"`
+	Icon         string                 `json:"icon,omitempty"`
+	ThumbnailURL string                 `json:"thumbnail_url,omitempty"`
+	Highlight    bool                   `json:"highlight,omitempty"`
+	Text         string                 `json:"text,omitempty"`
+	URL          string                 `json:"url,omitempty"`
+	Socials      []domain.SocialProfile `json:"socials,omitempty"`
+}
+
+func marshalItem(item *domain.CollectionItem) (contentJSON, styleJSON []byte, err error) {
+	contentJSON, err = json.Marshal(itemContent{Label: item.Label, Icon: item.Icon, ThumbnailURL: item.ThumbnailURL, Highlight: item.Highlight,
+		Text: item.Text, URL: item.URL, Socials: item.Socials})
+	if err != nil {
+		return nil, nil, err
+	}
+	styleJSON, err = json.Marshal(item.Style)
+	return contentJSON, styleJSON, err
+}
+
+// CreateCollectionItem inserts the item at a 1-based position, or at the end when position
+// is 0 or past the end. The collection's sort orders are renumbered in the same transaction.
+func (r *SQLiteRepository) CreateCollectionItem(ctx context.Context, item *domain.CollectionItem, position int) error {
+	contentJSON, styleJSON, err := marshalItem(item)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	order, err := collectionItemOrder(ctx, tx, item.CollectionID)
+	if err != nil {
+		return err
+	}
+	if item.LinkID > 0 && slices.ContainsFunc(order.all(), func(ref itemRef) bool { return ref.linkID == item.LinkID }) {
+		return domain.ErrLinkInCollection
+	}
+
+	var linkID sql.NullInt64
+	if item.LinkID > 0 {
+		linkID = sql.NullInt64{Int64: item.LinkID, Valid: true}
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO collection_items (collection_id, type, link_id, content, style, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		item.CollectionID, item.Type, linkID, contentJSON, styleJSON,
+		item.CreatedAt.UTC().Format(sqliteTimeLayout), item.UpdatedAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return err
+	}
+	if item.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+
+	// Positions count visible items, as the page shows them
+	ref := itemRef{id: item.ID, linkID: item.LinkID}
+	if position < 1 || position > len(order.visible) {
+		order.visible = append(order.visible, ref)
+	} else {
+		order.visible = slices.Insert(order.visible, position-1, ref)
+	}
+	if err := writeItemOrder(ctx, tx, order.all()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const collectionItemQuery = `SELECT ci.id, ci.collection_id, ci.type, ci.content, ci.style, ci.created_at, ci.updated_at,
+		l.id, l.original_url, l.short_code, l.title, l.tags, l.created_at, l.updated_at
+	FROM collection_items ci
+	LEFT JOIN links l ON l.id = ci.link_id`
+
+// scanCollectionItem scans a row of collectionItemQuery
+func scanCollectionItem(row interface{ Scan(...interface{}) error }) (*domain.CollectionItem, error) {
+	var item domain.CollectionItem
+	var contentJSON, styleJSON []byte
+	var createdAt, updatedAt string
+	var link struct {
+		id                            sql.NullInt64
+		originalURL, shortCode, title sql.NullString
+		tagsJSON                      []byte
+		createdAt, updatedAt          sql.NullTime
+	}
+	if err := row.Scan(&item.ID, &item.CollectionID, &item.Type, &contentJSON, &styleJSON, &createdAt, &updatedAt,
+		&link.id, &link.originalURL, &link.shortCode, &link.title, &link.tagsJSON, &link.createdAt, &link.updatedAt); err != nil {
+		return nil, err
+	}
+
+	var content itemContent
+	_ = json.Unmarshal(contentJSON, &content)
+	item.Label, item.Icon, item.ThumbnailURL, item.Highlight = content.Label, content.Icon, content.ThumbnailURL, content.Highlight
+	item.Text, item.URL, item.Socials = content.Text, content.URL, content.Socials
+	_ = json.Unmarshal(styleJSON, &item.Style)
+	item.CreatedAt, _ = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	item.UpdatedAt, _ = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC)
+
+	if link.id.Valid {
+		item.LinkID = link.id.Int64
+		item.Link = &domain.Link{
+			ID:          link.id.Int64,
+			OriginalURL: link.originalURL.String,
+			ShortCode:   link.shortCode.String,
+			Title:       link.title.String,
+			CreatedAt:   link.createdAt.Time,
+			UpdatedAt:   link.updatedAt.Time,
+		}
+		_ = json.Unmarshal(link.tagsJSON, &item.Link.Tags)
+	}
+	return &item, nil
+}
+
+// ListCollectionItems returns the collection's visible items in page order, with the
+// link of link items. Items of deleted links are left out.
+func (r *SQLiteRepository) ListCollectionItems(ctx context.Context, collectionID int64) ([]domain.CollectionItem, error) {
+	rows, err := r.db.QueryContext(ctx, collectionItemQuery+`
+		WHERE ci.collection_id = ? AND (ci.link_id IS NULL OR (l.id IS NOT NULL AND l.deleted_at IS NULL))
+		ORDER BY ci.sort_order, ci.id`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.CollectionItem{}
+	for rows.Next() {
+		item, err := scanCollectionItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+func (r *SQLiteRepository) GetCollectionItem(ctx context.Context, collectionID, itemID int64) (*domain.CollectionItem, error) {
+	item, err := scanCollectionItem(r.db.QueryRowContext(ctx, collectionItemQuery+` WHERE ci.collection_id = ? AND ci.id = ?`, collectionID, itemID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return item, err
+}
+
+// UpdateCollectionItem replaces an item's content and style; its type, link and position stay
+func (r *SQLiteRepository) UpdateCollectionItem(ctx context.Context, item *domain.CollectionItem) error {
+	contentJSON, styleJSON, err := marshalItem(item)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `UPDATE collection_items SET content = ?, style = ?, updated_at = ? WHERE collection_id = ? AND id = ?`,
+		contentJSON, styleJSON, item.UpdatedAt.UTC().Format(sqliteTimeLayout), item.CollectionID, item.ID)
+	return err
+}
+
+func (r *SQLiteRepository) DeleteCollectionItem(ctx context.Context, collectionID, itemID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM collection_items WHERE collection_id = ? AND id = ?`, collectionID, itemID)
+	return err
+}
+
+func (r *SQLiteRepository) RemoveLinkFromCollection(ctx context.Context, collectionID, linkID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM collection_items WHERE collection_id = ? AND link_id = ?`, collectionID, linkID)
+	return err
+}
+
+// ReorderCollectionItems sets the order of the collection's items in one transaction.
+// itemIDs must list each visible item exactly once; items of links that were since
+// deleted keep their place after them.
+func (r *SQLiteRepository) ReorderCollectionItems(ctx context.Context, collectionID int64, itemIDs []int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	order, err := collectionItemOrder(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]itemRef, len(order.visible))
+	for _, ref := range order.visible {
+		byID[ref.id] = ref
+	}
+	if !sameIDs(slices.Collect(maps.Keys(byID)), itemIDs) {
+		return domain.ErrCollectionOrderMismatch
+	}
+
+	order.visible = order.visible[:0]
+	for _, id := range itemIDs {
+		order.visible = append(order.visible, byID[id])
+	}
+	if err := writeItemOrder(ctx, tx, order.all()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderCollectionLinks sets the order of the collection's link items in one transaction.
+// linkIDs must list each visible link exactly once. Other items keep their positions; the
+// links fill the positions link items had, in the given order.
+func (r *SQLiteRepository) ReorderCollectionLinks(ctx context.Context, collectionID int64, linkIDs []int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	order, err := collectionItemOrder(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+	byLink := make(map[int64]itemRef)
+	for _, ref := range order.visible {
+		if ref.linkID > 0 {
+			byLink[ref.linkID] = ref
+		}
+	}
+	if !sameIDs(slices.Collect(maps.Keys(byLink)), linkIDs) {
+		return domain.ErrCollectionOrderMismatch
+	}
+
+	next := 0
+	for i, ref := range order.visible {
+		if ref.linkID > 0 {
+			order.visible[i] = byLink[linkIDs[next]]
+			next++
+		}
+	}
+	if err := writeItemOrder(ctx, tx, order.all()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// itemRef identifies an item while reordering
+type itemRef struct {
+	id     int64
+	linkID int64 // 0 for items other than links
+}
+
+// itemOrder is a collection's items in page order
+type itemOrder struct {
+	visible []itemRef
+	hidden  []itemRef // Items of deleted links
+}
+
+// all returns every item, the hidden ones last
+func (o *itemOrder) all() []itemRef {
+	return append(slices.Clone(o.visible), o.hidden...)
+}
+
+func collectionItemOrder(ctx context.Context, tx *sql.Tx, collectionID int64) (*itemOrder, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT ci.id, COALESCE(ci.link_id, 0), ci.link_id IS NOT NULL AND (l.id IS NULL OR l.deleted_at IS NOT NULL)
+		FROM collection_items ci
+		LEFT JOIN links l ON l.id = ci.link_id
+		WHERE ci.collection_id = ? ORDER BY ci.sort_order, ci.id`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	order := &itemOrder{}
+	for rows.Next() {
+		var ref itemRef
+		var hidden bool
+		if err := rows.Scan(&ref.id, &ref.linkID, &hidden); err != nil {
+			return nil, err
+		}
+		if hidden {
+			order.hidden = append(order.hidden, ref)
+		} else {
+			order.visible = append(order.visible, ref)
+		}
+	}
+	return order, rows.Err()
+}
+
+// writeItemOrder numbers the items 1..n in the given order
+func writeItemOrder(ctx context.Context, tx *sql.Tx, refs []itemRef) error {
+	for i, ref := range refs {
+		if _, err := tx.ExecContext(ctx, `UPDATE collection_items SET sort_order = ? WHERE id = ?`, i+1, ref.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameIDs reports whether ids lists each of want exactly once, in any order
+func sameIDs(want, ids []int64) bool {
+	want, ids = slices.Clone(want), slices.Clone(ids)
+	slices.Sort(want)
+	slices.Sort(ids)
+	return slices.Equal(want, ids)
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func migrateCollectionViews(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS collection_views (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection_id INTEGER NOT NULL,
+		referer TEXT NOT NULL DEFAULT '',
+		referer_domain TEXT NOT NULL DEFAULT '',
+		referer_channel TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_hash TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_collection_views_created ON collection_views(collection_id, created_at);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Collection item a link click came from
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN collection_item_id INTEGER`)
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_visits_collection_item ON visits(collection_item_id, created_at) WHERE collection_item_id IS NOT NULL`)
+	return err
+}
+
+// RecordCollectionView stores a view of a collection page
+func (r *SQLiteRepository) RecordCollectionView(ctx context.Context, view *domain.CollectionView) error {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO collection_views (collection_id, referer, referer_domain, referer_channel, user_agent, ip_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		view.CollectionID, view.Referer, view.RefererDomain, view.RefererChannel, view.UserAgent, view.IPHash,
+		view.CreatedAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return err
+	}
+	view.ID, err = res.LastInsertId()
+	return err
+}
+
+// timeRangeClause restricts column to [from, to); zero times leave that side open
+func timeRangeClause(column string, from, to time.Time) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if !from.IsZero() {
+		clause += " AND " + column + " >= ?"
+		args = append(args, from.UTC().Format(sqliteTimeLayout))
+	}
+	if !to.IsZero() {
+		clause += " AND " + column + " < ?"
+		args = append(args, to.UTC().Format(sqliteTimeLayout))
+	}
+	return clause, args
+}
+
+// GetCollectionStats counts the views of a collection page and the clicks on its link
+// items within [from, to). Clicks come from raw visits, so they only cover the visit
+// retention period. Items of deleted links are left out like in ListCollectionItems, and
+// so are their clicks. Item titles and the click-through rate are left to the caller.
+func (r *SQLiteRepository) GetCollectionStats(ctx context.Context, collectionID int64, from, to time.Time) (*domain.CollectionStats, error) {
+	stats := &domain.CollectionStats{
+		Referrers: make(map[string]int64),
+		Channels:  make(map[string]int64),
+		Items:     []domain.ItemClicks{},
+		Daily:     []domain.CollectionDay{},
+	}
+
+	viewRange, viewArgs := timeRangeClause("created_at", from, to)
+	viewArgs = append([]interface{}{collectionID}, viewArgs...)
+	viewScope := ` FROM collection_views WHERE collection_id = ?` + viewRange
+
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(DISTINCT ip_hash)`+viewScope, viewArgs...).
+		Scan(&stats.Views, &stats.UniqueVisitors); err != nil {
+		return nil, err
+	}
+	countViewsBy := func(column, empty string, into map[string]int64) error {
+		rows, err := r.db.QueryContext(ctx, `SELECT `+column+`, COUNT(*)`+viewScope+` GROUP BY `+column, viewArgs...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key string
+			var count int64
+			if err := rows.Scan(&key, &count); err != nil {
+				return err
+			}
+			if key == "" {
+				key = empty
+			}
+			into[key] += count
+		}
+		return rows.Err()
+	}
+	if err := countViewsBy("referer_domain", "Direct", stats.Referrers); err != nil {
+		return nil, err
+	}
+	if err := countViewsBy("referer_channel", domain.ChannelDirect, stats.Channels); err != nil {
+		return nil, err
+	}
+
+	// Clicks only count for the link the item points to, so a forged item parameter on
+	// another link's short URL is ignored
+	clickRange, clickArgs := timeRangeClause("v.created_at", from, to)
+	rows, err := r.db.QueryContext(ctx, `SELECT ci.id, ci.link_id, COUNT(v.id)
+		FROM collection_items ci
+		JOIN links l ON l.id = ci.link_id AND l.deleted_at IS NULL
+		LEFT JOIN visits v ON v.collection_item_id = ci.id AND v.link_id = ci.link_id`+clickRange+`
+		WHERE ci.collection_id = ? AND ci.type = ?
+		GROUP BY ci.id ORDER BY ci.sort_order, ci.id`, append(clickArgs, collectionID, domain.ItemLink)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item domain.ItemClicks
+		if err := rows.Scan(&item.ItemID, &item.LinkID, &item.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Clicks += item.Clicks
+		stats.Items = append(stats.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dailyArgs := append(append(viewArgs, collectionID, domain.ItemLink), clickArgs...)
+	dailyRows, err := r.db.QueryContext(ctx, `SELECT day, SUM(views), SUM(clicks) FROM (
+			SELECT substr(created_at, 1, 10) AS day, 1 AS views, 0 AS clicks`+viewScope+`
+			UNION ALL
+			SELECT substr(v.created_at, 1, 10), 0, 1
+			FROM visits v JOIN collection_items ci ON ci.id = v.collection_item_id AND ci.link_id = v.link_id
+			JOIN links l ON l.id = ci.link_id AND l.deleted_at IS NULL
+			WHERE ci.collection_id = ? AND ci.type = ?`+clickRange+`
+		) GROUP BY day ORDER BY day`, dailyArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer dailyRows.Close()
+	for dailyRows.Next() {
+		var day domain.CollectionDay
+		if err := dailyRows.Scan(&day.Date, &day.Views, &day.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	return stats, dailyRows.Err()
+}
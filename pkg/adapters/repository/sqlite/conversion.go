@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func migrateConversions(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS conversions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		click_id TEXT NOT NULL, -- visits.click_id; the visit may not be written yet, or pruned
+		link_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		revenue REAL NOT NULL DEFAULT 0,
+		currency TEXT NOT NULL DEFAULT '',
+		order_id TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	-- Repeated postbacks and pixel loads count once per click, event and order
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_conversions_dedup ON conversions(click_id, event, order_id);
+	CREATE INDEX IF NOT EXISTS idx_conversions_link_created ON conversions(link_id, created_at);
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// RecordConversion stores the conversion unless the same click, event and order was already
+// recorded. It reports whether a new row was created.
+func (r *SQLiteRepository) RecordConversion(ctx context.Context, c *domain.Conversion) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT OR IGNORE INTO conversions (click_id, link_id, event, revenue, currency, order_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.ClickID, c.LinkID, c.Event, c.Revenue, c.Currency, c.OrderID, c.CreatedAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	c.ID, err = res.LastInsertId()
+	return true, err
+}
+
+// conversionStats fills the conversion fields of stats for the date range of filters
+func (r *SQLiteRepository) conversionStats(ctx context.Context, linkID int64, filters map[string]interface{}, stats *domain.LinkStats) error {
+	where := "WHERE link_id = ?"
+	args := []interface{}{linkID}
+	if start, ok := filters["start_date"].(time.Time); ok {
+		where += " AND created_at >= ?"
+		args = append(args, start.UTC().Format(sqliteTimeLayout))
+	}
+	if end, ok := filters["end_date"].(time.Time); ok {
+		where += " AND created_at < ?"
+		args = append(args, end.UTC().Format(sqliteTimeLayout))
+	}
+
+	var convertedClicks int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(DISTINCT click_id) FROM conversions `+where, args...).
+		Scan(&stats.Conversions, &convertedClicks); err != nil {
+		return err
+	}
+	if stats.TotalClicks > 0 {
+		stats.ConversionRate = float64(convertedClicks) / float64(stats.TotalClicks)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT currency, SUM(revenue) FROM conversions `+where+` AND revenue != 0 GROUP BY currency`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency string
+		var revenue float64
+		if err := rows.Scan(&currency, &revenue); err != nil {
+			return err
+		}
+		if stats.Revenue == nil {
+			stats.Revenue = make(map[string]float64)
+		}
+		stats.Revenue[currency] = revenue
+	}
+	return rows.Err()
+}
@@ -13,6 +13,9 @@ import (
 	_ "modernc.org/sqlite" // Local SQLite driver
 )
 
+// sqliteTimeLayout is how visit timestamps are stored (always UTC)
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
 type SQLiteRepository struct {
 	db *sql.DB
 }
@@ -23,6 +26,16 @@ func NewSQLiteRepository(dbURL string) (*SQLiteRepository, error) {
 		driverName = "libsql"
 	}
 
+	// Wait for locks instead of failing with SQLITE_BUSY when a visit batch is being
+	// written while redirects read. Explicit _pragma settings in the URL win.
+	if driverName == "sqlite" && !strings.Contains(dbURL, "_pragma=") {
+		sep := "?"
+		if strings.Contains(dbURL, "?") {
+			sep = "&"
+		}
+		dbURL += sep + "_pragma=busy_timeout(5000)"
+	}
+
 	db, err := sql.Open(driverName, dbURL)
 	if err != nil {
 		return nil, err
@@ -75,15 +88,6 @@ func migrate(db *sql.DB) error {
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	CREATE INDEX IF NOT EXISTS idx_collections_slug ON collections(slug);
-
-	CREATE TABLE IF NOT EXISTS collection_links (
-		collection_id INTEGER NOT NULL,
-		link_id INTEGER NOT NULL,
-		sort_order INTEGER DEFAULT 0,
-		PRIMARY KEY (collection_id, link_id),
-		FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
-		FOREIGN KEY(link_id) REFERENCES links(id) ON DELETE CASCADE
-	);
 	`
 	if _, err := db.Exec(query); err != nil {
 		return err
@@ -94,9 +98,120 @@ func migrate(db *sql.DB) error {
 	// or check pragma table_info. Simpler is just to try.
 	_, _ = db.Exec(`ALTER TABLE links ADD COLUMN clicks INTEGER DEFAULT 0`)
 
+	// Visitor location (GeoIP), resolved at visit time
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN country TEXT`)
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN region TEXT`)
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN city TEXT`)
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_visits_link_country ON visits(link_id, country)`); err != nil {
+		return err
+	}
+
+	// Normalized referrer source, stored alongside the raw referer
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN referer_domain TEXT`)
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN referer_channel TEXT`)
+
+	// Campaign attribution captured from the inbound short-link URL
+	for _, column := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"} {
+		_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN ` + column + ` TEXT`)
+	}
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN query_params JSON`)
+
+	// Link-in-bio page avatar
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN avatar_url TEXT NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN appearance JSON NOT NULL DEFAULT '{}'`)
+
+	// Page visibility and publishing window; existing pages stay public
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'`)
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN publish_at TEXT`)
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN unpublish_at TEXT`)
+
+	// Collections kept as starting points for clones
+	_, _ = db.Exec(`ALTER TABLE collections ADD COLUMN is_template INTEGER NOT NULL DEFAULT 0`)
+
+	// Click ID issued on redirect, referenced by conversions
+	_, _ = db.Exec(`ALTER TABLE visits ADD COLUMN click_id TEXT`)
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_visits_click_id ON visits(click_id) WHERE click_id IS NOT NULL`); err != nil {
+		return err
+	}
+
+	if err := migrateRollups(db); err != nil {
+		return err
+	}
+	if err := backfillReferrers(db); err != nil {
+		return err
+	}
+	if err := migrateWebhooks(db); err != nil {
+		return err
+	}
+	if err := migrateAlerts(db); err != nil {
+		return err
+	}
+	if err := migrateConversions(db); err != nil {
+		return err
+	}
+	if err := migrateCollectionItems(db); err != nil {
+		return err
+	}
+	if err := migrateCollectionViews(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// referrersBackfilledKey marks in rollup_state that backfillReferrers has run
+const referrersBackfilledKey = "referrers_backfilled"
+
+// backfillReferrers normalizes referers of visits recorded before referer_channel existed.
+// It runs once per database: new visits are stored normalized, so later starts skip the
+// full scan.
+func backfillReferrers(db *sql.DB) error {
+	var done string
+	err := db.QueryRow(`SELECT value FROM rollup_state WHERE name = ?`, referrersBackfilledKey).Scan(&done)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT COALESCE(referer, '') FROM visits WHERE referer_channel IS NULL`)
+	if err != nil {
+		return err
+	}
+	var referers []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			rows.Close()
+			return err
+		}
+		referers = append(referers, ref)
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ref := range referers {
+		refDomain, channel := domain.NormalizeReferrer(ref)
+		_, err := tx.Exec(`UPDATE visits SET referer_domain = ?, referer_channel = ?
+			WHERE referer_channel IS NULL AND COALESCE(referer, '') = ?`, refDomain, channel, ref)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO rollup_state (name, value) VALUES (?, ?)`,
+		referrersBackfilledKey, time.Now().UTC().Format(sqliteTimeLayout)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (r *SQLiteRepository) Create(ctx context.Context, link *domain.Link) error {
 	query := `INSERT INTO links (original_url, short_code, title, tags, created_at, updated_at) 
 			  VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
@@ -190,10 +305,24 @@ func (r *SQLiteRepository) Update(ctx context.Context, link *domain.Link) error
 	return err
 }
 
+// Delete soft-deletes a link. Collections showing it are touched, since the link drops out
+// of their items without any of their timestamps changing otherwise.
 func (r *SQLiteRepository) Delete(ctx context.Context, id int64) error {
-	query := `UPDATE links SET deleted_at = ? WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE links SET deleted_at = ? WHERE id = ?`, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE collections SET updated_at = ?
+		WHERE id IN (SELECT collection_id FROM collection_items WHERE link_id = ?)`, now, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *SQLiteRepository) List(ctx context.Context, limit, offset int, filters map[string]interface{}) ([]domain.Link, error) {
@@ -281,103 +410,389 @@ func (r *SQLiteRepository) Dump(ctx context.Context) ([]domain.Link, error) {
 }
 
 func (r *SQLiteRepository) RecordVisit(ctx context.Context, visit *domain.Visit) error {
+	return r.RecordVisits(ctx, []*domain.Visit{visit})
+}
+
+// visitInsertBatch keeps multi-row inserts under SQLite's bound parameter limit (18 per row)
+const visitInsertBatch = 500
+
+// RecordVisits inserts visits with multi-row INSERTs and bumps each link's clicks counter
+// once per batch, all in a single transaction. Duplicate visits only increment the hourly
+// visit_duplicates counters.
+func (r *SQLiteRepository) RecordVisits(ctx context.Context, visits []*domain.Visit) error {
+	if len(visits) == 0 {
+		return nil
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// 1. Insert Visit Record
-	queryVisit := `INSERT INTO visits (link_id, referer, user_agent, ip_hash, created_at) VALUES (?, ?, ?, ?, ?)`
-	_, err = tx.ExecContext(ctx, queryVisit, visit.LinkID, visit.Referer, visit.UserAgent, visit.IPHash, visit.CreatedAt.Format("2006-01-02 15:04:05"))
+	type duplicateKey struct {
+		linkID int64
+		bucket string
+	}
+	duplicates := make(map[duplicateKey]int64)
+	unique := make([]*domain.Visit, 0, len(visits))
+	for _, visit := range visits {
+		if visit.Duplicate {
+			duplicates[duplicateKey{visit.LinkID, visit.CreatedAt.UTC().Format("2006-01-02 15:00:00")}]++
+			continue
+		}
+		unique = append(unique, visit)
+	}
+	for key, n := range duplicates {
+		_, err := tx.ExecContext(ctx, `INSERT INTO visit_duplicates (link_id, bucket, count) VALUES (?, ?, ?)
+			ON CONFLICT(link_id, bucket) DO UPDATE SET count = count + excluded.count`, key.linkID, key.bucket, n)
+		if err != nil {
+			return err
+		}
+	}
+	visits = unique
+
+	// 1. Insert Visit Records
+	clicks := make(map[int64]int64)
+	for start := 0; start < len(visits); start += visitInsertBatch {
+		chunk := visits[start:min(start+visitInsertBatch, len(visits))]
+
+		var query strings.Builder
+		query.WriteString(`INSERT INTO visits (link_id, referer, referer_domain, referer_channel, user_agent, ip_hash, country, region, city,
+		utm_source, utm_medium, utm_campaign, utm_term, utm_content, query_params, click_id, collection_item_id, created_at) VALUES `)
+		args := make([]interface{}, 0, len(chunk)*18)
+		for i, visit := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+			var paramsJSON []byte
+			if len(visit.Params) > 0 {
+				if paramsJSON, err = json.Marshal(visit.Params); err != nil {
+					return err
+				}
+			}
+			args = append(args, visit.LinkID, visit.Referer, visit.RefererDomain, visit.RefererChannel, visit.UserAgent, visit.IPHash,
+				visit.Country, visit.Region, visit.City,
+				visit.UTMSource, visit.UTMMedium, visit.UTMCampaign, visit.UTMTerm, visit.UTMContent, paramsJSON,
+				sql.NullString{String: visit.ClickID, Valid: visit.ClickID != ""},
+				sql.NullInt64{Int64: visit.CollectionItemID, Valid: visit.CollectionItemID != 0}, visit.CreatedAt.UTC().Format(sqliteTimeLayout))
+			clicks[visit.LinkID]++
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+
+	// 2. Increment Link Clicks Counters (Atomic, one update per link)
+	for linkID, n := range clicks {
+		if _, err := tx.ExecContext(ctx, `UPDATE links SET clicks = clicks + ? WHERE id = ?`, n, linkID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// HasRecentVisit reports whether the link has a stored visit from the same visitor and user agent since the given time
+func (r *SQLiteRepository) HasRecentVisit(ctx context.Context, linkID int64, ipHash, userAgent string, since time.Time) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM visits WHERE link_id = ? AND created_at >= ? AND ip_hash = ? AND user_agent = ? LIMIT 1`,
+		linkID, since.UTC().Format(sqliteTimeLayout), ipHash, userAgent).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// visitColumns is the SELECT list scanned by scanVisit
+const visitColumns = `id, link_id, COALESCE(referer, ''), COALESCE(referer_domain, ''), COALESCE(referer_channel, ''),
+	COALESCE(user_agent, ''), COALESCE(ip_hash, ''), COALESCE(country, ''), COALESCE(region, ''), COALESCE(city, ''),
+	COALESCE(utm_source, ''), COALESCE(utm_medium, ''), COALESCE(utm_campaign, ''), COALESCE(utm_term, ''), COALESCE(utm_content, ''),
+	query_params, COALESCE(click_id, ''), strftime('%Y-%m-%d %H:%M:%S', created_at)`
+
+func scanVisit(rows *sql.Rows) (*domain.Visit, error) {
+	var v domain.Visit
+	var paramsJSON []byte
+	var createdAt string
+	if err := rows.Scan(&v.ID, &v.LinkID, &v.Referer, &v.RefererDomain, &v.RefererChannel,
+		&v.UserAgent, &v.IPHash, &v.Country, &v.Region, &v.City,
+		&v.UTMSource, &v.UTMMedium, &v.UTMCampaign, &v.UTMTerm, &v.UTMContent,
+		&paramsJSON, &v.ClickID, &createdAt); err != nil {
+		return nil, err
+	}
+	if len(paramsJSON) > 0 {
+		_ = json.Unmarshal(paramsJSON, &v.Params)
+	}
+	v.CreatedAt, _ = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	return &v, nil
+}
+
+// ForEachVisit streams raw visits in ID order to fn without loading them all in memory.
+// Filters are those of visitListClause.
+func (r *SQLiteRepository) ForEachVisit(ctx context.Context, filters map[string]interface{}, fn func(*domain.Visit) error) error {
+	where, args := visitListClause(filters)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+visitColumns+` FROM visits `+where+` ORDER BY id`, args...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	// 2. Increment Link Clicks Counter (Atomic)
-	queryCount := `UPDATE links SET clicks = clicks + 1 WHERE id = ?`
-	_, err = tx.ExecContext(ctx, queryCount, visit.LinkID)
+	for rows.Next() {
+		v, err := scanVisit(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListVisits returns up to limit raw visits in ID order. Filters are those of visitListClause.
+func (r *SQLiteRepository) ListVisits(ctx context.Context, limit int, filters map[string]interface{}) ([]domain.Visit, error) {
+	where, args := visitListClause(filters)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+visitColumns+` FROM visits `+where+` ORDER BY id LIMIT ?`, append(args, limit)...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return tx.Commit()
+	visits := []domain.Visit{}
+	for rows.Next() {
+		v, err := scanVisit(rows)
+		if err != nil {
+			return nil, err
+		}
+		visits = append(visits, *v)
+	}
+	return visits, rows.Err()
+}
+
+// visitListClause builds the WHERE clause for listing raw visits. Supported filters:
+// "link_id" (int64), "start_date" and "end_date" (time.Time), "after_id" (int64, cursor)
+// and "tag" (string, visits of links with that tag).
+func visitListClause(filters map[string]interface{}) (string, []interface{}) {
+	where := "WHERE 1 = 1"
+	args := []interface{}{}
+
+	if linkID, ok := filters["link_id"].(int64); ok {
+		where += " AND link_id = ?"
+		args = append(args, linkID)
+	}
+	if afterID, ok := filters["after_id"].(int64); ok && afterID > 0 {
+		where += " AND id > ?"
+		args = append(args, afterID)
+	}
+	if start, ok := filters["start_date"].(time.Time); ok {
+		where += " AND created_at >= ?"
+		args = append(args, start.UTC().Format(sqliteTimeLayout))
+	}
+	if end, ok := filters["end_date"].(time.Time); ok {
+		where += " AND created_at < ?"
+		args = append(args, end.UTC().Format(sqliteTimeLayout))
+	}
+	if tag, ok := filters["tag"].(string); ok && tag != "" {
+		where += " AND link_id IN (SELECT id FROM links WHERE EXISTS (SELECT 1 FROM json_each(links.tags) WHERE value = ?))"
+		args = append(args, tag)
+	}
+	return where, args
+}
+
+func (r *SQLiteRepository) OldestVisitTime(ctx context.Context) (time.Time, error) {
+	var oldest sql.NullString
+	if err := r.db.QueryRowContext(ctx, `SELECT strftime('%Y-%m-%d %H:%M:%S', MIN(created_at)) FROM visits`).Scan(&oldest); err != nil {
+		return time.Time{}, err
+	}
+	if !oldest.Valid {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(sqliteTimeLayout, oldest.String, time.UTC)
+}
+
+// DeleteVisits removes raw visits in [from, to) and moves the pruning watermark up to `to`
+func (r *SQLiteRepository) DeleteVisits(ctx context.Context, from, to time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM visits WHERE created_at >= ? AND created_at < ?`,
+		from.UTC().Format(sqliteTimeLayout), to.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	// The layout sorts as text, so MAX keeps the latest watermark
+	_, err = tx.ExecContext(ctx, `INSERT INTO rollup_state (name, value) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = MAX(value, excluded.value)`, pruneWatermarkKey, to.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// PrunedUntil returns the pruning watermark: raw visits before it may have been deleted.
+// Zero if nothing was pruned.
+func (r *SQLiteRepository) PrunedUntil(ctx context.Context) (time.Time, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, `SELECT value FROM rollup_state WHERE name = ?`, pruneWatermarkKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(sqliteTimeLayout, value, time.UTC)
 }
 
 func (r *SQLiteRepository) GetLinkStats(ctx context.Context, linkID int64, filters map[string]interface{}) (*domain.LinkStats, error) {
 	stats := &domain.LinkStats{
-		Referrers:   make(map[string]int64),
 		DailyClicks: []domain.DailyClick{},
 	}
 
-	// Build Base Query
-	whereClause := "WHERE link_id = ?"
-	args := []interface{}{linkID}
+	top := 10
+	if n, ok := filters["top"].(int); ok && n > 0 {
+		top = n
+	}
+
+	scope, err := r.linkVisitScope(ctx, linkID, filters)
+	if err != nil {
+		return nil, err
+	}
 
+	// 1. Total Clicks and unique visitors
+	if stats.TotalClicks, stats.UniqueVisitors, err = r.scopeTotals(ctx, scope); err != nil {
+		return nil, err
+	}
+
+	// 1b. Repeats ignored by the dedup window (link-wide; dimension filters don't apply).
+	// They are counted per hour, so both ends of the range are truncated to the hour: an
+	// end in the middle of an hour leaves that hour out rather than count repeats after it.
+	dupQuery := `SELECT COALESCE(SUM(count), 0) FROM visit_duplicates WHERE link_id = ?`
+	dupArgs := []interface{}{linkID}
 	if start, ok := filters["start_date"].(time.Time); ok {
-		whereClause += " AND created_at >= ?"
-		args = append(args, start)
+		dupQuery += " AND bucket >= ?"
+		dupArgs = append(dupArgs, start.UTC().Truncate(time.Hour).Format(sqliteTimeLayout))
 	}
 	if end, ok := filters["end_date"].(time.Time); ok {
-		whereClause += " AND created_at < ?"
-		args = append(args, end)
+		dupQuery += " AND bucket < ?"
+		dupArgs = append(dupArgs, end.UTC().Truncate(time.Hour).Format(sqliteTimeLayout))
 	}
-	if ref, ok := filters["referer"].(string); ok && ref != "" {
-		whereClause += " AND referer LIKE ?"
-		args = append(args, "%"+ref+"%")
+	if err := r.db.QueryRowContext(ctx, dupQuery, dupArgs...).Scan(&stats.DuplicateClicks); err != nil {
+		return nil, err
+	}
+
+	// 1c. Conversions (link-wide like duplicates)
+	if err := r.conversionStats(ctx, linkID, filters, stats); err != nil {
+		return nil, err
+	}
+
+	// 2. Referrers: raw, normalized domain and channel
+	if stats.Referrers, err = r.countBy(ctx, scope, "referer", top, "Direct"); err != nil {
+		return nil, err
+	}
+	if stats.ReferrerDomains, err = r.countBy(ctx, scope, "referer_domain", top, "Direct"); err != nil {
+		return nil, err
+	}
+	if stats.Channels, err = r.countBy(ctx, scope, "referer_channel", -1, "Unknown"); err != nil {
+		return nil, err
+	}
+
+	// 2b. Locations
+	geo, err := r.geoBreakdown(ctx, scope, top)
+	if err != nil {
+		return nil, err
+	}
+	stats.Geo = *geo
+
+	// 2c. Campaigns
+	if stats.UTM.Sources, err = r.countBy(ctx, scope, "utm_source", top, "(none)"); err != nil {
+		return nil, err
+	}
+	if stats.UTM.Mediums, err = r.countBy(ctx, scope, "utm_medium", top, "(none)"); err != nil {
+		return nil, err
+	}
+	if stats.UTM.Campaigns, err = r.countBy(ctx, scope, "utm_campaign", top, "(none)"); err != nil {
+		return nil, err
 	}
 
-	// 1. Total Clicks
-	queryTotal := "SELECT COUNT(*) FROM visits " + whereClause
-	err := r.db.QueryRowContext(ctx, queryTotal, args...).Scan(&stats.TotalClicks)
+	return stats, nil
+}
+
+// GetClickSeries returns hourly (UTC) click counts for a link over the filtered range, oldest first.
+// Hours without clicks are omitted; callers bucket and zero-fill them.
+func (r *SQLiteRepository) GetClickSeries(ctx context.Context, linkID int64, filters map[string]interface{}) ([]domain.TimeBucket, error) {
+	scope, err := r.linkVisitScope(ctx, linkID, filters)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. Referrers
-	queryRef := "SELECT referer, COUNT(*) as c FROM visits " + whereClause + " GROUP BY referer ORDER BY c DESC LIMIT 10"
-	rows, err := r.db.QueryContext(ctx, queryRef, args...)
+	query := "SELECT strftime('%Y-%m-%d %H:00:00', created_at) AS hour, COUNT(*) AS c FROM " + scope.rawFrom + " " + scope.rawWhere + " GROUP BY hour"
+	args := append([]interface{}{}, scope.rawArgs...)
+	if scope.useRollup {
+		query += " UNION ALL SELECT ru.bucket AS hour, SUM(ru.clicks) AS c FROM visit_rollups_hourly ru " + scope.rollupJoin +
+			" WHERE " + scope.rollupWhere + " GROUP BY ru.bucket"
+		args = append(args, scope.rollupArgs...)
+	}
+	query = "SELECT hour, SUM(c) FROM (" + query + ") GROUP BY hour ORDER BY hour"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+
+	var series []domain.TimeBucket
 	for rows.Next() {
-		var ref string
-		var count int64
-		if err := rows.Scan(&ref, &count); err != nil {
+		var hour string
+		var bucket domain.TimeBucket
+		if err := rows.Scan(&hour, &bucket.Count); err != nil {
 			return nil, err
 		}
-		if ref == "" {
-			ref = "Direct"
+		if bucket.Start, err = time.ParseInLocation(sqliteTimeLayout, hour, time.UTC); err != nil {
+			return nil, err
 		}
-		stats.Referrers[ref] = count
+		series = append(series, bucket)
 	}
-	rows.Close()
+	return series, rows.Err()
+}
 
-	// 3. Clicks (Last 30 days or filtered range)
-	// Note: We might want to adjust LIMIT if a specific large range is requested,
-	// but keeping LIMIT 30 for safety/UI consistency is fine for now unless paginated.
-	queryDaily := `
-		SELECT strftime('%Y-%m-%d', created_at) as date, COUNT(*) 
-		FROM visits ` + whereClause + `
-		GROUP BY date 
-		ORDER BY date DESC 
-		LIMIT 30`
+// visitFilterClause builds the WHERE clause selecting a link's visits matching the stats filters
+func visitFilterClause(linkID int64, filters map[string]interface{}) (string, []interface{}) {
+	whereClause := "WHERE link_id = ?"
+	args := []interface{}{linkID}
 
-	rows2, err := r.db.QueryContext(ctx, queryDaily, args...)
-	if err != nil {
-		return nil, err
+	if start, ok := filters["start_date"].(time.Time); ok {
+		whereClause += " AND created_at >= ?"
+		args = append(args, start.UTC().Format(sqliteTimeLayout))
 	}
-	defer rows2.Close()
-	for rows2.Next() {
-		var dc domain.DailyClick
-		if err := rows2.Scan(&dc.Date, &dc.Count); err != nil {
-			return nil, err
+	if end, ok := filters["end_date"].(time.Time); ok {
+		whereClause += " AND created_at < ?"
+		args = append(args, end.UTC().Format(sqliteTimeLayout))
+	}
+	if ref, ok := filters["referer"].(string); ok && ref != "" {
+		whereClause += " AND referer LIKE ?"
+		args = append(args, "%"+ref+"%")
+	}
+	if country, ok := filters["country"].(string); ok && country != "" {
+		whereClause += " AND country = ?"
+		args = append(args, country)
+	}
+	for _, column := range []string{"utm_source", "utm_medium", "utm_campaign"} {
+		if value, ok := filters[column].(string); ok && value != "" {
+			whereClause += " AND " + column + " = ?"
+			args = append(args, value)
 		}
-		stats.DailyClicks = append(stats.DailyClicks, dc)
 	}
-
-	return stats, nil
+	return whereClause, args
 }
 
 func (r *SQLiteRepository) GetDashboardStats(ctx context.Context, limit int, filters map[string]interface{}) ([]domain.Link, int64, error) {
@@ -392,58 +807,163 @@ func (r *SQLiteRepository) GetDashboardStats(ctx context.Context, limit int, fil
 	// 2. Get Top Links by clicks (Optimized)
 	// Uses the indexed 'clicks' column (we should add an index later if needed, but for now it's a simple sort)
 	query := `
-		SELECT id, original_url, short_code, title, tags, clicks, created_at, updated_at
+		SELECT id, original_url, short_code, title, tags, clicks, created_at, updated_at,
+			(SELECT COUNT(*) FROM conversions c WHERE c.link_id = links.id),
+			(SELECT COUNT(DISTINCT click_id) FROM conversions c WHERE c.link_id = links.id)
 		FROM links
 		WHERE deleted_at IS NULL
 	`
+	clause, args := dashboardFilterClause("", filters)
+	query += clause
+
+	query += " ORDER BY clicks DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var links []domain.Link
+	for rows.Next() {
+		var l domain.Link
+		var tagsJSON []byte
+
+		var convertedClicks int64
+		if err := rows.Scan(&l.ID, &l.OriginalURL, &l.ShortCode, &l.Title, &tagsJSON, &l.Clicks, &l.CreatedAt, &l.UpdatedAt,
+			&l.Conversions, &convertedClicks); err != nil {
+			return nil, 0, err
+		}
+		_ = json.Unmarshal(tagsJSON, &l.Tags)
+		if l.Clicks > 0 {
+			l.ConversionRate = float64(convertedClicks) / float64(l.Clicks)
+		}
+		links = append(links, l)
+	}
+
+	return links, totalSystemClicks, nil
+}
+
+// GetGeoBreakdown returns the top visitor locations across all links matching the dashboard filters
+func (r *SQLiteRepository) GetGeoBreakdown(ctx context.Context, limit int, filters map[string]interface{}) (*domain.GeoBreakdown, error) {
+	clause, args := dashboardFilterClause("l.", filters)
+	whereClause := "l.deleted_at IS NULL" + clause
+
+	scope, err := r.linksVisitScope(ctx, whereClause, args)
+	if err != nil {
+		return nil, err
+	}
+	return r.geoBreakdown(ctx, scope, limit)
+}
+
+// dashboardFilterClause builds the " AND ..." conditions for the dashboard filters
+// "search", "tag" and "domain", on links columns qualified by prefix
+func dashboardFilterClause(prefix string, filters map[string]interface{}) (string, []interface{}) {
+	clause := ""
 	args := []interface{}{}
 
 	if search, ok := filters["search"].(string); ok && search != "" {
-		query += " AND (title LIKE ?)"
+		clause += " AND (" + prefix + "title LIKE ?)"
 		args = append(args, "%"+search+"%")
 	}
-
 	if tag, ok := filters["tag"].(string); ok && tag != "" {
-		query += " AND EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ?)"
+		clause += " AND EXISTS (SELECT 1 FROM json_each(" + prefix + "tags) WHERE value = ?)"
 		args = append(args, tag)
 	}
-
 	if domainFilter, ok := filters["domain"].(string); ok && domainFilter != "" {
-		query += " AND original_url LIKE ?"
+		clause += " AND " + prefix + "original_url LIKE ?"
 		args = append(args, "%"+domainFilter+"%")
 	}
+	return clause, args
+}
 
-	query += " ORDER BY clicks DESC LIMIT ?"
-	args = append(args, limit)
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
+// ListStatsLinks returns the ID, destination, tags and creation time of every link
+// matching the dashboard filters, for aggregating clicks
+func (r *SQLiteRepository) ListStatsLinks(ctx context.Context, filters map[string]interface{}) ([]domain.Link, error) {
+	clause, args := dashboardFilterClause("", filters)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, original_url, tags, created_at FROM links WHERE deleted_at IS NULL`+clause+` ORDER BY id`, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var links []domain.Link
+	links := []domain.Link{}
 	for rows.Next() {
 		var l domain.Link
 		var tagsJSON []byte
-
-		if err := rows.Scan(&l.ID, &l.OriginalURL, &l.ShortCode, &l.Title, &tagsJSON, &l.Clicks, &l.CreatedAt, &l.UpdatedAt); err != nil {
-			return nil, 0, err
+		if err := rows.Scan(&l.ID, &l.OriginalURL, &tagsJSON, &l.CreatedAt); err != nil {
+			return nil, err
 		}
 		_ = json.Unmarshal(tagsJSON, &l.Tags)
 		links = append(links, l)
 	}
+	return links, rows.Err()
+}
 
-	return links, totalSystemClicks, nil
+// geoBreakdown groups the visits in scope by country, region and city.
+// Visits recorded without a location are counted under "Unknown".
+func (r *SQLiteRepository) geoBreakdown(ctx context.Context, scope *visitScope, limit int) (*domain.GeoBreakdown, error) {
+	geo := &domain.GeoBreakdown{}
+	var err error
+
+	if geo.Countries, err = r.countBy(ctx, scope, "country", limit, "Unknown"); err != nil {
+		return nil, err
+	}
+	if geo.Regions, err = r.countBy(ctx, scope, "region", limit, "Unknown"); err != nil {
+		return nil, err
+	}
+	if geo.Cities, err = r.countBy(ctx, scope, "city", limit, "Unknown"); err != nil {
+		return nil, err
+	}
+	return geo, nil
 }
 
 // --- Collection Repository Implementation ---
 
+const collectionColumns = `id, slug, title, description, avatar_url, appearance, visibility, password_hash, publish_at, unpublish_at,
+	is_template, created_at, updated_at`
+
+func scanCollection(row interface{ Scan(...interface{}) error }) (*domain.Collection, error) {
+	var c domain.Collection
+	var title, description sql.NullString
+	var appearanceJSON []byte
+	var publishAt, unpublishAt sql.NullString
+	if err := row.Scan(&c.ID, &c.Slug, &title, &description, &c.AvatarURL, &appearanceJSON, &c.Visibility, &c.PasswordHash,
+		&publishAt, &unpublishAt, &c.IsTemplate, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	c.Title, c.Description = title.String, description.String
+	_ = json.Unmarshal(appearanceJSON, &c.Appearance)
+	c.PublishAt, c.UnpublishAt = parseNullableTime(publishAt), parseNullableTime(unpublishAt)
+	return &c, nil
+}
+
+// parseNullableTime reads a time stored by nullableTime
+func parseNullableTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.ParseInLocation(sqliteTimeLayout, s.String, time.UTC)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 func (r *SQLiteRepository) CreateCollection(ctx context.Context, collection *domain.Collection) error {
-	query := `INSERT INTO collections (slug, title, description, created_at, updated_at) 
-			  VALUES (?, ?, ?, ?, ?) RETURNING id`
+	appearanceJSON, err := json.Marshal(collection.Appearance)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO collections (slug, title, description, avatar_url, appearance, visibility, password_hash, publish_at, unpublish_at,
+			  is_template, created_at, updated_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
 
-	res, err := r.db.ExecContext(ctx, query, collection.Slug, collection.Title, collection.Description, collection.CreatedAt, collection.UpdatedAt)
+	res, err := r.db.ExecContext(ctx, query, collection.Slug, collection.Title, collection.Description, collection.AvatarURL, appearanceJSON,
+		collection.Visibility, collection.PasswordHash, nullableTime(collection.PublishAt), nullableTime(collection.UnpublishAt),
+		collection.IsTemplate, collection.CreatedAt, collection.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -456,53 +976,84 @@ func (r *SQLiteRepository) CreateCollection(ctx context.Context, collection *dom
 }
 
 func (r *SQLiteRepository) GetCollection(ctx context.Context, id int64) (*domain.Collection, error) {
-	query := `SELECT id, slug, title, description, created_at, updated_at FROM collections WHERE id = ?`
-	row := r.db.QueryRowContext(ctx, query, id)
-
-	var c domain.Collection
-	if err := row.Scan(&c.ID, &c.Slug, &c.Title, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = ?`
+	c, err := scanCollection(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return &c, nil
+	return c, err
 }
 
 func (r *SQLiteRepository) GetCollectionBySlug(ctx context.Context, slug string) (*domain.Collection, error) {
-	query := `SELECT id, slug, title, description, created_at, updated_at FROM collections WHERE slug = ?`
-	row := r.db.QueryRowContext(ctx, query, slug)
-
-	var c domain.Collection
-	if err := row.Scan(&c.ID, &c.Slug, &c.Title, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE slug = ?`
+	c, err := scanCollection(r.db.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return &c, nil
+	return c, err
 }
 
 func (r *SQLiteRepository) UpdateCollection(ctx context.Context, collection *domain.Collection) error {
-	query := `UPDATE collections SET slug = ?, title = ?, description = ?, updated_at = ? WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, collection.Slug, collection.Title, collection.Description, collection.UpdatedAt, collection.ID)
+	appearanceJSON, err := json.Marshal(collection.Appearance)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE collections SET slug = ?, title = ?, description = ?, avatar_url = ?, appearance = ?, visibility = ?, password_hash = ?,
+			  publish_at = ?, unpublish_at = ?, is_template = ?, updated_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, query, collection.Slug, collection.Title, collection.Description, collection.AvatarURL, appearanceJSON,
+		collection.Visibility, collection.PasswordHash, nullableTime(collection.PublishAt), nullableTime(collection.UnpublishAt),
+		collection.IsTemplate, collection.UpdatedAt, collection.ID)
 	return err
 }
 
-func (r *SQLiteRepository) DeleteCollection(ctx context.Context, id int64) error {
-	query := `DELETE FROM collections WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, id)
+// TouchCollection sets the collection's updated_at, e.g. after its items change
+func (r *SQLiteRepository) TouchCollection(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE collections SET updated_at = ? WHERE id = ?`, at, id)
 	return err
 }
 
+// DeleteCollection deletes a collection with its items and page views in one
+// transaction. Foreign keys aren't enforced, so ON DELETE CASCADE doesn't apply.
+func (r *SQLiteRepository) DeleteCollection(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM collection_items WHERE collection_id = ?`,
+		`DELETE FROM collection_views WHERE collection_id = ?`,
+		`DELETE FROM collections WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (r *SQLiteRepository) ListCollections(ctx context.Context, limit, offset int, filters map[string]interface{}) ([]domain.Collection, error) {
-	query := `SELECT id, slug, title, description, created_at, updated_at FROM collections`
+	query := `SELECT ` + collectionColumns + ` FROM collections`
 	args := []interface{}{}
 
+	conditions := []string{}
 	if search, ok := filters["search"].(string); ok && search != "" {
-		query += " WHERE title LIKE ? OR slug LIKE ?"
+		conditions = append(conditions, "(title LIKE ? OR slug LIKE ?)")
 		args = append(args, "%"+search+"%", "%"+search+"%")
 	}
+	if visibility, ok := filters["visibility"].(string); ok && visibility != "" {
+		conditions = append(conditions, "visibility = ?")
+		args = append(args, visibility)
+	}
+	if isTemplate, ok := filters["is_template"].(bool); ok {
+		conditions = append(conditions, "is_template = ?")
+		args = append(args, isTemplate)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
 
 	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
@@ -515,58 +1066,14 @@ func (r *SQLiteRepository) ListCollections(ctx context.Context, limit, offset in
 
 	var collections []domain.Collection
 	for rows.Next() {
-		var c domain.Collection
-		if err := rows.Scan(&c.ID, &c.Slug, &c.Title, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		c, err := scanCollection(rows)
+		if err != nil {
 			return nil, err
 		}
-		collections = append(collections, c)
+		collections = append(collections, *c)
 	}
 	return collections, nil
 }
 
-func (r *SQLiteRepository) AddLinkToCollection(ctx context.Context, collectionID, linkID int64) error {
-	query := `INSERT INTO collection_links (collection_id, link_id) VALUES (?, ?)`
-	_, err := r.db.ExecContext(ctx, query, collectionID, linkID)
-	return err
-}
-
-func (r *SQLiteRepository) RemoveLinkFromCollection(ctx context.Context, collectionID, linkID int64) error {
-	query := `DELETE FROM collection_links WHERE collection_id = ? AND link_id = ?`
-	_, err := r.db.ExecContext(ctx, query, collectionID, linkID)
-	return err
-}
-
-func (r *SQLiteRepository) UpdateLinkOrder(ctx context.Context, collectionID, linkID int64, newOrder int) error {
-	query := `UPDATE collection_links SET sort_order = ? WHERE collection_id = ? AND link_id = ?`
-	_, err := r.db.ExecContext(ctx, query, newOrder, collectionID, linkID)
-	return err
-}
-
-func (r *SQLiteRepository) GetCollectionLinks(ctx context.Context, collectionID int64) ([]domain.Link, error) {
-	query := `SELECT l.id, l.original_url, l.short_code, l.title, l.tags, l.created_at, l.updated_at
-			  FROM links l
-			  JOIN collection_links cl ON l.id = cl.link_id
-			  WHERE cl.collection_id = ? AND l.deleted_at IS NULL
-			  ORDER BY cl.sort_order ASC`
-
-	rows, err := r.db.QueryContext(ctx, query, collectionID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var links []domain.Link
-	for rows.Next() {
-		var l domain.Link
-		var tagsJSON []byte
-		if err := rows.Scan(&l.ID, &l.OriginalURL, &l.ShortCode, &l.Title, &tagsJSON, &l.CreatedAt, &l.UpdatedAt); err != nil {
-			return nil, err
-		}
-		_ = json.Unmarshal(tagsJSON, &l.Tags)
-		links = append(links, l)
-	}
-	return links, nil
-}
-
 // Ensure interface compliance
 var _ ports.LinkRepository = (*SQLiteRepository)(nil)
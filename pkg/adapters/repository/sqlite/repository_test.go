@@ -0,0 +1,435 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// newTestRepository opens a migrated database in a temporary directory
+func newTestRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	repo, err := NewSQLiteRepository("file:" + filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.db.Close() })
+	return repo
+}
+
+// createTestLink stores a link created at the given time
+func createTestLink(t *testing.T, repo *SQLiteRepository, code string, tags []string, createdAt time.Time) *domain.Link {
+	t.Helper()
+	link := &domain.Link{OriginalURL: "https://" + code + ".example.com/", ShortCode: code, Tags: tags, CreatedAt: createdAt, UpdatedAt: createdAt}
+	if err := repo.Create(context.Background(), link); err != nil {
+		t.Fatal(err)
+	}
+	return link
+}
+
+func TestGetGeoBreakdown(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	now := time.Now().UTC()
+	promo := createTestLink(t, repo, "promo1", []string{"promo"}, now)
+	other := createTestLink(t, repo, "other1", nil, now)
+
+	for _, visit := range []*domain.Visit{
+		{LinkID: promo.ID, Country: "TH", Region: "Bangkok", City: "Bangkok"},
+		{LinkID: promo.ID, Country: "TH", Region: "Bangkok", City: "Bangkok"},
+		{LinkID: promo.ID, Country: "US", Region: "California", City: "San Jose"},
+		{LinkID: promo.ID}, // No location resolved
+		{LinkID: other.ID, Country: "DE", Region: "Berlin", City: "Berlin"},
+	} {
+		visit.CreatedAt = now
+		if err := repo.RecordVisit(ctx, visit); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	geo, err := repo.GetGeoBreakdown(ctx, 10, map[string]interface{}{"tag": "promo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCountries := map[string]int64{"TH": 2, "US": 1, "Unknown": 1}
+	if len(geo.Countries) != len(wantCountries) {
+		t.Errorf("countries = %v, want %v", geo.Countries, wantCountries)
+	}
+	for country, want := range wantCountries {
+		if geo.Countries[country] != want {
+			t.Errorf("countries[%s] = %d, want %d", country, geo.Countries[country], want)
+		}
+	}
+	if geo.Cities["Bangkok, TH"] != 2 || geo.Regions["California, US"] != 1 {
+		t.Errorf("cities = %v, regions = %v, want keys suffixed with the country", geo.Cities, geo.Regions)
+	}
+
+	all, err := repo.GetGeoBreakdown(ctx, 10, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Countries["DE"] != 1 || all.Countries["TH"] != 2 {
+		t.Errorf("unfiltered countries = %v", all.Countries)
+	}
+
+	stats, err := repo.GetLinkStats(ctx, other.ID, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Geo.Countries) != 1 || stats.Geo.Countries["DE"] != 1 {
+		t.Errorf("link countries = %v, want only its own visits", stats.Geo.Countries)
+	}
+}
+
+func TestBackfillReferrersRunsOnce(t *testing.T) {
+	repo := newTestRepository(t)
+	link := createTestLink(t, repo, "ref001", nil, time.Now())
+	insertLegacyVisit := func() int64 {
+		res, err := repo.db.Exec(`INSERT INTO visits (link_id, referer, created_at) VALUES (?, 'https://www.google.com/search?q=x', ?)`,
+			link.ID, time.Now().UTC().Format(sqliteTimeLayout))
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		return id
+	}
+	channelOf := func(id int64) sql.NullString {
+		var channel sql.NullString
+		if err := repo.db.QueryRow(`SELECT referer_channel FROM visits WHERE id = ?`, id).Scan(&channel); err != nil {
+			t.Fatal(err)
+		}
+		return channel
+	}
+
+	// A database from before the backfill flag existed
+	legacy := insertLegacyVisit()
+	if _, err := repo.db.Exec(`DELETE FROM rollup_state WHERE name = ?`, referrersBackfilledKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := backfillReferrers(repo.db); err != nil {
+		t.Fatal(err)
+	}
+	if channel := channelOf(legacy); channel.String != domain.ChannelSearch {
+		t.Errorf("backfilled channel = %v, want %s", channel, domain.ChannelSearch)
+	}
+
+	later := insertLegacyVisit()
+	if err := backfillReferrers(repo.db); err != nil {
+		t.Fatal(err)
+	}
+	if channel := channelOf(later); channel.Valid {
+		t.Errorf("backfill ran again and set %q", channel.String)
+	}
+}
+
+func TestCompactVisitsKeepsStats(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	today := floorDay(time.Now())
+	link := createTestLink(t, repo, "roll01", nil, today.AddDate(0, 0, -3))
+	other := createTestLink(t, repo, "roll02", nil, today.AddDate(0, 0, -3))
+
+	visit := func(linkID int64, at time.Time, ip, referer string) *domain.Visit {
+		return &domain.Visit{LinkID: linkID, IPHash: ip, Referer: referer, RefererDomain: "ref.example", RefererChannel: domain.ChannelReferral,
+			Country: "TH", UTMSource: "news", CreatedAt: at}
+	}
+	var visits []*domain.Visit
+	dayOne, dayTwo := today.AddDate(0, 0, -2).Add(3*time.Hour), today.AddDate(0, 0, -1).Add(5*time.Hour)
+	for i := 0; i < 5; i++ {
+		visits = append(visits, visit(link.ID, dayOne, "a", "https://ref.example/top"))
+	}
+	for i := 0; i < maxRollupReferers+5; i++ { // More referers than rollups keep
+		visits = append(visits, visit(link.ID, dayOne, "b", fmt.Sprintf("https://ref.example/%d", i)))
+	}
+	visits = append(visits,
+		visit(link.ID, dayTwo, "a", "https://ref.example/top"), // Same visitor on another day
+		visit(link.ID, today.Add(time.Minute), "c", ""),        // Not compacted
+		visit(other.ID, dayTwo, "d", ""),
+	)
+	if err := repo.RecordVisits(ctx, visits); err != nil {
+		t.Fatal(err)
+	}
+
+	type snapshot struct {
+		stats      *domain.LinkStats
+		top        []domain.Link
+		total      int64
+		clicks     map[int64]int64
+		dayClicks  map[int64]int64
+		dayReferer int
+	}
+	take := func() snapshot {
+		t.Helper()
+		var s snapshot
+		var err error
+		if s.stats, err = repo.GetLinkStats(ctx, link.ID, map[string]interface{}{"top": 100}); err != nil {
+			t.Fatal(err)
+		}
+		if s.top, s.total, err = repo.GetDashboardStats(ctx, 10, map[string]interface{}{}); err != nil {
+			t.Fatal(err)
+		}
+		if s.clicks, err = repo.CountClicksByLink(ctx, 0, time.Time{}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if s.dayClicks, err = repo.CountClicksByLink(ctx, link.ID, floorDay(dayOne), floorDay(dayTwo)); err != nil {
+			t.Fatal(err)
+		}
+		day, err := repo.GetLinkStats(ctx, link.ID, map[string]interface{}{"top": 100, "start_date": floorDay(dayOne), "end_date": floorDay(dayTwo)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.dayReferer = len(day.Referrers)
+		return s
+	}
+
+	before := take()
+	if n, err := repo.CompactVisits(ctx, today, 10); err != nil || n != 2 {
+		t.Fatalf("CompactVisits = %d, %v; want 2 days", n, err)
+	}
+	after := take()
+
+	if after.stats.TotalClicks != before.stats.TotalClicks || after.stats.UniqueVisitors != before.stats.UniqueVisitors {
+		t.Errorf("totals = %d clicks, %d uniques; want %d, %d", after.stats.TotalClicks, after.stats.UniqueVisitors,
+			before.stats.TotalClicks, before.stats.UniqueVisitors)
+	}
+	if before.stats.UniqueVisitors != 4 {
+		t.Errorf("uniques = %d, want 4 (summed per day)", before.stats.UniqueVisitors)
+	}
+	if !maps.Equal(after.stats.Channels, before.stats.Channels) || !maps.Equal(after.stats.Geo.Countries, before.stats.Geo.Countries) ||
+		!maps.Equal(after.stats.UTM.Sources, before.stats.UTM.Sources) || !maps.Equal(after.stats.ReferrerDomains, before.stats.ReferrerDomains) {
+		t.Errorf("breakdowns changed: %+v, want %+v", after.stats, before.stats)
+	}
+	if got := after.stats.Referrers["https://ref.example/top"]; got != before.stats.Referrers["https://ref.example/top"] || got != 6 {
+		t.Errorf("top referer = %d, want 6", got)
+	}
+	if before.dayReferer != maxRollupReferers+6 || after.dayReferer != maxRollupReferers {
+		t.Errorf("referers of the day = %d before, %d after; want %d, %d", before.dayReferer, after.dayReferer, maxRollupReferers+6, maxRollupReferers)
+	}
+	if after.total != before.total || len(after.top) != len(before.top) || after.top[0].Clicks != before.top[0].Clicks {
+		t.Errorf("dashboard = %d, %v; want %d, %v", after.total, after.top, before.total, before.top)
+	}
+	if !maps.Equal(after.clicks, before.clicks) || !maps.Equal(after.dayClicks, before.dayClicks) {
+		t.Errorf("clicks by link = %v, %v; want %v, %v", after.clicks, after.dayClicks, before.clicks, before.dayClicks)
+	}
+
+	// A visit recorded for a compacted day only reaches the link's counter
+	if err := repo.RecordVisit(ctx, visit(link.ID, dayOne, "e", "")); err != nil {
+		t.Fatal(err)
+	}
+	late := take()
+	if late.stats.TotalClicks != after.stats.TotalClicks || !maps.Equal(late.clicks, after.clicks) {
+		t.Errorf("late visit counted in stats: %d clicks, %v", late.stats.TotalClicks, late.clicks)
+	}
+	if late.total != after.total+1 {
+		t.Errorf("dashboard total = %d, want %d", late.total, after.total+1)
+	}
+}
+
+func TestDuplicateClicksStopAtPartialHour(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	hour := floorDay(time.Now()).AddDate(0, 0, -1).Add(10 * time.Hour)
+	link := createTestLink(t, repo, "dup01", nil, hour.AddDate(0, 0, -1))
+
+	var visits []*domain.Visit
+	for _, at := range []time.Duration{10 * time.Minute, 80 * time.Minute, 100 * time.Minute} {
+		visits = append(visits, &domain.Visit{LinkID: link.ID, IPHash: "a", Duplicate: true, CreatedAt: hour.Add(at)})
+	}
+	if err := repo.RecordVisits(ctx, visits); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		end  time.Duration
+		want int64
+	}{
+		{end: 90 * time.Minute, want: 1}, // The repeat at 1:40 is after the end
+		{end: 2 * time.Hour, want: 3},
+	} {
+		stats, err := repo.GetLinkStats(ctx, link.ID, map[string]interface{}{"start_date": hour, "end_date": hour.Add(tc.end)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.DuplicateClicks != tc.want {
+			t.Errorf("duplicates until +%v = %d, want %d", tc.end, stats.DuplicateClicks, tc.want)
+		}
+	}
+}
+
+func TestDeleteVisitsMovesPruneWatermark(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	today := floorDay(time.Now())
+	link := createTestLink(t, repo, "prune1", nil, today.AddDate(0, 0, -3))
+	for _, at := range []time.Time{today.AddDate(0, 0, -3), today.AddDate(0, 0, -2), today} {
+		if err := repo.RecordVisit(ctx, &domain.Visit{LinkID: link.ID, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pruned, err := repo.PrunedUntil(ctx); err != nil || !pruned.IsZero() {
+		t.Fatalf("PrunedUntil = %v, %v; want zero before pruning", pruned, err)
+	}
+	if n, err := repo.DeleteVisits(ctx, today.AddDate(0, 0, -3), today.AddDate(0, 0, -1)); err != nil || n != 2 {
+		t.Fatalf("DeleteVisits = %d, %v; want 2", n, err)
+	}
+	// Deleting an older range doesn't move the watermark back
+	if _, err := repo.DeleteVisits(ctx, today.AddDate(0, 0, -5), today.AddDate(0, 0, -4)); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := repo.PrunedUntil(ctx); err != nil || !pruned.Equal(today.AddDate(0, 0, -1)) {
+		t.Errorf("PrunedUntil = %v, %v; want %v", pruned, err, today.AddDate(0, 0, -1))
+	}
+}
+
+func TestCreateWebhookDeliveriesInBatches(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	webhook := &domain.Webhook{URL: "https://hooks.example.com/", Events: []string{domain.EventLinkClicked}, Active: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	deliveries := make([]*domain.WebhookDelivery, deliveryInsertBatch+1) // Spans two INSERTs
+	for i := range deliveries {
+		deliveries[i] = &domain.WebhookDelivery{WebhookID: webhook.ID, Event: domain.EventLinkClicked, Payload: []byte(`{}`),
+			Status: domain.DeliveryPending, NextAttemptAt: &now, CreatedAt: now, UpdatedAt: now}
+	}
+	if err := repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := repo.CountWebhookDeliveries(ctx, webhook.ID, map[string]interface{}{"status": domain.DeliveryPending}); err != nil || n != int64(len(deliveries)) {
+		t.Errorf("pending deliveries = %d, %v; want %d", n, err, len(deliveries))
+	}
+}
+
+func TestDeleteFinishedDeliveries(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	webhook := &domain.Webhook{URL: "https://hooks.example.com/", Events: []string{domain.EventLinkClicked}, Active: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -10)
+	var deliveries []*domain.WebhookDelivery
+	for _, d := range []struct {
+		status  string
+		updated time.Time
+	}{
+		{domain.DeliverySucceeded, old},
+		{domain.DeliveryFailed, old},
+		{domain.DeliveryPending, old}, // Still retrying
+		{domain.DeliverySucceeded, now},
+	} {
+		deliveries = append(deliveries, &domain.WebhookDelivery{WebhookID: webhook.ID, Event: domain.EventLinkClicked, Payload: []byte(`{}`),
+			Status: d.status, CreatedAt: d.updated, UpdatedAt: d.updated})
+	}
+	if err := repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repo.DeleteFinishedDeliveries(ctx, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d deliveries, want 2", deleted)
+	}
+	left, err := repo.ListWebhookDeliveries(ctx, webhook.ID, 10, 0, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].Status != domain.DeliverySucceeded || left[1].Status != domain.DeliveryPending {
+		t.Errorf("deliveries left = %+v, want the recent and the pending one", left)
+	}
+}
+
+func TestDeleteCollectionDeletesItemsAndViews(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	now := time.Now()
+	link := createTestLink(t, repo, "coll01", nil, now)
+
+	var ids []int64
+	for _, slug := range []string{"gone", "kept"} {
+		collection := &domain.Collection{Slug: slug, Title: slug, Visibility: domain.VisibilityPublic, CreatedAt: now, UpdatedAt: now}
+		if err := repo.CreateCollection(ctx, collection); err != nil {
+			t.Fatal(err)
+		}
+		item := &domain.CollectionItem{CollectionID: collection.ID, Type: domain.ItemLink, LinkID: link.ID, CreatedAt: now, UpdatedAt: now}
+		if err := repo.CreateCollectionItem(ctx, item, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.RecordCollectionView(ctx, &domain.CollectionView{CollectionID: collection.ID, IPHash: "a", CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, collection.ID)
+	}
+
+	if err := repo.DeleteCollection(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"collection_items", "collection_views"} {
+		counts := map[int64]int{}
+		for _, id := range ids {
+			var n int
+			if err := repo.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE collection_id = ?`, id).Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			counts[id] = n
+		}
+		if counts[ids[0]] != 0 || counts[ids[1]] != 1 {
+			t.Errorf("%s rows per collection = %v, want only the kept collection's", table, counts)
+		}
+	}
+}
+
+func TestCollectionStatsSkipDeletedLinks(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	now := time.Now()
+	kept := createTestLink(t, repo, "stat01", nil, now)
+	deleted := createTestLink(t, repo, "stat02", nil, now)
+
+	collection := &domain.Collection{Slug: "jane", Title: "Jane", Visibility: domain.VisibilityPublic, CreatedAt: now, UpdatedAt: now}
+	if err := repo.CreateCollection(ctx, collection); err != nil {
+		t.Fatal(err)
+	}
+	var visits []*domain.Visit
+	for _, link := range []*domain.Link{kept, deleted} {
+		item := &domain.CollectionItem{CollectionID: collection.ID, Type: domain.ItemLink, LinkID: link.ID, CreatedAt: now, UpdatedAt: now}
+		if err := repo.CreateCollectionItem(ctx, item, 0); err != nil {
+			t.Fatal(err)
+		}
+		visits = append(visits, &domain.Visit{LinkID: link.ID, IPHash: "a", CollectionItemID: item.ID, CreatedAt: now})
+	}
+	if err := repo.RecordVisits(ctx, visits); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if touched, err := repo.GetCollection(ctx, collection.ID); err != nil || !touched.UpdatedAt.After(collection.UpdatedAt) {
+		t.Errorf("collection updated_at after deleting its link = %v, %v; want it bumped", touched.UpdatedAt, err)
+	}
+
+	stats, err := repo.GetCollectionStats(ctx, collection.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Items) != 1 || stats.Items[0].LinkID != kept.ID {
+		t.Errorf("items = %+v, want only the kept link's", stats.Items)
+	}
+	if stats.Clicks != 1 || len(stats.Daily) != 1 || stats.Daily[0].Clicks != 1 {
+		t.Errorf("clicks = %d, daily = %+v; want 1 click, without the deleted link's", stats.Clicks, stats.Daily)
+	}
+}
@@ -0,0 +1,345 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// Rollups pre-aggregate raw visits per link and UTC day (plus hourly clicks for
+// timelines). Whole days are compacted by CompactVisits; the last compacted day
+// boundary is stored as a watermark in rollup_state. Stats read rollups for whole
+// days before the watermark and raw visits for everything else.
+//
+// Clicks and the other breakdowns match the raw data, with these approximations:
+//   - only the top maxRollupReferers raw referers are kept per link and day, so
+//     rarer referers of compacted days are missing from the referrer counts
+//   - unique visitors are sums of per-day uniques; raw queries count them the same
+//     way, so a visitor returning on several days counts once per day either way
+//   - visits recorded for a day after it was compacted (behind the watermark) are
+//     left out of stats, since compacted days are read from rollups only
+
+const (
+	rollupWatermarkKey = "visits_compacted_until"
+	pruneWatermarkKey  = "visits_pruned_until"
+)
+
+// maxRollupReferers bounds how many raw referers are kept per link and day
+const maxRollupReferers = 20
+
+// visitDimensions maps breakdown dimensions to the visits expression they group by.
+// The same expressions are used for raw queries and when compacting rollups.
+var visitDimensions = map[string]string{
+	"referer":         "COALESCE(referer, '')",
+	"referer_domain":  "COALESCE(referer_domain, '')",
+	"referer_channel": "COALESCE(referer_channel, '')",
+	"country":         "COALESCE(country, '')",
+	"region":          "CASE WHEN COALESCE(region, '') = '' THEN '' ELSE region || ', ' || COALESCE(country, '') END",
+	"city":            "CASE WHEN COALESCE(city, '') = '' THEN '' ELSE city || ', ' || COALESCE(country, '') END",
+	"utm_source":      "COALESCE(utm_source, '')",
+	"utm_medium":      "COALESCE(utm_medium, '')",
+	"utm_campaign":    "COALESCE(utm_campaign, '')",
+}
+
+func migrateRollups(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS visit_rollups_hourly (
+		link_id INTEGER NOT NULL,
+		bucket TEXT NOT NULL, -- hour start, UTC
+		clicks INTEGER NOT NULL DEFAULT 0,
+		uniques INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, bucket)
+	);
+	CREATE INDEX IF NOT EXISTS idx_visit_rollups_hourly_bucket ON visit_rollups_hourly(bucket);
+
+	CREATE TABLE IF NOT EXISTS visit_rollups_daily (
+		link_id INTEGER NOT NULL,
+		bucket TEXT NOT NULL, -- day start, UTC
+		clicks INTEGER NOT NULL DEFAULT 0,
+		uniques INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, bucket)
+	);
+	CREATE INDEX IF NOT EXISTS idx_visit_rollups_daily_bucket ON visit_rollups_daily(bucket);
+
+	CREATE TABLE IF NOT EXISTS visit_rollup_dimensions (
+		link_id INTEGER NOT NULL,
+		bucket TEXT NOT NULL, -- day start, UTC
+		dimension TEXT NOT NULL,
+		value TEXT NOT NULL,
+		clicks INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, dimension, bucket, value)
+	);
+	CREATE INDEX IF NOT EXISTS idx_visit_rollup_dimensions_bucket ON visit_rollup_dimensions(bucket);
+
+	-- Repeat visits dropped by the dedup window are only counted
+	CREATE TABLE IF NOT EXISTS visit_duplicates (
+		link_id INTEGER NOT NULL,
+		bucket TEXT NOT NULL, -- hour start, UTC
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, bucket)
+	);
+
+	CREATE TABLE IF NOT EXISTS rollup_state (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// visitScope selects visits to aggregate: raw rows matching rawWhere plus, when
+// useRollup is set, rollup rows (aliased ru) matching rollupWhere.
+type visitScope struct {
+	rawFrom     string
+	rawWhere    string // including "WHERE"
+	rawArgs     []interface{}
+	useRollup   bool
+	rollupJoin  string
+	rollupWhere string // without "WHERE"
+	rollupArgs  []interface{}
+}
+
+// linkVisitScope scopes a single link's visits for the given stats filters
+func (r *SQLiteRepository) linkVisitScope(ctx context.Context, linkID int64, filters map[string]interface{}) (*visitScope, error) {
+	whereClause, args := visitFilterClause(linkID, filters)
+	scope := &visitScope{rawFrom: "visits", rawWhere: whereClause, rawArgs: args}
+
+	for _, key := range domain.StatsDimensionFilters {
+		if value, ok := filters[key].(string); ok && value != "" {
+			return scope, nil
+		}
+	}
+
+	watermark, err := r.CompactedUntil(ctx)
+	if err != nil || watermark.IsZero() {
+		return scope, err
+	}
+
+	// Only whole UTC days inside the range and before the watermark come from rollups
+	var rollupStart time.Time
+	if start, ok := filters["start_date"].(time.Time); ok {
+		rollupStart = ceilDay(start)
+	}
+	rollupEnd := watermark
+	if end, ok := filters["end_date"].(time.Time); ok && floorDay(end).Before(rollupEnd) {
+		rollupEnd = floorDay(end)
+	}
+	if !rollupStart.Before(rollupEnd) {
+		return scope, nil
+	}
+
+	startStr, endStr := rollupStart.Format(sqliteTimeLayout), rollupEnd.Format(sqliteTimeLayout)
+	scope.rawWhere += " AND (created_at < ? OR created_at >= ?)"
+	scope.rawArgs = append(scope.rawArgs, startStr, endStr)
+	scope.useRollup = true
+	scope.rollupWhere = "ru.link_id = ? AND ru.bucket >= ? AND ru.bucket < ?"
+	scope.rollupArgs = []interface{}{linkID, startStr, endStr}
+	return scope, nil
+}
+
+// linksVisitScope scopes all-time visits of the links matching linkWhere (on links aliased l)
+func (r *SQLiteRepository) linksVisitScope(ctx context.Context, linkWhere string, linkArgs []interface{}) (*visitScope, error) {
+	scope := &visitScope{
+		rawFrom:  "visits v JOIN links l ON l.id = v.link_id",
+		rawWhere: "WHERE " + linkWhere,
+		rawArgs:  linkArgs,
+	}
+
+	watermark, err := r.CompactedUntil(ctx)
+	if err != nil || watermark.IsZero() {
+		return scope, err
+	}
+
+	watermarkStr := watermark.Format(sqliteTimeLayout)
+	scope.rawWhere += " AND v.created_at >= ?"
+	scope.rawArgs = append(append([]interface{}{}, linkArgs...), watermarkStr)
+	scope.useRollup = true
+	scope.rollupJoin = "JOIN links l ON l.id = ru.link_id"
+	scope.rollupWhere = linkWhere + " AND ru.bucket < ?"
+	scope.rollupArgs = append(append([]interface{}{}, linkArgs...), watermarkStr)
+	return scope, nil
+}
+
+// scopeTotals returns clicks and unique visitors in scope. Uniques are counted per
+// UTC day (as in the daily rollups) and summed, so a visitor returning on several days
+// counts once per day.
+func (r *SQLiteRepository) scopeTotals(ctx context.Context, scope *visitScope) (clicks, uniques int64, err error) {
+	query := "SELECT COUNT(*), COUNT(DISTINCT date(created_at) || '|' || ip_hash) FROM " + scope.rawFrom + " " + scope.rawWhere
+	if err = r.db.QueryRowContext(ctx, query, scope.rawArgs...).Scan(&clicks, &uniques); err != nil {
+		return 0, 0, err
+	}
+	if !scope.useRollup {
+		return clicks, uniques, nil
+	}
+
+	var rollupClicks, rollupUniques int64
+	query = "SELECT COALESCE(SUM(ru.clicks), 0), COALESCE(SUM(ru.uniques), 0) FROM visit_rollups_daily ru " +
+		scope.rollupJoin + " WHERE " + scope.rollupWhere
+	if err = r.db.QueryRowContext(ctx, query, scope.rollupArgs...).Scan(&rollupClicks, &rollupUniques); err != nil {
+		return 0, 0, err
+	}
+	return clicks + rollupClicks, uniques + rollupUniques, nil
+}
+
+// countBy returns the top `limit` values of a dimension with their visit counts (all values if limit < 0).
+// Empty values are reported under emptyLabel.
+func (r *SQLiteRepository) countBy(ctx context.Context, scope *visitScope, dimension string, limit int, emptyLabel string) (map[string]int64, error) {
+	query := "SELECT " + visitDimensions[dimension] + " AS k, COUNT(*) AS c FROM " + scope.rawFrom + " " + scope.rawWhere + " GROUP BY k"
+	args := append([]interface{}{}, scope.rawArgs...)
+	if scope.useRollup {
+		query += " UNION ALL SELECT ru.value AS k, SUM(ru.clicks) AS c FROM visit_rollup_dimensions ru " + scope.rollupJoin +
+			" WHERE ru.dimension = ? AND " + scope.rollupWhere + " GROUP BY ru.value"
+		args = append(append(args, dimension), scope.rollupArgs...)
+	}
+	query = "SELECT k, SUM(c) AS total FROM (" + query + ") GROUP BY k ORDER BY total DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		if key == "" {
+			key = emptyLabel
+		}
+		counts[key] += count
+	}
+	return counts, rows.Err()
+}
+
+// CompactVisits rolls up raw visits of every whole UTC day before `until` that
+// hasn't been compacted yet, at most maxDays per call. It returns the number of
+// days compacted; callers loop until it returns 0.
+func (r *SQLiteRepository) CompactVisits(ctx context.Context, until time.Time, maxDays int) (int, error) {
+	watermark, err := r.CompactedUntil(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if watermark.IsZero() {
+		// First run: start at the oldest visit
+		oldest, err := r.OldestVisitTime(ctx)
+		if err != nil {
+			return 0, err
+		}
+		watermark = floorDay(until)
+		if !oldest.IsZero() {
+			watermark = floorDay(oldest)
+		}
+	}
+
+	days := 0
+	for day := watermark; days < maxDays && !day.AddDate(0, 0, 1).After(until); day = day.AddDate(0, 0, 1) {
+		if err := r.compactDay(ctx, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	if days == 0 {
+		// Nothing to compact yet, but persist the initial watermark
+		return 0, r.setCompactedUntil(ctx, r.db, watermark)
+	}
+	return days, nil
+}
+
+// compactDay (re)builds the rollups of one UTC day and advances the watermark past it
+func (r *SQLiteRepository) compactDay(ctx context.Context, day time.Time) error {
+	start, end := day.Format(sqliteTimeLayout), day.AddDate(0, 0, 1).Format(sqliteTimeLayout)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"visit_rollups_hourly", "visit_rollups_daily", "visit_rollup_dimensions"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE bucket >= ? AND bucket < ?`, start, end); err != nil {
+			return err
+		}
+	}
+
+	statements := []string{
+		`INSERT INTO visit_rollups_hourly (link_id, bucket, clicks, uniques)
+			SELECT link_id, strftime('%Y-%m-%d %H:00:00', created_at), COUNT(*), COUNT(DISTINCT ip_hash)
+			FROM visits WHERE created_at >= ? AND created_at < ? GROUP BY 1, 2`,
+		`INSERT INTO visit_rollups_daily (link_id, bucket, clicks, uniques)
+			SELECT link_id, ?1, COUNT(*), COUNT(DISTINCT ip_hash)
+			FROM visits WHERE created_at >= ?1 AND created_at < ?2 GROUP BY link_id`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, start, end); err != nil {
+			return err
+		}
+	}
+
+	for dimension, expr := range visitDimensions {
+		stmt := `INSERT INTO visit_rollup_dimensions (link_id, bucket, dimension, value, clicks)
+			SELECT link_id, ?1, ?3, ` + expr + `, COUNT(*)
+			FROM visits WHERE created_at >= ?1 AND created_at < ?2 GROUP BY 1, 4`
+		args := []interface{}{start, end, dimension}
+		if dimension == "referer" {
+			// Raw referers are unbounded; keep the top ones per link
+			stmt = `INSERT INTO visit_rollup_dimensions (link_id, bucket, dimension, value, clicks)
+				SELECT link_id, ?1, ?3, k, c FROM (
+					SELECT link_id, ` + expr + ` AS k, COUNT(*) AS c,
+						ROW_NUMBER() OVER (PARTITION BY link_id ORDER BY COUNT(*) DESC) AS rn
+					FROM visits WHERE created_at >= ?1 AND created_at < ?2 GROUP BY link_id, k
+				) WHERE rn <= ?4`
+			args = append(args, maxRollupReferers)
+		}
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return err
+		}
+	}
+
+	if err := r.setCompactedUntil(ctx, tx, day.AddDate(0, 0, 1)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CompactedUntil returns the watermark: visits before it are rolled up. Zero if never compacted.
+func (r *SQLiteRepository) CompactedUntil(ctx context.Context) (time.Time, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, `SELECT value FROM rollup_state WHERE name = ?`, rollupWatermarkKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(sqliteTimeLayout, value, time.UTC)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *SQLiteRepository) setCompactedUntil(ctx context.Context, db execer, watermark time.Time) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO rollup_state (name, value) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value`, rollupWatermarkKey, watermark.Format(sqliteTimeLayout))
+	return err
+}
+
+func floorDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func ceilDay(t time.Time) time.Time {
+	day := floorDay(t)
+	if day.Equal(t) {
+		return day
+	}
+	return day.AddDate(0, 0, 1)
+}
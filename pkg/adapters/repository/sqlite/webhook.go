@@ -0,0 +1,300 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func migrateWebhooks(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events JSON NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT, -- UTC; NULL once succeeded or failed
+		response_code INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		FOREIGN KEY(webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+const webhookColumns = `id, url, secret, events, active, created_at, updated_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*domain.Webhook, error) {
+	var w domain.Webhook
+	var eventsJSON []byte
+	var createdAt, updatedAt string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal(eventsJSON, &w.Events)
+	w.CreatedAt, _ = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	w.UpdatedAt, _ = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC)
+	return &w, nil
+}
+
+func (r *SQLiteRepository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	eventsJSON, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, `INSERT INTO webhooks (url, secret, events, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		webhook.URL, webhook.Secret, eventsJSON, webhook.Active,
+		webhook.CreatedAt.UTC().Format(sqliteTimeLayout), webhook.UpdatedAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return err
+	}
+	webhook.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r *SQLiteRepository) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error) {
+	w, err := scanWebhook(r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// ListWebhooks supports the filter "active" (bool)
+func (r *SQLiteRepository) ListWebhooks(ctx context.Context, filters map[string]interface{}) ([]domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks`
+	args := []interface{}{}
+	if active, ok := filters["active"].(bool); ok {
+		query += " WHERE active = ?"
+		args = append(args, active)
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []domain.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *SQLiteRepository) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	eventsJSON, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE webhooks SET url = ?, events = ?, active = ?, updated_at = ? WHERE id = ?`,
+		webhook.URL, eventsJSON, webhook.Active, webhook.UpdatedAt.UTC().Format(sqliteTimeLayout), webhook.ID)
+	return err
+}
+
+// DeleteWebhook removes the webhook together with its delivery log
+func (r *SQLiteRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, last_error, created_at, updated_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	var payload string
+	var nextAttempt sql.NullString
+	var createdAt, updatedAt string
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &nextAttempt,
+		&d.ResponseCode, &d.LastError, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if nextAttempt.Valid {
+		t, _ := time.ParseInLocation(sqliteTimeLayout, nextAttempt.String, time.UTC)
+		d.NextAttemptAt = &t
+	}
+	d.CreatedAt, _ = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	d.UpdatedAt, _ = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC)
+	return &d, nil
+}
+
+// nullableTime formats t for storage, or NULL when t is nil
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// deliveryInsertBatch keeps multi-row inserts under SQLite's bound parameter limit (8 per row)
+const deliveryInsertBatch = 500
+
+// CreateWebhookDeliveries queues deliveries with multi-row INSERTs in one transaction.
+// Delivery IDs are not set.
+func (r *SQLiteRepository) CreateWebhookDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(deliveries); start += deliveryInsertBatch {
+		chunk := deliveries[start:min(start+deliveryInsertBatch, len(deliveries))]
+
+		var query strings.Builder
+		query.WriteString(`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at, updated_at) VALUES `)
+		args := make([]interface{}, 0, len(chunk)*8)
+		for i, d := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("(?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, d.WebhookID, d.Event, string(d.Payload), d.Status, d.Attempts, nullableTime(d.NextAttemptAt),
+				d.CreatedAt.UTC().Format(sqliteTimeLayout), d.UpdatedAt.UTC().Format(sqliteTimeLayout))
+		}
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries due at now and pushes their
+// next attempt to now+lease, so a concurrent worker (or one that crashes mid-send)
+// doesn't pick them up again before the lease runs out.
+func (r *SQLiteRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		domain.DeliveryPending, now.UTC().Format(sqliteTimeLayout), limit)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	leaseUntil := now.Add(lease).UTC().Format(sqliteTimeLayout)
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`, leaseUntil, d.ID); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, tx.Commit()
+}
+
+// UpdateWebhookDelivery stores the outcome of an attempt
+func (r *SQLiteRepository) UpdateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, response_code = ?, last_error = ?, updated_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, nullableTime(d.NextAttemptAt), d.ResponseCode, d.LastError, d.UpdatedAt.UTC().Format(sqliteTimeLayout), d.ID)
+	return err
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first. Supports the filter "status" (string).
+func (r *SQLiteRepository) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int, filters map[string]interface{}) ([]domain.WebhookDelivery, error) {
+	where, args := deliveryFilterClause(webhookID, filters)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries `+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *SQLiteRepository) CountWebhookDeliveries(ctx context.Context, webhookID int64, filters map[string]interface{}) (int64, error) {
+	where, args := deliveryFilterClause(webhookID, filters)
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries `+where, args...).Scan(&count)
+	return count, err
+}
+
+// DeleteFinishedDeliveries deletes succeeded and failed deliveries last updated before
+// before, and returns how many were removed. Pending deliveries are kept.
+func (r *SQLiteRepository) DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status IN (?, ?) AND updated_at < ?`,
+		domain.DeliverySucceeded, domain.DeliveryFailed, before.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func deliveryFilterClause(webhookID int64, filters map[string]interface{}) (string, []interface{}) {
+	where := "WHERE webhook_id = ?"
+	args := []interface{}{webhookID}
+	if status, ok := filters["status"].(string); ok && status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	return where, args
+}
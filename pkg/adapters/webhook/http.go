@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// HTTPSender delivers webhook payloads with a plain HTTP POST
+type HTTPSender struct {
+	client *http.Client
+}
+
+// NewHTTPSender returns a sender that refuses to connect to loopback, private, link-local
+// and other internal addresses, so webhook URLs can't reach the server's own network.
+// allowPrivate lifts that for local development.
+func NewHTTPSender(allowPrivate bool) *HTTPSender {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		// Checked on the resolved address of every connection, so DNS can't sidestep it
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			return checkPublicAddress(address)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // A proxy would do the dialing, past the address check
+	transport.DialContext = dialer.DialContext
+
+	return &HTTPSender{
+		client: &http.Client{
+			Transport: transport,
+			// Receivers must answer the URL they registered; redirects count as failures
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// errPrivateAddress rejects connections to addresses webhooks may not reach
+var errPrivateAddress = errors.New("webhook URL resolves to a private or internal address")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), internal to providers' networks
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// checkPublicAddress fails for "ip:port" addresses that aren't public unicast
+func checkPublicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || ip.IsInterfaceLocalMulticast() || sharedAddressSpace.Contains(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// Send POSTs body and returns the response status; the caller's ctx bounds the request
+func (s *HTTPSender) Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "go-url-shortener-webhooks")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
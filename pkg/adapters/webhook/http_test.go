@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false}, // Cloud metadata
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+	for _, tt := range tests {
+		if err := checkPublicAddress(tt.address); (err == nil) != tt.public {
+			t.Errorf("checkPublicAddress(%s) = %v, want public %v", tt.address, err, tt.public)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPSender(false).Send(context.Background(), server.URL, nil, []byte(`{}`)); !errors.Is(err, errPrivateAddress) {
+		t.Errorf("Send to loopback: err = %v, want %v", err, errPrivateAddress)
+	}
+	if code, err := NewHTTPSender(true).Send(context.Background(), server.URL, nil, []byte(`{}`)); err != nil || code != http.StatusNoContent {
+		t.Errorf("Send with private addresses allowed = %d, %v", code, err)
+	}
+}
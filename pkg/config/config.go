@@ -2,36 +2,78 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port               string
-	DatabaseURL        string
-	AppEnv             string
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURL  string
-	JWTSecret          string
-	FrontendURL        string
-	AllowedEmails      []string
+	Port                 string
+	DatabaseURL          string
+	AppEnv               string
+	GoogleClientID       string
+	GoogleClientSecret   string
+	GoogleRedirectURL    string
+	JWTSecret            string
+	FrontendURL          string
+	BaseURL              string // Public scheme and host for absolute URLs in feeds and embeds, e.g. https://sho.rt; empty uses the request's Host
+	AllowedEmails        []string
+	GeoIPDBPath          string        // MaxMind City .mmdb file; empty disables location lookup
+	TrustedProxies       []string      // CIDRs or IPs allowed to set X-Forwarded-For / Forwarded / X-Real-IP
+	TrackedParams        []string      // Extra query parameters kept on visits besides utm_*
+	RollupInterval       time.Duration // How often the server compacts visits into rollups; 0 disables
+	VisitRetentionDays   int           // Raw visits older than this are pruned; 0 keeps them forever
+	VisitArchiveDir      string        // Pruned visits are archived here as .ndjson.gz; empty deletes them
+	VisitQueueSize       int           // Buffered visits awaiting a batch write; 0 writes each visit synchronously
+	VisitBatchSize       int           // Max visits per batch insert
+	VisitFlushInterval   time.Duration // Max time a visit waits in the queue
+	VisitDedupSeconds    int           // Repeat visits (same link, visitor and user agent) within this many seconds count as duplicates; 0 disables
+	ClickIDParam         string        // Query parameter carrying the click ID on redirects; empty disables click IDs
+	WebhookAllowPrivate  bool          // Let webhooks target loopback and private addresses (local development)
+	WebhookRetentionDays int           // Succeeded and failed deliveries older than this are deleted; 0 keeps them forever
+	AlertInterval        time.Duration // How often the server evaluates alert rules; 0 disables
+	SMTPHost             string        // Mail server for email alerts; empty disables email
+	SMTPPort             string
+	SMTPUsername         string // Empty sends without AUTH
+	SMTPPassword         string
+	SMTPFrom             string
 }
 
 func Load() *Config {
 	_ = godotenv.Load() // Ignore error if .env not found (e.g. prod)
 
 	return &Config{
-		Port:               getEnv("PORT", "8080"),
-		DatabaseURL:        getEnv("DATABASE_URL", "file:db.sqlite"),
-		AppEnv:             getEnv("APP_ENV", "local"),
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
-		JWTSecret:          getEnv("JWT_SECRET", "secret"),
-		FrontendURL:        getEnv("FRONTEND_URL", "http://localhost:8080/dashboard"),
-		AllowedEmails:      getEnvAsSlice("ALLOWED_EMAILS", []string{}),
+		Port:                 getEnv("PORT", "8080"),
+		DatabaseURL:          getEnv("DATABASE_URL", "file:db.sqlite"),
+		AppEnv:               getEnv("APP_ENV", "local"),
+		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL:    getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
+		JWTSecret:            getEnv("JWT_SECRET", "secret"),
+		FrontendURL:          getEnv("FRONTEND_URL", "http://localhost:8080/dashboard"),
+		BaseURL:              strings.TrimSuffix(getEnv("BASE_URL", ""), "/"),
+		AllowedEmails:        getEnvAsSlice("ALLOWED_EMAILS", []string{}),
+		GeoIPDBPath:          getEnv("GEOIP_DB_PATH", ""),
+		TrustedProxies:       getEnvAsSlice("TRUSTED_PROXIES", []string{}),
+		TrackedParams:        getEnvAsSlice("TRACKED_QUERY_PARAMS", []string{}),
+		RollupInterval:       getEnvAsDuration("ROLLUP_INTERVAL", 15*time.Minute),
+		VisitRetentionDays:   getEnvAsInt("VISIT_RETENTION_DAYS", 0),
+		VisitArchiveDir:      getEnv("VISIT_ARCHIVE_DIR", ""),
+		VisitQueueSize:       getEnvAsInt("VISIT_QUEUE_SIZE", 10000),
+		VisitBatchSize:       getEnvAsInt("VISIT_BATCH_SIZE", 500),
+		VisitFlushInterval:   getEnvAsDuration("VISIT_FLUSH_INTERVAL", time.Second),
+		VisitDedupSeconds:    getEnvAsInt("VISIT_DEDUP_SECONDS", 10),
+		ClickIDParam:         getEnv("CLICK_ID_PARAM", "clid"),
+		WebhookAllowPrivate:  getEnv("WEBHOOK_ALLOW_PRIVATE", "") == "true",
+		WebhookRetentionDays: getEnvAsInt("WEBHOOK_RETENTION_DAYS", 7),
+		AlertInterval:        getEnvAsDuration("ALERT_INTERVAL", 5*time.Minute),
+		SMTPHost:             getEnv("SMTP_HOST", ""),
+		SMTPPort:             getEnv("SMTP_PORT", "587"),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:             getEnv("SMTP_FROM", ""),
 	}
 }
 
@@ -42,6 +84,24 @@ func getEnvAsSlice(key string, fallback []string) []string {
 	return fallback
 }
 
+func getEnvAsInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists && value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists && value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// Click aggregate groupings
+const (
+	AggregateByTag    = "tag"
+	AggregateByDomain = "domain"
+	AggregateByMonth  = "month"
+)
+
+// AggregateGroupings lists the supported click aggregate groupings
+var AggregateGroupings = []string{AggregateByTag, AggregateByDomain, AggregateByMonth}
+
+// UntaggedKey groups links without tags in tag aggregates
+const UntaggedKey = "(untagged)"
+
+// ClickAggregate is the clicks on every link sharing a tag, destination domain or
+// creation month
+type ClickAggregate struct {
+	Key    string `json:"key"`
+	Links  int64  `json:"links"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Domain is the normalized host of the link's destination (lowercase, without "www.")
+func (l *Link) Domain() string {
+	return referrerHost(l.OriginalURL)
+}
+
+// AggregateClicks groups links and their clicks by grouping. A link with several tags
+// counts toward each of them. Months ("2006-01") are the creation month in loc and are
+// returned oldest first; tags and domains are returned by clicks, most first.
+func AggregateClicks(links []Link, clicks map[int64]int64, groupBy string, loc *time.Location) []ClickAggregate {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	groups := make(map[string]*ClickAggregate)
+	add := func(key string, linkClicks int64) {
+		g, ok := groups[key]
+		if !ok {
+			g = &ClickAggregate{Key: key}
+			groups[key] = g
+		}
+		g.Links++
+		g.Clicks += linkClicks
+	}
+
+	for i := range links {
+		link := &links[i]
+		linkClicks := clicks[link.ID]
+		switch groupBy {
+		case AggregateByTag:
+			if len(link.Tags) == 0 {
+				add(UntaggedKey, linkClicks)
+			}
+			seen := make(map[string]bool, len(link.Tags))
+			for _, tag := range link.Tags {
+				if !seen[tag] {
+					seen[tag] = true
+					add(tag, linkClicks)
+				}
+			}
+		case AggregateByDomain:
+			add(link.Domain(), linkClicks)
+		case AggregateByMonth:
+			add(link.CreatedAt.In(loc).Format("2006-01"), linkClicks)
+		}
+	}
+
+	aggregates := make([]ClickAggregate, 0, len(groups))
+	for _, g := range groups {
+		aggregates = append(aggregates, *g)
+	}
+	sort.Slice(aggregates, func(i, j int) bool {
+		a, b := aggregates[i], aggregates[j]
+		if groupBy != AggregateByMonth && a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Key < b.Key
+	})
+	return aggregates
+}
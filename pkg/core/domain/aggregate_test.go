@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateClicks(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*3600)
+	links := []Link{
+		{ID: 1, OriginalURL: "https://www.example.com/a", Tags: []string{"campaign-q3", "email"}, CreatedAt: time.Date(2026, 6, 30, 18, 0, 0, 0, time.UTC)},
+		{ID: 2, OriginalURL: "https://Example.com/b", Tags: []string{"campaign-q3"}, CreatedAt: time.Date(2026, 7, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: 3, OriginalURL: "https://shop.example.org/", CreatedAt: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	clicks := map[int64]int64{1: 10, 2: 5, 3: 20}
+
+	tests := []struct {
+		groupBy string
+		want    []ClickAggregate
+	}{
+		{AggregateByTag, []ClickAggregate{
+			{Key: UntaggedKey, Links: 1, Clicks: 20},
+			{Key: "campaign-q3", Links: 2, Clicks: 15},
+			{Key: "email", Links: 1, Clicks: 10},
+		}},
+		{AggregateByDomain, []ClickAggregate{
+			{Key: "shop.example.org", Links: 1, Clicks: 20},
+			{Key: "example.com", Links: 2, Clicks: 15},
+		}},
+		{AggregateByMonth, []ClickAggregate{
+			{Key: "2026-05", Links: 1, Clicks: 20},
+			{Key: "2026-07", Links: 2, Clicks: 15}, // link 1 is created in July in Bangkok
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := AggregateClicks(links, clicks, tt.groupBy, bangkok); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AggregateClicks(%s) = %+v, want %+v", tt.groupBy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// Alert rule types
+const (
+	AlertThreshold   = "threshold"    // lifetime clicks reach Threshold
+	AlertChange      = "change"       // clicks in the last WindowHours differ from the trailing average by ChangePercent
+	AlertZeroTraffic = "zero_traffic" // no clicks in the last WindowHours after some in the WindowHours before
+)
+
+// AlertTypes lists every supported alert rule type
+var AlertTypes = []string{AlertThreshold, AlertChange, AlertZeroTraffic}
+
+// Alert change directions
+const (
+	AlertDirectionUp   = "up"   // spikes only
+	AlertDirectionDown = "down" // drops only
+	AlertDirectionBoth = "both"
+)
+
+// Alert notification channels
+const (
+	AlertChannelWebhook = "webhook" // published as an alert.triggered event
+	AlertChannelEmail   = "email"
+)
+
+// AlertRule is evaluated per link by the background alert job. A rule without a
+// link applies to every link.
+type AlertRule struct {
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	LinkID          int64      `json:"link_id,omitempty"` // 0 for a global rule
+	Type            string     `json:"type"`
+	Threshold       int64      `json:"threshold,omitempty"`        // threshold
+	ChangePercent   float64    `json:"change_percent,omitempty"`   // change
+	Direction       string     `json:"direction,omitempty"`        // change: up, down or both
+	WindowHours     int        `json:"window_hours,omitempty"`     // change, zero_traffic
+	BaselineWindows int        `json:"baseline_windows,omitempty"` // change: windows averaged for the baseline
+	Channels        []string   `json:"channels"`
+	Emails          []string   `json:"emails,omitempty"` // email channel recipients
+	Active          bool       `json:"active"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Alert is one rule firing for one link. It is the data of alert.triggered events
+// and the content of alert emails.
+type Alert struct {
+	RuleID      int64     `json:"rule_id"`
+	RuleName    string    `json:"rule_name"`
+	Type        string    `json:"type"`
+	LinkID      int64     `json:"link_id"`
+	ShortCode   string    `json:"short_code"`
+	Message     string    `json:"message"`
+	Value       float64   `json:"value"`              // lifetime clicks, or clicks in the window
+	Baseline    float64   `json:"baseline,omitempty"` // change: trailing average per window
+	TriggeredAt time.Time `json:"triggered_at"`
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Collection page themes
+const (
+	ThemeDefault = "default"
+	ThemeDark    = "dark"
+	ThemeMinimal = "minimal"
+	ThemeOcean   = "ocean"
+	ThemeSunset  = "sunset"
+)
+
+// CollectionThemes lists the built-in public page themes
+var CollectionThemes = []string{ThemeDefault, ThemeDark, ThemeMinimal, ThemeOcean, ThemeSunset}
+
+// Collection page fonts
+const (
+	FontSystem  = "system"
+	FontSerif   = "serif"
+	FontMono    = "mono"
+	FontRounded = "rounded"
+)
+
+// CollectionFonts lists the font stacks a page can use
+var CollectionFonts = []string{FontSystem, FontSerif, FontMono, FontRounded}
+
+// Collection page button styles
+const (
+	ButtonRounded = "rounded"
+	ButtonPill    = "pill"
+	ButtonSquare  = "square"
+	ButtonOutline = "outline"
+)
+
+// ButtonStyles lists the link button styles
+var ButtonStyles = []string{ButtonRounded, ButtonPill, ButtonSquare, ButtonOutline}
+
+// MaxCustomCSSLen bounds a collection's custom CSS, in bytes
+const MaxCustomCSSLen = 10000
+
+// CollectionAppearance styles a collection's public page. Empty fields use the theme's
+// defaults; colors are "#rgb" or "#rrggbb".
+type CollectionAppearance struct {
+	Theme              string `json:"theme,omitempty"`
+	BackgroundColor    string `json:"background_color,omitempty"`
+	TextColor          string `json:"text_color,omitempty"`
+	ButtonColor        string `json:"button_color,omitempty"`
+	ButtonTextColor    string `json:"button_text_color,omitempty"`
+	Font               string `json:"font,omitempty"`
+	ButtonStyle        string `json:"button_style,omitempty"`
+	BackgroundImageURL string `json:"background_image_url,omitempty"`
+	CustomCSS          string `json:"custom_css,omitempty"` // Sanitized with SanitizeCSS
+}
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssImport  = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	cssUnsafe  = regexp.MustCompile(`(?i)expression\s*\(|(java|vb)script\s*:|behavior\s*:|-moz-binding`)
+)
+
+// SanitizeCSS makes user CSS safe to embed in a <style> element: it cannot close the
+// element, load other stylesheets or run script. Comments and escapes are dropped first
+// so they cannot hide a construct from the later passes.
+func SanitizeCSS(css string) string {
+	for {
+		clean := strings.NewReplacer("<", "", `\`, "").Replace(css)
+		clean = cssComment.ReplaceAllString(clean, "")
+		clean = cssImport.ReplaceAllString(clean, "")
+		clean = cssUnsafe.ReplaceAllString(clean, "")
+		if clean == css {
+			return strings.TrimSpace(clean)
+		}
+		css = clean
+	}
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "testing"
+
+func TestSanitizeCSS(t *testing.T) {
+	tests := []struct {
+		css, want string
+	}{
+		{".links a { font-weight: 700; }", ".links a { font-weight: 700; }"},
+		{"h1 { color: red }</style><script>alert(1)</script>", "h1 { color: red }/style>script>alert(1)/script>"},
+		{"@import url(https://evil.test/x.css); h1 { color: red }", "h1 { color: red }"},
+		{"body { background: url(javascript:alert(1)) }", "body { background: url(alert(1)) }"},
+		{"body { background: url(jav/**/ascript:alert(1)) }", "body { background: url(alert(1)) }"},
+		{`body { width: expr\65ssion(alert(1)) }`, "body { width: expr65ssion(alert(1)) }"},
+		{"a { width: expexpression(ression(1) }", "a { width: 1) }"},
+		{"a { behavior: url(x.htc); -moz-binding: url(x.xml) }", "a {  url(x.htc); : url(x.xml) }"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeCSS(tt.css); got != tt.want {
+			t.Errorf("SanitizeCSS(%q) = %q, want %q", tt.css, got, tt.want)
+		}
+	}
+}
@@ -1,22 +1,60 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLinkInCollection is returned when adding a link the collection already has
+	ErrLinkInCollection = errors.New("link is already in the collection")
+	// ErrCollectionOrderMismatch is returned when a new order doesn't list exactly the collection's links
+	ErrCollectionOrderMismatch = errors.New("link_ids must list each of the collection's links exactly once")
+)
+
+// Visibility of a collection's public page
+const (
+	VisibilityDraft    = "draft"    // Only reachable through a preview link
+	VisibilityUnlisted = "unlisted" // Reachable by URL, but asks search engines not to index it
+	VisibilityPublic   = "public"
+	VisibilityPassword = "password" // Visitors must enter the collection's password
+)
+
+// CollectionVisibilities lists the supported visibilities
+var CollectionVisibilities = []string{VisibilityDraft, VisibilityUnlisted, VisibilityPublic, VisibilityPassword}
 
 // Collection represents a group of links (link-in-bio)
 type Collection struct {
-	ID          int64     `json:"id"`
-	Slug        string    `json:"slug"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	LinkIDs     []int64   `json:"link_ids,omitempty"` // For convenience, though likely fetched separately
-	Links       []Link    `json:"links,omitempty"`    // Populated when fetching full collection details
+	ID           int64                `json:"id"`
+	Slug         string               `json:"slug"`
+	Title        string               `json:"title"`
+	Description  string               `json:"description"`
+	AvatarURL    string               `json:"avatar_url"` // Shown at the top of the public page
+	Appearance   CollectionAppearance `json:"appearance"`
+	Visibility   string               `json:"visibility"`
+	Password     string               `json:"-"`                      // New password on create/update; never stored as is
+	PasswordHash string               `json:"-"`                      // Set for password-protected collections
+	PublishAt    *time.Time           `json:"publish_at,omitempty"`   // Page is hidden before this time
+	UnpublishAt  *time.Time           `json:"unpublish_at,omitempty"` // Page is hidden from this time on
+	IsTemplate   bool                 `json:"is_template"`            // Listed as a starting point for cloning new collections
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	LinkIDs      []int64              `json:"link_ids,omitempty"` // For convenience, though likely fetched separately
+	Links        []Link               `json:"links,omitempty"`    // Populated when fetching full collection details
+	Items        []CollectionItem     `json:"items,omitempty"`    // Page blocks in order, links included
 }
 
-// CollectionLink represents the many-to-many relationship with ordering
-type CollectionLink struct {
-	CollectionID int64 `json:"collection_id"`
-	LinkID       int64 `json:"link_id"`
-	SortOrder    int   `json:"sort_order"`
+// IsLive reports whether the public page can be served at now: the collection is not a
+// draft and now is within its publishing window. Password checks are up to the caller.
+func (c *Collection) IsLive(now time.Time) bool {
+	if c.Visibility == VisibilityDraft {
+		return false
+	}
+	if c.PublishAt != nil && now.Before(*c.PublishAt) {
+		return false
+	}
+	if c.UnpublishAt != nil && !now.Before(*c.UnpublishAt) {
+		return false
+	}
+	return true
 }
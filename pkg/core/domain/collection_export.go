@@ -0,0 +1,104 @@
+package domain
+
+import "time"
+
+// CollectionExportVersion is the version of the collection export format
+const CollectionExportVersion = 1
+
+// How a cloned or imported collection gets its links
+const (
+	LinksShare     = "share"     // Reuse the existing links, so clicks are counted together
+	LinksDuplicate = "duplicate" // Create a new short link for each, with separate stats
+)
+
+// CollectionLinkModes lists the supported link modes
+var CollectionLinkModes = []string{LinksShare, LinksDuplicate}
+
+// CopyOptions configures cloning or importing a collection
+type CopyOptions struct {
+	Slug  string `json:"slug"`  // Required for clones; imports default to the exported slug
+	Title string `json:"title"` // Defaults to the original title
+	Links string `json:"links"` // LinksShare (default) or LinksDuplicate
+}
+
+// CollectionExport is a self-contained copy of a collection: its settings, appearance and
+// items, with links by destination rather than by ID so it can be imported elsewhere.
+// Passwords are not exported; importing a password-protected collection needs Password.
+type CollectionExport struct {
+	Version     int                  `json:"version"`
+	ExportedAt  time.Time            `json:"exported_at"`
+	Slug        string               `json:"slug"`
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	AvatarURL   string               `json:"avatar_url"`
+	Appearance  CollectionAppearance `json:"appearance"`
+	Visibility  string               `json:"visibility"`
+	Password    string               `json:"password,omitempty"` // Import only
+	PublishAt   *time.Time           `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time           `json:"unpublish_at,omitempty"`
+	IsTemplate  bool                 `json:"is_template"`
+	Items       []ExportedItem       `json:"items"`
+}
+
+// ExportedItem is a collection item without its IDs and timestamps
+type ExportedItem struct {
+	Type         string          `json:"type"`
+	Link         *ExportedLink   `json:"link,omitempty"` // Link items
+	Label        string          `json:"label,omitempty"`
+	Icon         string          `json:"icon,omitempty"`
+	ThumbnailURL string          `json:"thumbnail_url,omitempty"`
+	Highlight    bool            `json:"highlight,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	URL          string          `json:"url,omitempty"`
+	Socials      []SocialProfile `json:"socials,omitempty"`
+	Style        ItemStyle       `json:"style"`
+}
+
+// ExportedLink is the short link of an exported link item
+type ExportedLink struct {
+	ShortCode   string   `json:"short_code"`
+	OriginalURL string   `json:"original_url"`
+	Title       string   `json:"title,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// NewCollectionExport exports a collection with its items loaded
+func NewCollectionExport(c *Collection, now time.Time) *CollectionExport {
+	export := &CollectionExport{
+		Version:     CollectionExportVersion,
+		ExportedAt:  now.UTC(),
+		Slug:        c.Slug,
+		Title:       c.Title,
+		Description: c.Description,
+		AvatarURL:   c.AvatarURL,
+		Appearance:  c.Appearance,
+		Visibility:  c.Visibility,
+		PublishAt:   c.PublishAt,
+		UnpublishAt: c.UnpublishAt,
+		IsTemplate:  c.IsTemplate,
+		Items:       make([]ExportedItem, 0, len(c.Items)),
+	}
+	for _, item := range c.Items {
+		exported := ExportedItem{
+			Type:         item.Type,
+			Label:        item.Label,
+			Icon:         item.Icon,
+			ThumbnailURL: item.ThumbnailURL,
+			Highlight:    item.Highlight,
+			Text:         item.Text,
+			URL:          item.URL,
+			Socials:      item.Socials,
+			Style:        item.Style,
+		}
+		if item.Link != nil {
+			exported.Link = &ExportedLink{
+				ShortCode:   item.Link.ShortCode,
+				OriginalURL: item.Link.OriginalURL,
+				Title:       item.Link.Title,
+				Tags:        item.Link.Tags,
+			}
+		}
+		export.Items = append(export.Items, exported)
+	}
+	return export
+}
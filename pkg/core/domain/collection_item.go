@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Collection item types
+const (
+	ItemLink    = "link"
+	ItemHeading = "heading"
+	ItemText    = "text"
+	ItemImage   = "image"
+	ItemSocial  = "social" // A row of social profile icons
+	ItemEmbed   = "embed"  // Embedded media player (YouTube, Vimeo, Spotify)
+)
+
+// CollectionItemTypes lists the kinds of blocks a collection page can hold
+var CollectionItemTypes = []string{ItemLink, ItemHeading, ItemText, ItemImage, ItemSocial, ItemEmbed}
+
+// SocialPlatforms lists the profiles a social item can link to
+var SocialPlatforms = []string{"instagram", "x", "tiktok", "youtube", "facebook", "linkedin", "github", "threads", "twitch", "email", "website"}
+
+// CollectionItem is one block of a collection page. Which content fields are used
+// depends on Type.
+type CollectionItem struct {
+	ID           int64           `json:"id"`
+	CollectionID int64           `json:"collection_id"`
+	Type         string          `json:"type"`
+	LinkID       int64           `json:"link_id,omitempty"`       // Link items
+	Link         *Link           `json:"link,omitempty"`          // Populated for link items
+	Label        string          `json:"label,omitempty"`         // Link button text on this page, instead of the link's title
+	Icon         string          `json:"icon,omitempty"`          // Emoji or short symbol before the label
+	ThumbnailURL string          `json:"thumbnail_url,omitempty"` // Image shown on the link button
+	Highlight    bool            `json:"highlight,omitempty"`     // Emphasizes the link button
+	Text         string          `json:"text,omitempty"`          // Heading and text content, image alt text
+	URL          string          `json:"url,omitempty"`           // Image source, embedded page URL
+	Socials      []SocialProfile `json:"socials,omitempty"`       // Social items
+	Style        ItemStyle       `json:"style"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// ButtonText is the text of a link item's button: its label, else the link's title, else
+// the link's destination domain
+func (item *CollectionItem) ButtonText() string {
+	if item.Label != "" || item.Link == nil {
+		return item.Label
+	}
+	if item.Link.Title != "" {
+		return item.Link.Title
+	}
+	return item.Link.Domain()
+}
+
+// SocialProfile is one icon of a social item
+type SocialProfile struct {
+	Platform string `json:"platform"`
+	URL      string `json:"url"` // mailto: for email
+}
+
+// ItemStyle overrides the page appearance for a single item
+type ItemStyle struct {
+	Align           string `json:"align,omitempty"` // left, center (default) or right
+	TextColor       string `json:"text_color,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+}
+
+var (
+	youTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{6,20}$`)
+	vimeoID   = regexp.MustCompile(`^[0-9]{1,12}$`)
+	spotifyID = regexp.MustCompile(`^[A-Za-z0-9]{10,40}$`)
+)
+
+// EmbedSource returns the player URL for a supported media page URL: YouTube videos,
+// Vimeo videos and Spotify tracks, albums, playlists, episodes and shows
+func EmbedSource(pageURL string) (string, bool) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtube.com", "m.youtube.com":
+		id := u.Query().Get("v")
+		if len(segments) == 2 && (segments[0] == "embed" || segments[0] == "shorts") {
+			id = segments[1]
+		}
+		if youTubeID.MatchString(id) {
+			return "https://www.youtube-nocookie.com/embed/" + id, true
+		}
+	case "youtu.be":
+		if len(segments) == 1 && youTubeID.MatchString(segments[0]) {
+			return "https://www.youtube-nocookie.com/embed/" + segments[0], true
+		}
+	case "vimeo.com":
+		if len(segments) == 1 && vimeoID.MatchString(segments[0]) {
+			return "https://player.vimeo.com/video/" + segments[0], true
+		}
+	case "open.spotify.com":
+		if len(segments) == 2 && spotifyID.MatchString(segments[1]) {
+			switch segments[0] {
+			case "track", "album", "playlist", "episode", "show", "artist":
+				return "https://open.spotify.com/embed/" + segments[0] + "/" + segments[1], true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "testing"
+
+func TestEmbedSource(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"https://youtube.com/shorts/dQw4w9WgXcQ", "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{"https://vimeo.com/76979871", "https://player.vimeo.com/video/76979871"},
+		{"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc", "https://open.spotify.com/embed/playlist/37i9dQZF1DXcBWIGoYBM5M"},
+		{"https://www.youtube.com/watch?v=<script>", ""},
+		{"https://open.spotify.com/user/someone", ""},
+		{"https://evil.test/watch?v=dQw4w9WgXcQ", ""},
+		{"javascript:alert(1)", ""},
+	}
+	for _, tt := range tests {
+		got, ok := EmbedSource(tt.url)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("EmbedSource(%q) = %q, %v, want %q", tt.url, got, ok, tt.want)
+		}
+	}
+}
+
+func TestItemButtonText(t *testing.T) {
+	link := &Link{Title: "My blog", OriginalURL: "https://www.blog.example.com/post"}
+	tests := []struct {
+		item CollectionItem
+		want string
+	}{
+		{CollectionItem{Label: "Read this", Link: link}, "Read this"},
+		{CollectionItem{Link: link}, "My blog"},
+		{CollectionItem{Link: &Link{OriginalURL: "https://www.blog.example.com/post"}}, "blog.example.com"},
+		{CollectionItem{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.item.ButtonText(); got != tt.want {
+			t.Errorf("ButtonText(%+v) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
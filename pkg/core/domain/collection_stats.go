@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// CollectionItemParam is the /open query parameter attributing a click to the collection
+// item it came from
+const CollectionItemParam = "ci"
+
+// CollectionView is a visit to a collection's public page
+type CollectionView struct {
+	ID             int64     `json:"id"`
+	CollectionID   int64     `json:"collection_id"`
+	Referer        string    `json:"referer"`
+	RefererDomain  string    `json:"referer_domain"`
+	RefererChannel string    `json:"referer_channel"`
+	UserAgent      string    `json:"user_agent"`
+	IPHash         string    `json:"ip_hash"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CollectionStats compares a collection page's views with the clicks on its links
+type CollectionStats struct {
+	Views            int64            `json:"views"`
+	UniqueVisitors   int64            `json:"unique_visitors"`
+	Clicks           int64            `json:"clicks"`             // link clicks from the page
+	ClickThroughRate float64          `json:"click_through_rate"` // Clicks / Views
+	Referrers        map[string]int64 `json:"referrers"`          // views by source domain
+	Channels         map[string]int64 `json:"channels"`           // views by traffic channel
+	Items            []ItemClicks     `json:"items"`              // link items in page order
+	Daily            []CollectionDay  `json:"daily"`              // UTC days with views or clicks, oldest first
+}
+
+// ItemClicks is the number of clicks on one link item of a collection page
+type ItemClicks struct {
+	ItemID int64  `json:"item_id"`
+	LinkID int64  `json:"link_id"`
+	Title  string `json:"title"` // Button text: the item label, else the link title, else its domain
+	Clicks int64  `json:"clicks"`
+}
+
+// CollectionDay holds a collection page's views and clicks of one day
+type CollectionDay struct {
+	Date   string `json:"date"` // YYYY-MM-DD
+	Views  int64  `json:"views"`
+	Clicks int64  `json:"clicks"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectionIsLive(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		collection Collection
+		want       bool
+	}{
+		{"public", Collection{Visibility: VisibilityPublic}, true},
+		{"unlisted", Collection{Visibility: VisibilityUnlisted}, true},
+		{"password", Collection{Visibility: VisibilityPassword}, true},
+		{"draft", Collection{Visibility: VisibilityDraft}, false},
+		{"published", Collection{Visibility: VisibilityPublic, PublishAt: &before, UnpublishAt: &after}, true},
+		{"publishes at now", Collection{Visibility: VisibilityPublic, PublishAt: &now}, true},
+		{"scheduled", Collection{Visibility: VisibilityPublic, PublishAt: &after}, false},
+		{"unpublished", Collection{Visibility: VisibilityPublic, UnpublishAt: &before}, false},
+		{"unpublishes at now", Collection{Visibility: VisibilityPublic, UnpublishAt: &now}, false},
+		{"scheduled draft", Collection{Visibility: VisibilityDraft, PublishAt: &before}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.collection.IsLive(now); got != tt.want {
+			t.Errorf("%s: IsLive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// DefaultConversionEvent names conversions reported without an event
+const DefaultConversionEvent = "conversion"
+
+// Conversion is an outcome reported after a redirect (signup, purchase, ...), attributed
+// to the visit and link through the click ID issued on that redirect
+type Conversion struct {
+	ID        int64     `json:"id,omitempty"` // unset when the conversion was already recorded
+	ClickID   string    `json:"click_id"`
+	LinkID    int64     `json:"link_id"`
+	Event     string    `json:"event"`
+	Revenue   float64   `json:"revenue,omitempty"`
+	Currency  string    `json:"currency,omitempty"` // ISO 4217
+	OrderID   string    `json:"order_id,omitempty"` // deduplicates repeated reports of the same order
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ConversionInput is a conversion as reported by a postback or pixel
+type ConversionInput struct {
+	ClickID  string
+	Event    string
+	Revenue  float64
+	Currency string
+	OrderID  string
+}
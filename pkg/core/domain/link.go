@@ -13,4 +13,8 @@ type Link struct {
 	UpdatedAt   time.Time  `json:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 	Clicks      int64      `json:"clicks,omitempty"` // Aggregated count
+
+	// Dashboard only
+	Conversions    int64   `json:"conversions,omitempty"`
+	ConversionRate float64 `json:"conversion_rate,omitempty"` // clicks with a conversion / clicks
 }
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Traffic channels a referrer is classified into
+const (
+	ChannelDirect   = "direct"
+	ChannelSocial   = "social"
+	ChannelSearch   = "search"
+	ChannelEmail    = "email"
+	ChannelReferral = "referral"
+)
+
+// Known referrer hosts by channel. A host matches an entry exactly or as a subdomain.
+var (
+	emailDomains = []string{
+		"mail.google.com", "com.google.android.gm", "outlook.live.com", "outlook.office.com",
+		"outlook.office365.com", "mail.yahoo.com", "mail.proton.me", "mail.aol.com", "mail.zoho.com",
+	}
+	socialDomains = []string{
+		"facebook.com", "fb.com", "messenger.com", "instagram.com", "t.co", "twitter.com", "x.com",
+		"linkedin.com", "lnkd.in", "reddit.com", "pinterest.com", "tiktok.com", "youtube.com", "youtu.be",
+		"threads.net", "line.me", "t.me", "telegram.org", "whatsapp.com", "wa.me", "discord.com",
+		"snapchat.com", "tumblr.com", "mastodon.social", "bsky.app", "com.facebook.katana",
+	}
+	// Search engines are matched by label so regional sites (google.co.th, yandex.ru) are covered
+	searchLabels = []string{"google", "bing", "duckduckgo", "yahoo", "baidu", "yandex", "naver", "ecosia"}
+)
+
+// NormalizeReferrer reduces a raw referrer (URL, app referrer or free-form custom ref)
+// to its source domain and traffic channel. An empty referrer is direct traffic.
+func NormalizeReferrer(raw string) (domain, channel string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ChannelDirect
+	}
+
+	host := referrerHost(raw)
+	if host == "" {
+		return "", ChannelDirect
+	}
+	return host, referrerChannel(host)
+}
+
+func referrerHost(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	for _, prefix := range []string{"www.", "m.", "l.", "lm.", "mobile."} {
+		if trimmed := strings.TrimPrefix(host, prefix); trimmed != host && strings.Contains(trimmed, ".") {
+			host = trimmed
+			break
+		}
+	}
+	return host
+}
+
+func referrerChannel(host string) string {
+	if matchesDomain(host, emailDomains) {
+		return ChannelEmail
+	}
+	if matchesDomain(host, socialDomains) {
+		return ChannelSocial
+	}
+	for _, label := range strings.Split(host, ".") {
+		for _, engine := range searchLabels {
+			if label == engine {
+				return ChannelSearch
+			}
+		}
+	}
+	return ChannelReferral
+}
+
+func matchesDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "testing"
+
+func TestNormalizeReferrer(t *testing.T) {
+	tests := []struct {
+		raw     string
+		domain  string
+		channel string
+	}{
+		{"", "", ChannelDirect},
+		{"https://t.co/abc", "t.co", ChannelSocial},
+		{"https://t.co/xyz", "t.co", ChannelSocial},
+		{"https://l.facebook.com/l.php?u=x", "facebook.com", ChannelSocial},
+		{"https://www.google.co.th/", "google.co.th", ChannelSearch},
+		{"https://mail.google.com/mail/u/0/", "mail.google.com", ChannelEmail},
+		{"android-app://com.google.android.gm", "com.google.android.gm", ChannelEmail},
+		{"https://Blog.Example.com/post?id=1", "blog.example.com", ChannelReferral},
+		{"newsletter.example.org", "newsletter.example.org", ChannelReferral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			domain, channel := NormalizeReferrer(tt.raw)
+			if domain != tt.domain || channel != tt.channel {
+				t.Errorf("NormalizeReferrer(%q) = (%q, %q), want (%q, %q)", tt.raw, domain, channel, tt.domain, tt.channel)
+			}
+		})
+	}
+}
@@ -1,22 +1,153 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 // Visit represents a click on a short link
 type Visit struct {
-	ID        int64     `json:"id"`
-	LinkID    int64     `json:"link_id"`
-	Referer   string    `json:"referer"`
-	UserAgent string    `json:"user_agent"`
-	IPHash    string    `json:"ip_hash"` // Anonymized IP
-	CreatedAt time.Time `json:"created_at"`
+	ID               int64             `json:"id"`
+	LinkID           int64             `json:"link_id"`
+	Referer          string            `json:"referer"`
+	RefererDomain    string            `json:"referer_domain"`
+	RefererChannel   string            `json:"referer_channel"` // direct, social, search, email, referral
+	UserAgent        string            `json:"user_agent"`
+	IPHash           string            `json:"ip_hash"`           // Anonymized IP
+	Country          string            `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	Region           string            `json:"region,omitempty"`
+	City             string            `json:"city,omitempty"`
+	UTMSource        string            `json:"utm_source,omitempty"`
+	UTMMedium        string            `json:"utm_medium,omitempty"`
+	UTMCampaign      string            `json:"utm_campaign,omitempty"`
+	UTMTerm          string            `json:"utm_term,omitempty"`
+	UTMContent       string            `json:"utm_content,omitempty"`
+	Params           map[string]string `json:"params,omitempty"`             // whitelisted extra query parameters
+	ClickID          string            `json:"click_id,omitempty"`           // appended to the destination for conversion attribution
+	CollectionItemID int64             `json:"collection_item_id,omitempty"` // link-in-bio item the click came from
+	CreatedAt        time.Time         `json:"created_at"`
+	Duplicate        bool              `json:"-"` // repeat within the dedup window; only counted, never stored
+}
+
+// VisitEvent is a recorded visit as pushed to live stream subscribers
+type VisitEvent struct {
+	ID             int64     `json:"id,omitempty"` // stream sequence, resumable via Last-Event-ID
+	LinkID         int64     `json:"link_id"`
+	ShortCode      string    `json:"short_code"`
+	Tags           []string  `json:"tags,omitempty"`
+	RefererDomain  string    `json:"referer_domain"`
+	RefererChannel string    `json:"referer_channel"`
+	Country        string    `json:"country,omitempty"`
+	City           string    `json:"city,omitempty"`
+	UTMSource      string    `json:"utm_source,omitempty"`
+	UTMCampaign    string    `json:"utm_campaign,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// VisitInput is the raw request data a visit is recorded from
+type VisitInput struct {
+	Referer   string
+	UserAgent string
+	IP        string
+	Query     map[string]string // inbound query (or Track body) parameters; only UTM and whitelisted keys are kept
+}
+
+// ErrVisitQueueFull means a visit was dropped because the visit writer is saturated
+var ErrVisitQueueFull = errors.New("visit queue full")
+
+// VisitQueueStats reports the state of the buffered visit writer
+type VisitQueueStats struct {
+	Queued   int   `json:"queued"`   // visits waiting to be written
+	Capacity int   `json:"capacity"` // queue size; visits beyond it are dropped
+	Enqueued int64 `json:"enqueued"`
+	Written  int64 `json:"written"`
+	Dropped  int64 `json:"dropped"` // rejected because the queue was full
+	Failed   int64 `json:"failed"`  // lost to database errors
+}
+
+// Location is the resolved geographic origin of a visitor
+type Location struct {
+	Country string `json:"country"` // ISO 3166-1 alpha-2
+	Region  string `json:"region"`
+	City    string `json:"city"`
 }
 
 // Stats represents aggregated statistics for a link
 type LinkStats struct {
-	TotalClicks int64            `json:"total_clicks"`
-	Referrers   map[string]int64 `json:"referrers"`    // count by domain
-	DailyClicks []DailyClick     `json:"daily_clicks"` // timeline
+	TotalClicks     int64              `json:"total_clicks"`
+	UniqueVisitors  int64              `json:"unique_visitors"`   // distinct per UTC day for compacted history
+	DuplicateClicks int64              `json:"duplicate_clicks"`  // repeats ignored by the dedup window, not in TotalClicks
+	Conversions     int64              `json:"conversions"`       // conversions recorded in the range
+	ConversionRate  float64            `json:"conversion_rate"`   // clicks with a conversion / TotalClicks
+	Revenue         map[string]float64 `json:"revenue,omitempty"` // conversion revenue by currency ("" when none given)
+	Referrers       map[string]int64   `json:"referrers"`         // count by raw referer
+	ReferrerDomains map[string]int64   `json:"referrer_domains"`  // count by normalized source domain
+	Channels        map[string]int64   `json:"channels"`          // count by traffic channel
+	Geo             GeoBreakdown       `json:"geo"`               // count by location
+	UTM             UTMBreakdown       `json:"utm"`               // count by campaign parameters
+	DailyClicks     []DailyClick       `json:"daily_clicks"`      // days with clicks, newest first (UTC unless tz is given)
+
+	// Timeline over [From, To) in Granularity buckets, zero-filled
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Granularity string            `json:"granularity"`
+	Timezone    string            `json:"timezone"`
+	Timeline    []TimeBucket      `json:"timeline"`
+	Previous    *PeriodComparison `json:"previous,omitempty"` // set when comparison is requested
+
+	// Set when dimension filters reach before PrunedUntil: filtered stats need raw visits,
+	// and those were pruned there, so the counts leave that part out
+	Partial     bool       `json:"partial,omitempty"`
+	PrunedUntil *time.Time `json:"pruned_until,omitempty"`
+}
+
+// StatsDimensionFilters are stats filters that match raw visit fields. Only raw visits
+// can answer them, not rollups.
+var StatsDimensionFilters = []string{"referer", "country", "utm_source", "utm_medium", "utm_campaign"}
+
+// Timeline granularities
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week" // ISO weeks, starting Monday
+	GranularityMonth = "month"
+)
+
+// Errors for stats ranges the timeline can't cover
+var (
+	ErrStatsRangeOrder    = errors.New("from must be before to")
+	ErrStatsRangeTooLarge = errors.New("range too large for granularity, use a coarser one")
+)
+
+// TimeBucket is the click count of the interval starting at Start
+type TimeBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// PeriodComparison summarizes the period of equal length immediately before the requested range
+type PeriodComparison struct {
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	TotalClicks int64        `json:"total_clicks"`
+	Timeline    []TimeBucket `json:"timeline"`
+	ChangePct   *float64     `json:"change_pct"` // nil when the previous period had no clicks
+}
+
+// GeoBreakdown holds visit counts grouped by location.
+// Region and city keys are suffixed with the country code, e.g. "Bangkok, TH".
+type GeoBreakdown struct {
+	Countries map[string]int64 `json:"countries"`
+	Regions   map[string]int64 `json:"regions"`
+	Cities    map[string]int64 `json:"cities"`
+}
+
+// UTMBreakdown holds visit counts grouped by UTM parameter.
+// Visits without the parameter are counted under "(none)".
+type UTMBreakdown struct {
+	Sources   map[string]int64 `json:"sources"`
+	Mediums   map[string]int64 `json:"mediums"`
+	Campaigns map[string]int64 `json:"campaigns"`
 }
 
 type DailyClick struct {
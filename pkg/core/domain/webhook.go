@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types delivered to webhooks
+const (
+	EventLinkCreated       = "link.created"
+	EventLinkUpdated       = "link.updated"
+	EventLinkDeleted       = "link.deleted"
+	EventLinkClicked       = "link.clicked"
+	EventCollectionCreated = "collection.created"
+	EventCollectionUpdated = "collection.updated"
+	EventCollectionDeleted = "collection.deleted"
+	EventAlertTriggered    = "alert.triggered"
+)
+
+// EventTypes lists every event a webhook can subscribe to
+var EventTypes = []string{
+	EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkClicked,
+	EventCollectionCreated, EventCollectionUpdated, EventCollectionDeleted,
+	EventAlertTriggered,
+}
+
+// Event is the JSON body POSTed to webhooks
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Webhook is a registered endpoint receiving signed event payloads
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // HMAC-SHA256 key; only returned on creation
+	Events    []string  `json:"events"`           // subscribed event types, or "*" for all but link.clicked
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribed reports whether the webhook wants events of eventType. "*" covers every
+// event but link.clicked, which fires on every redirect and must be listed explicitly.
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, e := range w.Events {
+		if (e == "*" && eventType != EventLinkClicked) || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // gave up after the last retry
+)
+
+// WebhookDelivery is one event queued for a webhook, with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"` // pending deliveries only
+	ResponseCode  int             `json:"response_code,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
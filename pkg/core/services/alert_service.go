@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+const (
+	alertDefaultWindowHours     = 1
+	alertDefaultBaselineWindows = 24
+	alertDefaultZeroHours       = 24
+	alertMaxWindowHours         = 24 * 7
+	alertMaxBaselineWindows     = 24 * 7
+)
+
+// AlertService manages alert rules and evaluates them against recent clicks.
+// A rule notifies once when its condition starts holding for a link and re-arms
+// when it stops holding.
+type AlertService struct {
+	repo   ports.LinkRepository
+	events ports.EventPublisher // webhook channel
+	mailer ports.Mailer         // email channel; nil when SMTP isn't configured
+}
+
+func NewAlertService(repo ports.LinkRepository, events ports.EventPublisher, mailer ports.Mailer) *AlertService {
+	return &AlertService{repo: repo, events: events, mailer: mailer}
+}
+
+func (s *AlertService) CreateAlertRule(ctx context.Context, rule *domain.AlertRule) (*domain.AlertRule, error) {
+	created := &domain.AlertRule{Active: rule.Active, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.applyAlertSettings(ctx, created, rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateAlertRule(ctx, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *AlertService) GetAlertRule(ctx context.Context, id int64) (*domain.AlertRule, error) {
+	return s.repo.GetAlertRule(ctx, id)
+}
+
+// ListAlertRules returns every rule, or only those of one link when linkID > 0
+func (s *AlertService) ListAlertRules(ctx context.Context, linkID int64) ([]domain.AlertRule, error) {
+	filters := map[string]interface{}{}
+	if linkID > 0 {
+		filters["link_id"] = linkID
+	}
+	return s.repo.ListAlertRules(ctx, filters)
+}
+
+// UpdateAlertRule replaces the rule's settings and active flag. Links it was firing
+// for notify again if they still match.
+func (s *AlertService) UpdateAlertRule(ctx context.Context, id int64, rule *domain.AlertRule) (*domain.AlertRule, error) {
+	existing, err := s.repo.GetAlertRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, errors.New("alert rule not found")
+	}
+
+	if err := s.applyAlertSettings(ctx, existing, rule); err != nil {
+		return nil, err
+	}
+	existing.Active = rule.Active
+	existing.UpdatedAt = time.Now()
+	if err := s.repo.UpdateAlertRule(ctx, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *AlertService) DeleteAlertRule(ctx context.Context, id int64) error {
+	return s.repo.DeleteAlertRule(ctx, id)
+}
+
+// applyAlertSettings validates the settings of src and copies them to dst, applying
+// defaults and dropping fields the rule type doesn't use
+func (s *AlertService) applyAlertSettings(ctx context.Context, dst, src *domain.AlertRule) error {
+	if !slices.Contains(domain.AlertTypes, src.Type) {
+		return fmt.Errorf("alert type must be one of %s", strings.Join(domain.AlertTypes, ", "))
+	}
+	if src.LinkID < 0 {
+		return errors.New("invalid link_id")
+	}
+	if src.LinkID > 0 {
+		link, err := s.repo.GetByID(ctx, src.LinkID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return errors.New("link not found")
+		}
+	}
+
+	dst.Name = strings.TrimSpace(src.Name)
+	dst.LinkID = src.LinkID
+	dst.Type = src.Type
+	dst.Threshold, dst.ChangePercent, dst.Direction, dst.WindowHours, dst.BaselineWindows = 0, 0, "", 0, 0
+
+	switch src.Type {
+	case domain.AlertThreshold:
+		if src.Threshold <= 0 {
+			return errors.New("threshold must be positive")
+		}
+		dst.Threshold = src.Threshold
+	case domain.AlertChange:
+		if src.ChangePercent <= 0 {
+			return errors.New("change_percent must be positive")
+		}
+		dst.ChangePercent = src.ChangePercent
+		dst.Direction = src.Direction
+		switch dst.Direction {
+		case "":
+			dst.Direction = domain.AlertDirectionBoth
+		case domain.AlertDirectionUp, domain.AlertDirectionDown, domain.AlertDirectionBoth:
+		default:
+			return errors.New("direction must be up, down or both")
+		}
+		dst.WindowHours = orDefault(src.WindowHours, alertDefaultWindowHours)
+		dst.BaselineWindows = orDefault(src.BaselineWindows, alertDefaultBaselineWindows)
+		if dst.BaselineWindows < 1 || dst.BaselineWindows > alertMaxBaselineWindows {
+			return fmt.Errorf("baseline_windows must be between 1 and %d", alertMaxBaselineWindows)
+		}
+	case domain.AlertZeroTraffic:
+		dst.WindowHours = orDefault(src.WindowHours, alertDefaultZeroHours)
+	}
+	if dst.Type != domain.AlertThreshold && (dst.WindowHours < 1 || dst.WindowHours > alertMaxWindowHours) {
+		return fmt.Errorf("window_hours must be between 1 and %d", alertMaxWindowHours)
+	}
+
+	dst.Channels = src.Channels
+	if len(dst.Channels) == 0 {
+		dst.Channels = []string{domain.AlertChannelWebhook}
+	}
+	dst.Emails = nil
+	for _, channel := range dst.Channels {
+		switch channel {
+		case domain.AlertChannelWebhook:
+		case domain.AlertChannelEmail:
+			if s.mailer == nil {
+				return errors.New("email alerts require SMTP to be configured")
+			}
+			if len(src.Emails) == 0 {
+				return errors.New("email alerts require at least one address in emails")
+			}
+			// Only the bare address is kept, so display names never reach mail headers
+			for _, raw := range src.Emails {
+				addr, err := mail.ParseAddress(raw)
+				if err != nil {
+					return errors.New("invalid email address: " + raw)
+				}
+				dst.Emails = append(dst.Emails, addr.Address)
+			}
+		default:
+			return errors.New("unknown alert channel: " + channel)
+		}
+	}
+	return nil
+}
+
+// orDefault returns value, or fallback when value is unset
+func orDefault(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// EvaluateAlerts checks every active rule and notifies for links that newly match.
+// It returns the number of alerts triggered.
+func (s *AlertService) EvaluateAlerts(ctx context.Context) (int, error) {
+	rules, err := s.repo.ListAlertRules(ctx, map[string]interface{}{"active": true})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	triggered := 0
+	for i := range rules {
+		n, err := s.evaluateRule(ctx, &rules[i], now)
+		triggered += n
+		if err != nil {
+			return triggered, fmt.Errorf("alert rule %d: %w", rules[i].ID, err)
+		}
+	}
+	return triggered, nil
+}
+
+func (s *AlertService) evaluateRule(ctx context.Context, rule *domain.AlertRule, now time.Time) (int, error) {
+	matches, err := s.matchRule(ctx, rule, now)
+	if err != nil {
+		return 0, err
+	}
+	firing, err := s.repo.ListFiringAlerts(ctx, rule.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Re-arm links that no longer match
+	for linkID := range firing {
+		if _, ok := matches[linkID]; !ok {
+			if err := s.repo.ClearAlertFiring(ctx, rule.ID, linkID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	linkIDs := make([]int64, 0, len(matches))
+	for linkID := range matches {
+		if _, ok := firing[linkID]; !ok {
+			linkIDs = append(linkIDs, linkID)
+		}
+	}
+	slices.Sort(linkIDs)
+
+	triggered := 0
+	for _, linkID := range linkIDs {
+		link, err := s.repo.GetByID(ctx, linkID)
+		if err != nil {
+			return triggered, err
+		}
+		if link == nil {
+			continue
+		}
+
+		alert := matches[linkID]
+		alert.RuleID, alert.RuleName, alert.Type = rule.ID, rule.Name, rule.Type
+		alert.LinkID, alert.ShortCode = link.ID, link.ShortCode
+		alert.TriggeredAt = now.UTC()
+		alert.Message = alertMessage(rule, alert)
+
+		// A failed email leaves the link unmarked so the next run retries it
+		if err := s.notify(ctx, rule, alert); err != nil {
+			log.Printf("Alert %d for link %d not sent: %v", rule.ID, linkID, err)
+			continue
+		}
+		if err := s.repo.SetAlertFiring(ctx, rule.ID, linkID, now); err != nil {
+			return triggered, err
+		}
+		triggered++
+	}
+	return triggered, nil
+}
+
+// matchRule returns the links whose clicks currently meet the rule's condition. Windows
+// end at the start of the current hour, so only whole hours are compared.
+func (s *AlertService) matchRule(ctx context.Context, rule *domain.AlertRule, now time.Time) (map[int64]*domain.Alert, error) {
+	matches := make(map[int64]*domain.Alert)
+	end := now.UTC().Truncate(time.Hour)
+	window := time.Duration(rule.WindowHours) * time.Hour
+
+	switch rule.Type {
+	case domain.AlertThreshold:
+		totals, err := s.repo.LinkClickTotals(ctx, rule.LinkID)
+		if err != nil {
+			return nil, err
+		}
+		for linkID, clicks := range totals {
+			if clicks >= rule.Threshold {
+				matches[linkID] = &domain.Alert{Value: float64(clicks)}
+			}
+		}
+
+	case domain.AlertChange:
+		start := end.Add(-window)
+		current, err := s.repo.CountClicksByLink(ctx, rule.LinkID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		baseline, err := s.repo.CountClicksByLink(ctx, rule.LinkID, start.Add(-window*time.Duration(rule.BaselineWindows)), start)
+		if err != nil {
+			return nil, err
+		}
+		for linkID, total := range baseline {
+			avg := float64(total) / float64(rule.BaselineWindows)
+			clicks := float64(current[linkID])
+			if changeExceeds(clicks, avg, rule.ChangePercent, rule.Direction) {
+				matches[linkID] = &domain.Alert{Value: clicks, Baseline: avg}
+			}
+		}
+
+	case domain.AlertZeroTraffic:
+		start := end.Add(-window)
+		current, err := s.repo.CountClicksByLink(ctx, rule.LinkID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		previous, err := s.repo.CountClicksByLink(ctx, rule.LinkID, start.Add(-window), start)
+		if err != nil {
+			return nil, err
+		}
+		// Links that never had traffic don't alert
+		for linkID := range previous {
+			if current[linkID] == 0 {
+				matches[linkID] = &domain.Alert{}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// changeExceeds reports whether current differs from a non-zero baseline by at least
+// percent in the given direction
+func changeExceeds(current, baseline, percent float64, direction string) bool {
+	if baseline <= 0 {
+		return false
+	}
+	change := (current - baseline) / baseline * 100
+	switch direction {
+	case domain.AlertDirectionUp:
+		return change >= percent
+	case domain.AlertDirectionDown:
+		return -change >= percent
+	default:
+		return change >= percent || -change >= percent
+	}
+}
+
+func alertMessage(rule *domain.AlertRule, alert *domain.Alert) string {
+	switch rule.Type {
+	case domain.AlertThreshold:
+		return fmt.Sprintf("/%s reached %d clicks (threshold %d)", alert.ShortCode, int64(alert.Value), rule.Threshold)
+	case domain.AlertChange:
+		change := (alert.Value - alert.Baseline) / alert.Baseline * 100
+		return fmt.Sprintf("/%s had %d clicks in the last %dh, %+.0f%% vs the trailing average of %.1f",
+			alert.ShortCode, int64(alert.Value), rule.WindowHours, change, alert.Baseline)
+	default:
+		return fmt.Sprintf("/%s had no clicks in the last %dh", alert.ShortCode, rule.WindowHours)
+	}
+}
+
+// notify sends the alert on every channel of the rule; email goes first since webhook
+// events can't be taken back if it fails
+func (s *AlertService) notify(ctx context.Context, rule *domain.AlertRule, alert *domain.Alert) error {
+	if slices.Contains(rule.Channels, domain.AlertChannelEmail) {
+		if s.mailer == nil {
+			return errors.New("SMTP is not configured")
+		}
+		subject := "Alert: " + alert.Message
+		if rule.Name != "" {
+			subject = "Alert " + rule.Name + ": " + alert.Message
+		}
+		body := fmt.Sprintf("%s\n\nRule: #%d %s (%s)\nLink: /%s (ID %d)\nTriggered at: %s\n",
+			alert.Message, rule.ID, rule.Name, rule.Type, alert.ShortCode, alert.LinkID, alert.TriggeredAt.Format(time.RFC3339))
+		if err := s.mailer.Send(ctx, rule.Emails, subject, body); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(rule.Channels, domain.AlertChannelWebhook) && s.events != nil {
+		s.events.Publish(ctx, domain.EventAlertTriggered, alert)
+	}
+	return nil
+}
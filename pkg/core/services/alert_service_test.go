@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+func TestChangeExceeds(t *testing.T) {
+	tests := []struct {
+		current, baseline, percent float64
+		direction                  string
+		want                       bool
+	}{
+		{30, 10, 200, domain.AlertDirectionUp, true},
+		{29, 10, 200, domain.AlertDirectionUp, false},
+		{30, 10, 200, domain.AlertDirectionDown, false},
+		{2, 10, 80, domain.AlertDirectionDown, true},
+		{2, 10, 80, domain.AlertDirectionBoth, true},
+		{0, 10, 100, domain.AlertDirectionDown, true},
+		{50, 0, 10, domain.AlertDirectionBoth, false}, // no baseline to compare with
+	}
+	for _, tt := range tests {
+		if got := changeExceeds(tt.current, tt.baseline, tt.percent, tt.direction); got != tt.want {
+			t.Errorf("changeExceeds(%v, %v, %v, %s) = %v, want %v", tt.current, tt.baseline, tt.percent, tt.direction, got, tt.want)
+		}
+	}
+}
+
+// alertRepo serves fixed click totals and keeps firing state in memory
+type alertRepo struct {
+	ports.LinkRepository
+	totals map[int64]int64
+	firing map[int64]time.Time
+}
+
+func (r *alertRepo) LinkClickTotals(ctx context.Context, linkID int64) (map[int64]int64, error) {
+	return r.totals, nil
+}
+
+func (r *alertRepo) GetByID(ctx context.Context, id int64) (*domain.Link, error) {
+	return &domain.Link{ID: id, ShortCode: "promo"}, nil
+}
+
+func (r *alertRepo) ListFiringAlerts(ctx context.Context, ruleID int64) (map[int64]time.Time, error) {
+	return r.firing, nil
+}
+
+func (r *alertRepo) SetAlertFiring(ctx context.Context, ruleID, linkID int64, at time.Time) error {
+	r.firing[linkID] = at
+	return nil
+}
+
+func (r *alertRepo) ClearAlertFiring(ctx context.Context, ruleID, linkID int64) error {
+	delete(r.firing, linkID)
+	return nil
+}
+
+type recordingPublisher struct {
+	events []interface{}
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, eventType string, data interface{}) {
+	p.events = append(p.events, data)
+}
+
+func TestEvaluateRuleNotifiesOncePerCrossing(t *testing.T) {
+	repo := &alertRepo{totals: map[int64]int64{1: 999}, firing: map[int64]time.Time{}}
+	events := &recordingPublisher{}
+	s := NewAlertService(repo, events, nil)
+	rule := &domain.AlertRule{ID: 7, Type: domain.AlertThreshold, Threshold: 1000, Channels: []string{domain.AlertChannelWebhook}}
+
+	steps := []struct {
+		clicks int64
+		want   int // alerts triggered by this run
+	}{
+		{999, 0},
+		{1000, 1},
+		{1500, 0}, // still firing
+	}
+	for _, step := range steps {
+		repo.totals[1] = step.clicks
+		n, err := s.evaluateRule(context.Background(), rule, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != step.want {
+			t.Errorf("clicks %d: triggered %d, want %d", step.clicks, n, step.want)
+		}
+	}
+	if len(events.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(events.events))
+	}
+
+	alert := events.events[0].(*domain.Alert)
+	if alert.ShortCode != "promo" || alert.Value != 1000 || alert.RuleID != 7 {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	// Falling back below the threshold re-arms the rule
+	repo.totals[1] = 10
+	if _, err := s.evaluateRule(context.Background(), rule, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.firing) != 0 {
+		t.Errorf("rule still firing after condition cleared")
+	}
+}
+
+// ruleRepo keeps the last created rule
+type ruleRepo struct {
+	ports.LinkRepository
+	created *domain.AlertRule
+}
+
+func (r *ruleRepo) CreateAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	r.created = rule
+	return nil
+}
+
+// nopMailer accepts every message
+type nopMailer struct{}
+
+func (nopMailer) Send(ctx context.Context, to []string, subject, body string) error { return nil }
+
+func TestCreateAlertRuleStoresBareAddresses(t *testing.T) {
+	repo := &ruleRepo{}
+	svc := NewAlertService(repo, nil, nopMailer{})
+	rule := &domain.AlertRule{Type: domain.AlertThreshold, Threshold: 10, Channels: []string{domain.AlertChannelEmail}}
+
+	rule.Emails = []string{"Jane Doe <jane@example.com>", " ops@example.com "}
+	if _, err := svc.CreateAlertRule(context.Background(), rule); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"jane@example.com", "ops@example.com"}; !slices.Equal(repo.created.Emails, want) {
+		t.Errorf("stored emails = %q, want %q", repo.created.Emails, want)
+	}
+
+	rule.Emails = []string{"ops@example.com\r\nBcc: victim@example.com"}
+	if _, err := svc.CreateAlertRule(context.Background(), rule); err == nil {
+		t.Error("an address with a header injection was accepted")
+	}
+}
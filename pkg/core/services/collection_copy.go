@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// maxImportItems caps the items of an imported collection
+const maxImportItems = 500
+
+// ExportCollection returns a portable copy of the collection, or nil if there is none
+func (s *CollectionService) ExportCollection(ctx context.Context, id int64) (*domain.CollectionExport, error) {
+	collection, err := s.GetCollection(ctx, id)
+	if err != nil || collection == nil {
+		return nil, err
+	}
+	return domain.NewCollectionExport(collection, time.Now()), nil
+}
+
+// CloneCollection copies a collection with its appearance and items to a new slug. The
+// clone is an unscheduled draft and not a template. Its link items share the original's
+// links unless opts.Links asks to duplicate them.
+func (s *CollectionService) CloneCollection(ctx context.Context, id int64, opts domain.CopyOptions) (*domain.Collection, error) {
+	if opts.Slug == "" {
+		return nil, errors.New("slug is required")
+	}
+	export, err := s.ExportCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if export == nil {
+		return nil, errors.New("collection not found")
+	}
+
+	export.Visibility = domain.VisibilityDraft
+	export.PublishAt, export.UnpublishAt = nil, nil
+	export.IsTemplate = false
+	return s.createFromExport(ctx, export, opts)
+}
+
+// ImportCollection creates a collection from an export, at the exported slug unless
+// opts.Slug is set. Shared links are matched by short code and destination; links that
+// don't exist yet are created, keeping their short code when it is free.
+func (s *CollectionService) ImportCollection(ctx context.Context, export *domain.CollectionExport, opts domain.CopyOptions) (*domain.Collection, error) {
+	if export.Version != domain.CollectionExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+	if len(export.Items) > maxImportItems {
+		return nil, fmt.Errorf("exports can have at most %d items", maxImportItems)
+	}
+	if opts.Slug == "" {
+		opts.Slug = export.Slug
+	}
+	return s.createFromExport(ctx, export, opts)
+}
+
+// createFromExport creates the collection and its items. Items are validated before
+// anything is stored; if storing one fails, the new collection is removed again with the
+// items and links created for it. collection.created is only published on success.
+func (s *CollectionService) createFromExport(ctx context.Context, export *domain.CollectionExport, opts domain.CopyOptions) (*domain.Collection, error) {
+	mode := opts.Links
+	if mode == "" {
+		mode = domain.LinksShare
+	}
+	if !slices.Contains(domain.CollectionLinkModes, mode) {
+		return nil, fmt.Errorf("links must be one of %s", strings.Join(domain.CollectionLinkModes, ", "))
+	}
+	title := opts.Title
+	if title == "" {
+		title = export.Title
+	}
+
+	items := make([]*domain.CollectionItem, len(export.Items))
+	for i, exported := range export.Items {
+		input := &domain.CollectionItem{
+			Type:         exported.Type,
+			Label:        exported.Label,
+			Icon:         exported.Icon,
+			ThumbnailURL: exported.ThumbnailURL,
+			Highlight:    exported.Highlight,
+			Text:         exported.Text,
+			URL:          exported.URL,
+			Socials:      exported.Socials,
+			Style:        exported.Style,
+		}
+		if exported.Type == domain.ItemLink {
+			if exported.Link == nil || exported.Link.OriginalURL == "" {
+				return nil, fmt.Errorf("item %d: link.original_url is required", i+1)
+			}
+			input.LinkID = 1 // Placeholder to validate the rest; the link is resolved later
+		}
+		item, err := normalizeItem(input)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		items[i] = item
+	}
+
+	collection, err := s.createCollection(ctx, &domain.Collection{
+		Slug:        opts.Slug,
+		Title:       title,
+		Description: export.Description,
+		AvatarURL:   export.AvatarURL,
+		Appearance:  export.Appearance,
+		Visibility:  export.Visibility,
+		Password:    export.Password,
+		PublishAt:   export.PublishAt,
+		UnpublishAt: export.UnpublishAt,
+		IsTemplate:  export.IsTemplate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	linkIDs, err := s.createItems(ctx, collection.ID, export.Items, items, mode)
+	if err != nil {
+		s.discardCopy(ctx, collection.ID, linkIDs)
+		return nil, err
+	}
+	s.publish(ctx, domain.EventCollectionCreated, collection)
+	return s.GetCollection(ctx, collection.ID)
+}
+
+// createItems stores the validated items in order, resolving the links of link items. It
+// returns the IDs of the links it created, on failure too.
+func (s *CollectionService) createItems(ctx context.Context, collectionID int64, exported []domain.ExportedItem, items []*domain.CollectionItem, mode string) (linkIDs []int64, err error) {
+	for i, item := range items {
+		if item.Type == domain.ItemLink {
+			link, created, err := s.copyLink(ctx, exported[i].Link, mode)
+			if err != nil {
+				return linkIDs, fmt.Errorf("item %d: %w", i+1, err)
+			}
+			if created {
+				linkIDs = append(linkIDs, link.ID)
+			}
+			item.LinkID, item.Link = link.ID, link
+		}
+		item.CollectionID = collectionID
+		item.CreatedAt = time.Now()
+		item.UpdatedAt = item.CreatedAt
+		if err := s.repo.CreateCollectionItem(ctx, item, 0); err != nil {
+			return linkIDs, err
+		}
+	}
+	return linkIDs, nil
+}
+
+// discardCopy removes a collection that could not be copied completely, with its items
+// and the links created for it. No collection event was published for it, so none is for
+// its removal; its links were announced by the link service and are deleted through it.
+func (s *CollectionService) discardCopy(ctx context.Context, collectionID int64, linkIDs []int64) {
+	for _, id := range linkIDs {
+		if err := s.links.DeleteLink(ctx, id); err != nil {
+			log.Printf("Failed to delete link %d of partially copied collection %d: %v", id, collectionID, err)
+		}
+	}
+	if err := s.repo.DeleteCollection(ctx, collectionID); err != nil {
+		log.Printf("Failed to delete partially copied collection %d: %v", collectionID, err)
+	}
+}
+
+// copyLink returns the link of a copied link item: the existing link with the same short
+// code and destination when sharing, otherwise a new one. created reports a new link.
+func (s *CollectionService) copyLink(ctx context.Context, exported *domain.ExportedLink, mode string) (link *domain.Link, created bool, err error) {
+	var existing *domain.Link
+	if exported.ShortCode != "" {
+		if existing, err = s.repo.GetByShortCode(ctx, exported.ShortCode); err != nil {
+			return nil, false, err
+		}
+	}
+	if mode == domain.LinksShare && existing != nil && existing.OriginalURL == exported.OriginalURL {
+		return existing, false, nil
+	}
+
+	if s.links == nil {
+		return nil, false, errors.New("creating links is not available")
+	}
+	if existing == nil && exported.ShortCode != "" {
+		// The code may still be taken by a deleted link; a new code is used then
+		if link, err := s.links.Shorten(ctx, exported.OriginalURL, exported.Title, exported.Tags, exported.ShortCode); err == nil {
+			return link, true, nil
+		}
+	}
+	if link, err = s.links.Shorten(ctx, exported.OriginalURL, exported.Title, exported.Tags, ""); err != nil {
+		return nil, false, err
+	}
+	return link, true, nil
+}
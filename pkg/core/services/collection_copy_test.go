@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// collectionRepo keeps collections, their items and links in memory
+type collectionRepo struct {
+	ports.LinkRepository
+	collections map[int64]*domain.Collection
+	items       map[int64][]domain.CollectionItem
+	links       map[string]*domain.Link
+}
+
+func newCollectionRepo() *collectionRepo {
+	return &collectionRepo{collections: map[int64]*domain.Collection{}, items: map[int64][]domain.CollectionItem{}, links: map[string]*domain.Link{}}
+}
+
+func (r *collectionRepo) CreateCollection(ctx context.Context, c *domain.Collection) error {
+	c.ID = int64(len(r.collections) + 1)
+	stored := *c
+	r.collections[c.ID] = &stored
+	return nil
+}
+
+func (r *collectionRepo) GetCollection(ctx context.Context, id int64) (*domain.Collection, error) {
+	if c, ok := r.collections[id]; ok {
+		copied := *c
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *collectionRepo) GetCollectionBySlug(ctx context.Context, slug string) (*domain.Collection, error) {
+	for id, c := range r.collections {
+		if c.Slug == slug {
+			return r.GetCollection(ctx, id)
+		}
+	}
+	return nil, nil
+}
+
+func (r *collectionRepo) DeleteCollection(ctx context.Context, id int64) error {
+	delete(r.collections, id)
+	delete(r.items, id)
+	return nil
+}
+
+func (r *collectionRepo) CreateCollectionItem(ctx context.Context, item *domain.CollectionItem, position int) error {
+	item.ID = int64(len(r.items[item.CollectionID]) + 1)
+	r.items[item.CollectionID] = append(r.items[item.CollectionID], *item)
+	return nil
+}
+
+func (r *collectionRepo) ListCollectionItems(ctx context.Context, collectionID int64) ([]domain.CollectionItem, error) {
+	return r.items[collectionID], nil
+}
+
+func (r *collectionRepo) GetByShortCode(ctx context.Context, code string) (*domain.Link, error) {
+	return r.links[code], nil
+}
+
+// shortener creates links in a collectionRepo with sequential codes, except for failURL
+type shortener struct {
+	ports.LinkService
+	repo    *collectionRepo
+	failURL string
+}
+
+func (s *shortener) Shorten(ctx context.Context, originalURL, title string, tags []string, customCode string) (*domain.Link, error) {
+	if originalURL == s.failURL {
+		return nil, fmt.Errorf("cannot shorten %s", originalURL)
+	}
+	if customCode == "" {
+		customCode = fmt.Sprintf("new%d", len(s.repo.links)+1)
+	} else if s.repo.links[customCode] != nil {
+		return nil, fmt.Errorf("custom code already exists")
+	}
+	link := &domain.Link{ID: int64(100 + len(s.repo.links)), ShortCode: customCode, OriginalURL: originalURL, Title: title, Tags: tags}
+	s.repo.links[customCode] = link
+	return link, nil
+}
+
+func (s *shortener) DeleteLink(ctx context.Context, id int64) error {
+	maps.DeleteFunc(s.repo.links, func(code string, link *domain.Link) bool { return link.ID == id })
+	return nil
+}
+
+func TestCloneCollection(t *testing.T) {
+	ctx := context.Background()
+	repo := newCollectionRepo()
+	svc := NewCollectionService(repo, WithCollectionLinks(&shortener{repo: repo}))
+
+	blog := &domain.Link{ID: 7, ShortCode: "blog01", OriginalURL: "https://blog.example.com/"}
+	repo.links[blog.ShortCode] = blog
+	source, err := svc.CreateCollection(ctx, &domain.Collection{Slug: "us", Title: "US", IsTemplate: true,
+		Appearance: domain.CollectionAppearance{Theme: domain.ThemeOcean}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []domain.CollectionItem{
+		{Type: domain.ItemHeading, Text: "Latest"},
+		{Type: domain.ItemLink, LinkID: blog.ID, Label: "Blog", Highlight: true},
+	} {
+		normalized, err := normalizeItem(&item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		normalized.CollectionID = source.ID
+		if normalized.Type == domain.ItemLink {
+			normalized.Link = blog
+		}
+		repo.CreateCollectionItem(ctx, normalized, 0)
+	}
+
+	shared, err := svc.CloneCollection(ctx, source.ID, domain.CopyOptions{Slug: "uk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Slug != "uk" || shared.Title != "US" || shared.Visibility != domain.VisibilityDraft || shared.IsTemplate {
+		t.Errorf("clone = %+v, want a draft copy that is not a template", shared)
+	}
+	if shared.Appearance.Theme != domain.ThemeOcean || len(shared.Items) != 2 {
+		t.Fatalf("clone lost its appearance or items: %+v", shared)
+	}
+	if link := shared.Items[1]; link.LinkID != blog.ID || link.Label != "Blog" || !link.Highlight {
+		t.Errorf("shared link item = %+v", link)
+	}
+
+	duplicated, err := svc.CloneCollection(ctx, source.ID, domain.CopyOptions{Slug: "de", Title: "DE", Links: domain.LinksDuplicate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := duplicated.Items[1].Link; link == nil || link.ID == blog.ID || link.OriginalURL != blog.OriginalURL {
+		t.Errorf("duplicated link = %+v, want a new link to the same destination", link)
+	}
+
+	if _, err := svc.CloneCollection(ctx, source.ID, domain.CopyOptions{Slug: "uk"}); err == nil {
+		t.Error("clone to a taken slug succeeded")
+	}
+	if _, err := svc.CloneCollection(ctx, source.ID, domain.CopyOptions{Slug: "fr", Links: "move"}); err == nil {
+		t.Error("unknown links mode accepted")
+	}
+}
+
+func TestImportCollection(t *testing.T) {
+	ctx := context.Background()
+	repo := newCollectionRepo()
+	svc := NewCollectionService(repo, WithCollectionLinks(&shortener{repo: repo}))
+	repo.links["taken1"] = &domain.Link{ID: 1, ShortCode: "taken1", OriginalURL: "https://other.example.com/"}
+
+	export := &domain.CollectionExport{
+		Version: domain.CollectionExportVersion,
+		Slug:    "jane",
+		Items: []domain.ExportedItem{
+			{Type: domain.ItemLink, Link: &domain.ExportedLink{ShortCode: "free01", OriginalURL: "https://a.example.com/"}},
+			{Type: domain.ItemLink, Link: &domain.ExportedLink{ShortCode: "taken1", OriginalURL: "https://b.example.com/"}},
+		},
+	}
+	imported, err := svc.ImportCollection(ctx, export, domain.CopyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Slug != "jane" || len(imported.Items) != 2 {
+		t.Fatalf("imported = %+v", imported)
+	}
+	if code := imported.Items[0].Link.ShortCode; code != "free01" {
+		t.Errorf("free short code = %q, want it kept", code)
+	}
+	if link := imported.Items[1].Link; link.ShortCode == "taken1" || link.OriginalURL != "https://b.example.com/" {
+		t.Errorf("link with a taken code = %+v, want a new code", link)
+	}
+
+	bad := *export
+	bad.Items = []domain.ExportedItem{{Type: domain.ItemHeading}}
+	if _, err := svc.ImportCollection(ctx, &bad, domain.CopyOptions{Slug: "bad"}); err == nil {
+		t.Error("invalid item imported")
+	}
+	if c, _ := repo.GetCollectionBySlug(ctx, "bad"); c != nil {
+		t.Error("invalid import left a collection behind")
+	}
+	bad.Version = 2
+	if _, err := svc.ImportCollection(ctx, &bad, domain.CopyOptions{Slug: "v2"}); err == nil {
+		t.Error("unknown export version imported")
+	}
+}
+
+func TestFailedCopyLeavesNothingBehind(t *testing.T) {
+	ctx := context.Background()
+	repo := newCollectionRepo()
+	events := &recordingPublisher{}
+	svc := NewCollectionService(repo, WithCollectionEvents(events), WithCollectionLinks(&shortener{repo: repo, failURL: "https://c.example.com/"}))
+	repo.links["shared"] = &domain.Link{ID: 1, ShortCode: "shared", OriginalURL: "https://a.example.com/"}
+
+	export := &domain.CollectionExport{
+		Version: domain.CollectionExportVersion,
+		Slug:    "jane",
+		Items: []domain.ExportedItem{
+			{Type: domain.ItemLink, Link: &domain.ExportedLink{ShortCode: "shared", OriginalURL: "https://a.example.com/"}},
+			{Type: domain.ItemLink, Link: &domain.ExportedLink{OriginalURL: "https://b.example.com/"}},
+			{Type: domain.ItemLink, Link: &domain.ExportedLink{OriginalURL: "https://c.example.com/"}},
+		},
+	}
+	if _, err := svc.ImportCollection(ctx, export, domain.CopyOptions{}); err == nil {
+		t.Fatal("import with a failing link succeeded")
+	}
+
+	if len(repo.collections) != 0 {
+		t.Errorf("collections left = %v", repo.collections)
+	}
+	for id, items := range repo.items {
+		if len(items) > 0 {
+			t.Errorf("collection %d left %d items", id, len(items))
+		}
+	}
+	if len(repo.links) != 1 || repo.links["shared"] == nil {
+		t.Errorf("links = %v, want only the shared one", slices.Collect(maps.Keys(repo.links)))
+	}
+	if len(events.events) != 0 {
+		t.Errorf("published %d events for a failed import", len(events.events))
+	}
+
+	export.Items = export.Items[:2]
+	if _, err := svc.ImportCollection(ctx, export, domain.CopyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events.events) != 1 {
+		t.Errorf("published %d events for an import, want collection.created once", len(events.events))
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+const (
+	maxHeadingLen     = 200
+	maxTextLen        = 2000
+	maxSocialProfiles = 12
+	maxIconRunes      = 8
+)
+
+// loadItems fills the collection's items, and its links in page order
+func (s *CollectionService) loadItems(ctx context.Context, collection *domain.Collection) error {
+	items, err := s.repo.ListCollectionItems(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+	collection.Items = items
+	collection.Links = nil
+	for _, item := range items {
+		if item.Link != nil {
+			collection.Links = append(collection.Links, *item.Link)
+		}
+	}
+	return nil
+}
+
+// AddItem inserts a block at a 1-based position in the collection, or at the end when
+// position is 0
+func (s *CollectionService) AddItem(ctx context.Context, collectionID int64, input *domain.CollectionItem, position int) (*domain.CollectionItem, error) {
+	if position < 0 {
+		return nil, errors.New("position must be 1 or more")
+	}
+	collection, err := s.repo.GetCollection(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return nil, errors.New("collection not found")
+	}
+
+	item, err := normalizeItem(input)
+	if err != nil {
+		return nil, err
+	}
+	if item.Type == domain.ItemLink {
+		link, err := s.repo.GetByID(ctx, item.LinkID)
+		if err != nil {
+			return nil, err
+		}
+		if link == nil {
+			return nil, errors.New("link not found")
+		}
+		item.Link = link
+	}
+
+	item.CollectionID = collectionID
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	if err := s.repo.CreateCollectionItem(ctx, item, position); err != nil {
+		return nil, err
+	}
+	return item, s.touch(ctx, collectionID)
+}
+
+func (s *CollectionService) GetItem(ctx context.Context, collectionID, itemID int64) (*domain.CollectionItem, error) {
+	return s.repo.GetCollectionItem(ctx, collectionID, itemID)
+}
+
+// UpdateItem replaces an item's content and style. Its type and link cannot change;
+// remove the item and add a new one instead.
+func (s *CollectionService) UpdateItem(ctx context.Context, collectionID, itemID int64, input *domain.CollectionItem) (*domain.CollectionItem, error) {
+	existing, err := s.repo.GetCollectionItem(ctx, collectionID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, errors.New("item not found")
+	}
+	if input.Type != existing.Type {
+		return nil, errors.New("type cannot be changed")
+	}
+	if input.LinkID != existing.LinkID {
+		return nil, errors.New("link_id cannot be changed")
+	}
+
+	item, err := normalizeItem(input)
+	if err != nil {
+		return nil, err
+	}
+	item.ID = existing.ID
+	item.CollectionID = existing.CollectionID
+	item.Link = existing.Link
+	item.CreatedAt = existing.CreatedAt
+	item.UpdatedAt = time.Now()
+	if err := s.repo.UpdateCollectionItem(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, s.touch(ctx, collectionID)
+}
+
+func (s *CollectionService) RemoveItem(ctx context.Context, collectionID, itemID int64) error {
+	if err := s.repo.DeleteCollectionItem(ctx, collectionID, itemID); err != nil {
+		return err
+	}
+	return s.touch(ctx, collectionID)
+}
+
+// ReorderItems sets the order of the collection's blocks; itemIDs must list each of them once
+func (s *CollectionService) ReorderItems(ctx context.Context, collectionID int64, itemIDs []int64) error {
+	if err := s.repo.ReorderCollectionItems(ctx, collectionID, itemIDs); err != nil {
+		return err
+	}
+	return s.touch(ctx, collectionID)
+}
+
+// touch bumps the collection's UpdatedAt after its items change, so feeds and caches
+// keyed on it see the change
+func (s *CollectionService) touch(ctx context.Context, collectionID int64) error {
+	return s.repo.TouchCollection(ctx, collectionID, time.Now())
+}
+
+// normalizeItem validates an item and keeps only the content its type uses
+func normalizeItem(input *domain.CollectionItem) (*domain.CollectionItem, error) {
+	item := &domain.CollectionItem{Type: input.Type, Style: input.Style}
+	text := strings.TrimSpace(input.Text)
+	rawURL := strings.TrimSpace(input.URL)
+
+	switch input.Type {
+	case domain.ItemLink:
+		if input.LinkID <= 0 {
+			return nil, errors.New("link_id is required")
+		}
+		item.LinkID = input.LinkID
+		item.Label = strings.TrimSpace(input.Label)
+		if len(item.Label) > maxHeadingLen {
+			return nil, fmt.Errorf("label must be at most %d bytes", maxHeadingLen)
+		}
+		item.Icon = strings.TrimSpace(input.Icon)
+		if utf8.RuneCountInString(item.Icon) > maxIconRunes {
+			return nil, fmt.Errorf("icon must be at most %d characters", maxIconRunes)
+		}
+		item.ThumbnailURL = strings.TrimSpace(input.ThumbnailURL)
+		if !isHTTPURL(item.ThumbnailURL) {
+			return nil, errors.New("thumbnail_url must be an http(s) URL")
+		}
+		item.Highlight = input.Highlight
+	case domain.ItemHeading, domain.ItemText:
+		limit := maxTextLen
+		if input.Type == domain.ItemHeading {
+			limit = maxHeadingLen
+		}
+		if text == "" {
+			return nil, errors.New("text is required")
+		}
+		if len(text) > limit {
+			return nil, fmt.Errorf("text must be at most %d bytes", limit)
+		}
+		item.Text = text
+	case domain.ItemImage:
+		if rawURL == "" || !isHTTPURL(rawURL) {
+			return nil, errors.New("url must be an http(s) image URL")
+		}
+		if len(text) > maxHeadingLen {
+			return nil, fmt.Errorf("text must be at most %d bytes", maxHeadingLen)
+		}
+		item.URL, item.Text = rawURL, text
+	case domain.ItemEmbed:
+		if _, ok := domain.EmbedSource(rawURL); !ok {
+			return nil, errors.New("url must be a YouTube, Vimeo or Spotify page")
+		}
+		item.URL = rawURL
+	case domain.ItemSocial:
+		if len(input.Socials) == 0 || len(input.Socials) > maxSocialProfiles {
+			return nil, fmt.Errorf("socials must list 1 to %d profiles", maxSocialProfiles)
+		}
+		for _, profile := range input.Socials {
+			profile.Platform = strings.ToLower(strings.TrimSpace(profile.Platform))
+			profile.URL = strings.TrimSpace(profile.URL)
+			if !slices.Contains(domain.SocialPlatforms, profile.Platform) {
+				return nil, fmt.Errorf("platform must be one of %s", strings.Join(domain.SocialPlatforms, ", "))
+			}
+			if !validProfileURL(profile) {
+				return nil, fmt.Errorf("invalid %s url", profile.Platform)
+			}
+			item.Socials = append(item.Socials, profile)
+		}
+	default:
+		return nil, fmt.Errorf("type must be one of %s", strings.Join(domain.CollectionItemTypes, ", "))
+	}
+
+	if err := normalizeItemStyle(&item.Style); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// validProfileURL accepts mailto: addresses for email and http(s) URLs otherwise
+func validProfileURL(profile domain.SocialProfile) bool {
+	if profile.Platform == "email" {
+		u, err := url.Parse(profile.URL)
+		return err == nil && u.Scheme == "mailto" && strings.Contains(u.Opaque, "@")
+	}
+	return profile.URL != "" && isHTTPURL(profile.URL)
+}
+
+func normalizeItemStyle(style *domain.ItemStyle) error {
+	switch style.Align {
+	case "", "left", "center", "right":
+	default:
+		return errors.New("style.align must be left, center or right")
+	}
+	for name, color := range map[string]*string{"style.text_color": &style.TextColor, "style.background_color": &style.BackgroundColor} {
+		*color = strings.ToLower(strings.TrimSpace(*color))
+		if *color != "" && !hexColor.MatchString(*color) {
+			return fmt.Errorf("%s must be a hex color like #1c1917", name)
+		}
+	}
+	return nil
+}
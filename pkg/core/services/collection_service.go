@@ -3,6 +3,11 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
@@ -10,36 +15,93 @@ import (
 )
 
 type CollectionService struct {
-	repo ports.LinkRepository
+	repo   ports.LinkRepository
+	events ports.EventPublisher
+	links  ports.LinkService // Creates links for copied collections
+
+	unlocks *unlockLimiter // Throttles password guessing
+}
+
+// CollectionServiceOption configures optional CollectionService dependencies
+type CollectionServiceOption func(*CollectionService)
+
+// WithCollectionEvents emits collection.created/updated/deleted events (e.g. to webhooks)
+func WithCollectionEvents(p ports.EventPublisher) CollectionServiceOption {
+	return func(s *CollectionService) {
+		s.events = p
+	}
+}
+
+// WithCollectionLinks creates the links of cloned and imported collections through the
+// link service, so they get short codes and link.created events like any other link
+func WithCollectionLinks(links ports.LinkService) CollectionServiceOption {
+	return func(s *CollectionService) {
+		s.links = links
+	}
+}
+
+func NewCollectionService(repo ports.LinkRepository, opts ...CollectionServiceOption) *CollectionService {
+	s := &CollectionService{repo: repo, unlocks: newUnlockLimiter()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// publish emits an event if a publisher is configured
+func (s *CollectionService) publish(ctx context.Context, eventType string, data interface{}) {
+	if s.events != nil {
+		s.events.Publish(ctx, eventType, data)
+	}
 }
 
-func NewCollectionService(repo ports.LinkRepository) *CollectionService {
-	return &CollectionService{repo: repo}
+// CreateCollection stores a new collection from its slug, title, description, avatar, appearance,
+// visibility and template flag. Collections are public unless another visibility is given.
+func (s *CollectionService) CreateCollection(ctx context.Context, input *domain.Collection) (*domain.Collection, error) {
+	collection, err := s.createCollection(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, domain.EventCollectionCreated, collection)
+	return collection, nil
 }
 
-func (s *CollectionService) CreateCollection(ctx context.Context, title, slug, description string) (*domain.Collection, error) {
-	if slug == "" {
+// createCollection validates and stores a new collection without publishing its event
+func (s *CollectionService) createCollection(ctx context.Context, input *domain.Collection) (*domain.Collection, error) {
+	if input.Slug == "" {
 		return nil, errors.New("slug is required")
 	}
+	if err := validateAvatarURL(input.AvatarURL); err != nil {
+		return nil, err
+	}
+	appearance, err := normalizeAppearance(input.Appearance)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if slug exists
-	existing, _ := s.repo.GetCollectionBySlug(ctx, slug)
+	existing, _ := s.repo.GetCollectionBySlug(ctx, input.Slug)
 	if existing != nil {
 		return nil, errors.New("slug already exists")
 	}
 
 	collection := &domain.Collection{
-		Title:       title,
-		Slug:        slug,
-		Description: description,
+		Title:       input.Title,
+		Slug:        input.Slug,
+		Description: input.Description,
+		AvatarURL:   input.AvatarURL,
+		Appearance:  appearance,
+		IsTemplate:  input.IsTemplate,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if err := applyVisibility(collection, input); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.CreateCollection(ctx, collection); err != nil {
 		return nil, err
 	}
-
 	return collection, nil
 }
 
@@ -49,11 +111,9 @@ func (s *CollectionService) GetCollection(ctx context.Context, id int64) (*domai
 		return nil, err
 	}
 	if collection != nil {
-		links, err := s.repo.GetCollectionLinks(ctx, id)
-		if err != nil {
+		if err := s.loadItems(ctx, collection); err != nil {
 			return nil, err
 		}
-		collection.Links = links
 	}
 	return collection, nil
 }
@@ -64,16 +124,27 @@ func (s *CollectionService) GetCollectionBySlug(ctx context.Context, slug string
 		return nil, err
 	}
 	if collection != nil {
-		links, err := s.repo.GetCollectionLinks(ctx, collection.ID)
-		if err != nil {
+		if err := s.loadItems(ctx, collection); err != nil {
 			return nil, err
 		}
-		collection.Links = links
 	}
 	return collection, nil
 }
 
-func (s *CollectionService) UpdateCollection(ctx context.Context, id int64, title, slug, description string) (*domain.Collection, error) {
+// UpdateCollection replaces the collection's slug, title, description, avatar, appearance,
+// visibility and template flag. An empty password keeps the current one.
+func (s *CollectionService) UpdateCollection(ctx context.Context, id int64, input *domain.Collection) (*domain.Collection, error) {
+	if input.Slug == "" {
+		return nil, errors.New("slug is required")
+	}
+	if err := validateAvatarURL(input.AvatarURL); err != nil {
+		return nil, err
+	}
+	appearance, err := normalizeAppearance(input.Appearance)
+	if err != nil {
+		return nil, err
+	}
+
 	collection, err := s.repo.GetCollection(ctx, id)
 	if err != nil {
 		return nil, err
@@ -83,35 +154,65 @@ func (s *CollectionService) UpdateCollection(ctx context.Context, id int64, titl
 	}
 
 	// Check slug uniqueness if changed
-	if slug != collection.Slug {
-		existing, _ := s.repo.GetCollectionBySlug(ctx, slug)
+	if input.Slug != collection.Slug {
+		existing, _ := s.repo.GetCollectionBySlug(ctx, input.Slug)
 		if existing != nil {
 			return nil, errors.New("slug already exists")
 		}
 	}
 
-	collection.Title = title
-	collection.Slug = slug
-	collection.Description = description
+	if err := applyVisibility(collection, input); err != nil {
+		return nil, err
+	}
+	collection.Title = input.Title
+	collection.Slug = input.Slug
+	collection.Description = input.Description
+	collection.AvatarURL = input.AvatarURL
+	collection.Appearance = appearance
+	collection.IsTemplate = input.IsTemplate
 	collection.UpdatedAt = time.Now()
 
 	if err := s.repo.UpdateCollection(ctx, collection); err != nil {
 		return nil, err
 	}
+	s.publish(ctx, domain.EventCollectionUpdated, collection)
 
 	return collection, nil
 }
 
 func (s *CollectionService) DeleteCollection(ctx context.Context, id int64) error {
-	return s.repo.DeleteCollection(ctx, id)
+	if s.events == nil {
+		return s.repo.DeleteCollection(ctx, id)
+	}
+
+	// Fetched first so the event carries the deleted collection
+	collection, err := s.repo.GetCollection(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteCollection(ctx, id); err != nil {
+		return err
+	}
+	if collection != nil {
+		s.publish(ctx, domain.EventCollectionDeleted, collection)
+	}
+	return nil
 }
 
-func (s *CollectionService) ListCollections(ctx context.Context, page, limit int, search string) ([]domain.Collection, int64, error) {
+// ListCollections lists collections, newest first. A non-nil template lists only templates
+// or only regular collections.
+func (s *CollectionService) ListCollections(ctx context.Context, page, limit int, search, visibility string, template *bool) ([]domain.Collection, int64, error) {
 	offset := (page - 1) * limit
 	filters := map[string]interface{}{}
 	if search != "" {
 		filters["search"] = search
 	}
+	if visibility != "" {
+		filters["visibility"] = visibility
+	}
+	if template != nil {
+		filters["is_template"] = *template
+	}
 
 	collections, err := s.repo.ListCollections(ctx, limit, offset, filters)
 	if err != nil {
@@ -123,20 +224,72 @@ func (s *CollectionService) ListCollections(ctx context.Context, page, limit int
 	return collections, 0, nil
 }
 
-func (s *CollectionService) AddLink(ctx context.Context, collectionID, linkID int64) error {
-	return s.repo.AddLinkToCollection(ctx, collectionID, linkID)
-}
-
 func (s *CollectionService) RemoveLink(ctx context.Context, collectionID, linkID int64) error {
-	return s.repo.RemoveLinkFromCollection(ctx, collectionID, linkID)
+	if err := s.repo.RemoveLinkFromCollection(ctx, collectionID, linkID); err != nil {
+		return err
+	}
+	return s.touch(ctx, collectionID)
 }
 
+// ReorderLinks sets the order of the collection's links; linkIDs must list each of them once
 func (s *CollectionService) ReorderLinks(ctx context.Context, collectionID int64, linkIDs []int64) error {
-	for i, linkID := range linkIDs {
-		// New order is index + 1
-		if err := s.repo.UpdateLinkOrder(ctx, collectionID, linkID, i+1); err != nil {
-			return err
-		}
+	if err := s.repo.ReorderCollectionLinks(ctx, collectionID, linkIDs); err != nil {
+		return err
+	}
+	return s.touch(ctx, collectionID)
+}
+
+// validateAvatarURL accepts an empty avatar or an absolute http(s) URL
+func validateAvatarURL(raw string) error {
+	if !isHTTPURL(raw) {
+		return errors.New("avatar_url must be an http(s) URL")
 	}
 	return nil
 }
+
+// isHTTPURL reports whether raw is empty or an absolute http(s) URL
+func isHTTPURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// normalizeAppearance validates the appearance settings, lowercasing colors and
+// sanitizing the custom CSS
+func normalizeAppearance(a domain.CollectionAppearance) (domain.CollectionAppearance, error) {
+	if a.Theme != "" && !slices.Contains(domain.CollectionThemes, a.Theme) {
+		return a, fmt.Errorf("theme must be one of %s", strings.Join(domain.CollectionThemes, ", "))
+	}
+	if a.Font != "" && !slices.Contains(domain.CollectionFonts, a.Font) {
+		return a, fmt.Errorf("font must be one of %s", strings.Join(domain.CollectionFonts, ", "))
+	}
+	if a.ButtonStyle != "" && !slices.Contains(domain.ButtonStyles, a.ButtonStyle) {
+		return a, fmt.Errorf("button_style must be one of %s", strings.Join(domain.ButtonStyles, ", "))
+	}
+
+	colors := map[string]*string{
+		"background_color":  &a.BackgroundColor,
+		"text_color":        &a.TextColor,
+		"button_color":      &a.ButtonColor,
+		"button_text_color": &a.ButtonTextColor,
+	}
+	for name, color := range colors {
+		*color = strings.ToLower(strings.TrimSpace(*color))
+		if *color != "" && !hexColor.MatchString(*color) {
+			return a, fmt.Errorf("%s must be a hex color like #1c1917", name)
+		}
+	}
+
+	if !isHTTPURL(a.BackgroundImageURL) {
+		return a, errors.New("background_image_url must be an http(s) URL")
+	}
+	if len(a.CustomCSS) > domain.MaxCustomCSSLen {
+		return a, fmt.Errorf("custom_css must be at most %d bytes", domain.MaxCustomCSSLen)
+	}
+	a.CustomCSS = domain.SanitizeCSS(a.CustomCSS)
+	return a, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestNormalizeAppearance(t *testing.T) {
+	got, err := normalizeAppearance(domain.CollectionAppearance{
+		Theme:       domain.ThemeOcean,
+		ButtonColor: " #FF0066 ",
+		TextColor:   "#FFF",
+		CustomCSS:   "@import url(https://evil.test/x.css); h1 { color: red }",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ButtonColor != "#ff0066" || got.TextColor != "#fff" {
+		t.Errorf("colors = %q, %q", got.ButtonColor, got.TextColor)
+	}
+	if got.CustomCSS != "h1 { color: red }" {
+		t.Errorf("custom CSS = %q", got.CustomCSS)
+	}
+
+	for _, bad := range []domain.CollectionAppearance{
+		{Theme: "neon"},
+		{Font: "comic-sans"},
+		{ButtonStyle: "3d"},
+		{BackgroundColor: "red"},
+		{ButtonTextColor: "#12345"},
+		{BackgroundImageURL: "javascript:alert(1)"},
+	} {
+		if _, err := normalizeAppearance(bad); err == nil {
+			t.Errorf("normalizeAppearance(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestNormalizeItem(t *testing.T) {
+	item, err := normalizeItem(&domain.CollectionItem{
+		Type:   domain.ItemHeading,
+		Text:   "  Latest  ",
+		URL:    "https://ignored.example.com/",
+		LinkID: 7,
+		Style:  domain.ItemStyle{Align: "left", TextColor: "#ABC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Text != "Latest" || item.URL != "" || item.LinkID != 0 || item.Style.TextColor != "#abc" {
+		t.Errorf("normalizeItem kept %+v", item)
+	}
+
+	for _, good := range []domain.CollectionItem{
+		{Type: domain.ItemLink, LinkID: 7},
+		{Type: domain.ItemLink, LinkID: 7, Label: "Shop", Icon: "🛍️", ThumbnailURL: "https://cdn.example.com/t.png", Highlight: true},
+		{Type: domain.ItemImage, URL: "https://cdn.example.com/a.png"},
+		{Type: domain.ItemEmbed, URL: "https://vimeo.com/76979871"},
+		{Type: domain.ItemSocial, Socials: []domain.SocialProfile{{Platform: "GitHub", URL: "https://github.com/jane"}, {Platform: "email", URL: "mailto:jane@example.com"}}},
+	} {
+		if _, err := normalizeItem(&good); err != nil {
+			t.Errorf("normalizeItem(%+v): %v", good, err)
+		}
+	}
+	for _, bad := range []domain.CollectionItem{
+		{Type: "button"},
+		{Type: domain.ItemLink},
+		{Type: domain.ItemLink, LinkID: 7, Icon: "a very long icon"},
+		{Type: domain.ItemLink, LinkID: 7, ThumbnailURL: "data:image/png;base64,AAAA"},
+		{Type: domain.ItemText, Text: " "},
+		{Type: domain.ItemImage, URL: "javascript:alert(1)"},
+		{Type: domain.ItemEmbed, URL: "https://example.com/video"},
+		{Type: domain.ItemSocial},
+		{Type: domain.ItemSocial, Socials: []domain.SocialProfile{{Platform: "myspace", URL: "https://myspace.com/jane"}}},
+		{Type: domain.ItemSocial, Socials: []domain.SocialProfile{{Platform: "email", URL: "https://example.com"}}},
+		{Type: domain.ItemText, Text: "hi", Style: domain.ItemStyle{Align: "justify"}},
+	} {
+		if _, err := normalizeItem(&bad); err == nil {
+			t.Errorf("normalizeItem(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestApplyVisibility(t *testing.T) {
+	publish := time.Date(2026, 5, 1, 9, 30, 15, 500, time.FixedZone("ICT", 7*3600))
+	unpublish := publish.Add(24 * time.Hour)
+
+	collection := &domain.Collection{}
+	if err := applyVisibility(collection, &domain.Collection{PublishAt: &publish, UnpublishAt: &unpublish}); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Visibility != domain.VisibilityPublic {
+		t.Errorf("visibility defaulted to %q", collection.Visibility)
+	}
+	if collection.PublishAt.Location() != time.UTC || collection.PublishAt.Nanosecond() != 0 || !collection.PublishAt.Equal(publish.Truncate(time.Second)) {
+		t.Errorf("publish_at stored as %v", collection.PublishAt)
+	}
+
+	if err := applyVisibility(collection, &domain.Collection{Visibility: domain.VisibilityPassword}); err == nil {
+		t.Error("password visibility accepted without a password")
+	}
+	if err := applyVisibility(collection, &domain.Collection{Visibility: domain.VisibilityPassword, Password: "open sesame"}); err != nil {
+		t.Fatal(err)
+	}
+	hash := collection.PasswordHash
+	if !checkPassword(hash, "open sesame") || checkPassword(hash, "open sesam") {
+		t.Error("password hash doesn't verify")
+	}
+	if err := applyVisibility(collection, &domain.Collection{Visibility: domain.VisibilityPassword}); err != nil || collection.PasswordHash != hash {
+		t.Errorf("password not kept on update: %v", err)
+	}
+	if err := applyVisibility(collection, &domain.Collection{Visibility: domain.VisibilityUnlisted}); err != nil || collection.PasswordHash != "" {
+		t.Errorf("password kept after leaving password visibility: %v", err)
+	}
+
+	for _, bad := range []domain.Collection{
+		{Visibility: "private"},
+		{Visibility: domain.VisibilityPassword, Password: "abc"},
+		{Visibility: domain.VisibilityPassword, Password: "1234567"}, // One byte short
+		{PublishAt: &unpublish, UnpublishAt: &publish},
+		{PublishAt: &publish, UnpublishAt: &publish},
+	} {
+		if err := applyVisibility(&domain.Collection{}, &bad); err == nil {
+			t.Errorf("applyVisibility(%+v) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// RecordView stores a view of the collection's public page with the same referrer,
+// user agent and visitor data as link visits
+func (s *CollectionService) RecordView(ctx context.Context, collection *domain.Collection, input domain.VisitInput) error {
+	refererDomain, refererChannel := domain.NormalizeReferrer(input.Referer)
+	view := &domain.CollectionView{
+		CollectionID:   collection.ID,
+		Referer:        input.Referer,
+		RefererDomain:  refererDomain,
+		RefererChannel: refererChannel,
+		UserAgent:      input.UserAgent,
+		IPHash:         input.IP, // Same visitor key as link visits
+		CreatedAt:      time.Now(),
+	}
+	return s.repo.RecordCollectionView(ctx, view)
+}
+
+// GetStats returns the views of a collection page within [from, to), the clicks on its
+// links that came from the page and the resulting click-through rate
+func (s *CollectionService) GetStats(ctx context.Context, id int64, from, to time.Time) (*domain.CollectionStats, error) {
+	collection, err := s.GetCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return nil, errors.New("collection not found")
+	}
+
+	stats, err := s.repo.GetCollectionStats(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[int64]*domain.CollectionItem, len(collection.Items))
+	for i := range collection.Items {
+		items[collection.Items[i].ID] = &collection.Items[i]
+	}
+	for i := range stats.Items {
+		if item := items[stats.Items[i].ItemID]; item != nil {
+			stats.Items[i].Title = item.ButtonText()
+		}
+	}
+	if stats.Views > 0 {
+		stats.ClickThroughRate = float64(stats.Clicks) / float64(stats.Views)
+	}
+	return stats, nil
+}
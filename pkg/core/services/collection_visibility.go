@@ -0,0 +1,142 @@
+package services
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+const (
+	minCollectionPasswordLen = 8
+	maxCollectionPasswordLen = 200
+
+	// passwordIterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	passwordIterations = 600000
+	passwordScheme     = "pbkdf2-sha256"
+)
+
+// applyVisibility validates the visibility, password and publishing window of input and
+// sets them on collection. A new password is hashed; without one a password-protected
+// collection keeps its current password.
+func applyVisibility(collection, input *domain.Collection) error {
+	visibility := input.Visibility
+	if visibility == "" {
+		visibility = domain.VisibilityPublic
+	}
+	if !slices.Contains(domain.CollectionVisibilities, visibility) {
+		return fmt.Errorf("visibility must be one of %s", strings.Join(domain.CollectionVisibilities, ", "))
+	}
+
+	publishAt, unpublishAt := storedTime(input.PublishAt), storedTime(input.UnpublishAt)
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return errors.New("unpublish_at must be after publish_at")
+	}
+
+	passwordHash := collection.PasswordHash
+	switch {
+	case visibility != domain.VisibilityPassword:
+		passwordHash = ""
+	case input.Password != "":
+		if len(input.Password) < minCollectionPasswordLen || len(input.Password) > maxCollectionPasswordLen {
+			return fmt.Errorf("password must be %d to %d bytes", minCollectionPasswordLen, maxCollectionPasswordLen)
+		}
+		hash, err := hashPassword(input.Password)
+		if err != nil {
+			return err
+		}
+		passwordHash = hash
+	case passwordHash == "":
+		return errors.New("password is required for password-protected collections")
+	}
+
+	collection.Visibility = visibility
+	collection.PasswordHash = passwordHash
+	collection.PublishAt, collection.UnpublishAt = publishAt, unpublishAt
+	return nil
+}
+
+// storedTime returns t in UTC at the precision it is stored with
+func storedTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC().Truncate(time.Second)
+	return &utc
+}
+
+// VerifyPassword reports whether password unlocks a password-protected collection.
+// Repeated wrong passwords from clientIP block its further attempts with a growing
+// backoff; while blocked the password isn't checked and the remaining wait is returned.
+// Wrong passwords for the collection from anyone are only counted and logged, so
+// guessing can't lock other visitors out.
+func (s *CollectionService) VerifyPassword(collection *domain.Collection, password, clientIP string) (bool, time.Duration) {
+	now := time.Now()
+	clientKey := "client:" + clientIP
+	if wait := s.unlocks.wait(clientKey, now); wait > 0 {
+		return false, wait
+	}
+
+	if collection.PasswordHash == "" || !checkPassword(collection.PasswordHash, password) {
+		s.unlocks.fail(clientKey, unlockFreePerClient, now)
+		if n := s.unlocks.count("collection:"+strconv.FormatInt(collection.ID, 10), now); n%unlockAlertPerCollection == 0 {
+			log.Printf("Collection %d (%s): %d wrong passwords from any client, possibly a guessing attack", collection.ID, collection.Slug, n)
+		}
+		return false, 0
+	}
+	s.unlocks.reset(clientKey)
+	return true, 0
+}
+
+// hashPassword derives a salted PBKDF2 hash, encoded as scheme$iterations$salt$key
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// checkPassword compares password against a hash from hashPassword in constant time
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+const (
+	maxConversionEventLen = 64
+	maxConversionOrderLen = 128
+)
+
+// newClickID returns "<link ID in base 36>_<96 random bits in hex>_<signature>". Carrying
+// the link ID lets a conversion be attributed even before a queued visit is written; the
+// signature keeps anyone from making up click IDs for arbitrary links.
+func newClickID(key []byte, linkID int64) (string, error) {
+	random, err := randomHex(12)
+	if err != nil {
+		return "", err
+	}
+	unsigned := strconv.FormatInt(linkID, 36) + "_" + random
+	return unsigned + "_" + signClickID(key, unsigned), nil
+}
+
+// parseClickID returns the link ID of a click ID issued by newClickID with the same key
+func parseClickID(key []byte, clickID string) (int64, bool) {
+	unsigned, signature, ok := cutLast(clickID, "_")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signClickID(key, unsigned))) {
+		return 0, false
+	}
+	prefix, random, ok := strings.Cut(unsigned, "_")
+	if !ok || len(random) != 24 || !isHex(random) {
+		return 0, false
+	}
+	linkID, err := strconv.ParseInt(prefix, 36, 64)
+	if err != nil || linkID <= 0 {
+		return 0, false
+	}
+	return linkID, true
+}
+
+// signClickID is the first 96 bits of HMAC-SHA256 over the link ID and random part, in hex
+func signClickID(key []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("click_id."))
+	mac.Write([]byte(unsigned))
+	return hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// DestinationURL is the link's original URL with the click ID appended as a query
+// parameter. The existing query string is kept as is.
+func (s *LinkService) DestinationURL(link *domain.Link, clickID string) string {
+	if clickID == "" || s.clickIDParam == "" {
+		return link.OriginalURL
+	}
+	u, err := url.Parse(link.OriginalURL)
+	if err != nil {
+		return link.OriginalURL
+	}
+	param := url.QueryEscape(s.clickIDParam) + "=" + url.QueryEscape(clickID)
+	if u.RawQuery == "" {
+		u.RawQuery = param
+	} else {
+		u.RawQuery += "&" + param
+	}
+	return u.String()
+}
+
+// RecordConversion attributes a conversion to the link (and visit) of its click ID. A
+// repeated report of the same click, event and order is not stored again; it returns the
+// conversion with created false.
+func (s *LinkService) RecordConversion(ctx context.Context, input domain.ConversionInput) (*domain.Conversion, bool, error) {
+	linkID, ok := parseClickID(s.clickIDKey, strings.TrimSpace(input.ClickID))
+	if !ok {
+		return nil, false, errors.New("invalid click_id")
+	}
+	link, err := s.repo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, false, err
+	}
+	if link == nil {
+		return nil, false, errors.New("unknown click_id")
+	}
+
+	conversion := &domain.Conversion{
+		ClickID:   strings.TrimSpace(input.ClickID),
+		LinkID:    linkID,
+		Event:     strings.TrimSpace(input.Event),
+		Revenue:   input.Revenue,
+		Currency:  strings.ToUpper(strings.TrimSpace(input.Currency)),
+		OrderID:   strings.TrimSpace(input.OrderID),
+		CreatedAt: time.Now(),
+	}
+	if conversion.Event == "" {
+		conversion.Event = domain.DefaultConversionEvent
+	}
+	if len(conversion.Event) > maxConversionEventLen {
+		return nil, false, errors.New("event is too long")
+	}
+	if len(conversion.OrderID) > maxConversionOrderLen {
+		return nil, false, errors.New("order_id is too long")
+	}
+	if conversion.Revenue < 0 || math.IsNaN(conversion.Revenue) || math.IsInf(conversion.Revenue, 0) {
+		return nil, false, errors.New("invalid revenue")
+	}
+	if conversion.Currency != "" && (len(conversion.Currency) != 3 || strings.Trim(conversion.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		return nil, false, errors.New("currency must be a 3-letter ISO 4217 code")
+	}
+
+	created, err := s.repo.RecordConversion(ctx, conversion)
+	if err != nil {
+		return nil, false, err
+	}
+	return conversion, created, nil
+}
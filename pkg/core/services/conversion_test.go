@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestClickIDRoundTrip(t *testing.T) {
+	key := []byte("server secret")
+	clickID, err := newClickID(key, 123456)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkID, ok := parseClickID(key, clickID)
+	if !ok || linkID != 123456 {
+		t.Errorf("parseClickID(%q) = %d, %v", clickID, linkID, ok)
+	}
+
+	for _, bad := range []string{"", "abc", "2n9c_xyz", "2n9c_3f9c1e0a6b2d48e5a1c07f3", "_3f9c1e0a6b2d48e5a1c07f3e", "-1_3f9c1e0a6b2d48e5a1c07f3e"} {
+		if _, ok := parseClickID(key, bad+"_"+signClickID(key, bad)); ok {
+			t.Errorf("parseClickID(%q) accepted", bad)
+		}
+	}
+}
+
+func TestForgedClickIDsAreRejected(t *testing.T) {
+	key := []byte("server secret")
+	issued, err := newClickID(key, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, signature, _ := cutLast(issued, "_")
+	_, random, _ := strings.Cut(unsigned, "_")
+	otherKey, err := newClickID([]byte("another secret"), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := map[string]string{
+		"unsigned":                   unsigned,
+		"signed with another key":    otherKey,
+		"other link, same signature": "1b_" + random + "_" + signature,
+		"altered signature":          unsigned + "_" + strings.Repeat("0", len(signature)),
+	}
+	for name, clickID := range forged {
+		if _, ok := parseClickID(key, clickID); ok {
+			t.Errorf("%s: parseClickID(%q) accepted", name, clickID)
+		}
+	}
+
+	// Rejected before the link is looked up
+	svc := NewLinkService(nil, WithClickIDs("clid", string(key)))
+	if _, _, err := svc.RecordConversion(context.Background(), domain.ConversionInput{ClickID: "1b_" + random + "_" + signature}); err == nil {
+		t.Error("RecordConversion accepted a forged click ID")
+	}
+}
+
+func TestDestinationURL(t *testing.T) {
+	s := NewLinkService(nil, WithClickIDs("clid", "server secret"))
+	tests := []struct {
+		original, clickID, want string
+	}{
+		{"https://example.com/pricing", "7_abc", "https://example.com/pricing?clid=7_abc"},
+		{"https://example.com/?b=2&a=1#plans", "7_abc", "https://example.com/?b=2&a=1&clid=7_abc#plans"},
+		{"https://example.com/", "", "https://example.com/"},
+	}
+	for _, tt := range tests {
+		if got := s.DestinationURL(&domain.Link{OriginalURL: tt.original}, tt.clickID); got != tt.want {
+			t.Errorf("DestinationURL(%q) = %q, want %q", tt.original, got, tt.want)
+		}
+	}
+
+	// Click IDs off: destinations are untouched
+	off := NewLinkService(nil)
+	if got := off.DestinationURL(&domain.Link{OriginalURL: "https://example.com/"}, "7_abc"); got != "https://example.com/" {
+		t.Errorf("DestinationURL without click IDs = %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dedupWindow remembers recent visitors per link so a click reported both by the edge
+// middleware (Track) and by Redirect is counted once
+type dedupWindow struct {
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time // key -> first visit in the current window
+	lastSweep time.Time
+}
+
+func newDedupWindow(window time.Duration) *dedupWindow {
+	return &dedupWindow{window: window, seen: make(map[string]time.Time)}
+}
+
+func dedupKey(linkID int64, ipHash, userAgent string) string {
+	return strconv.FormatInt(linkID, 10) + "|" + ipHash + "|" + userAgent
+}
+
+// seenRecently reports whether key was seen within the window before now, and otherwise
+// starts a new window for it. Repeats don't extend the window.
+func (d *dedupWindow) seenRecently(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Sweep expired entries at most once per window to bound memory
+	if now.Sub(d.lastSweep) >= d.window {
+		for k, first := range d.seen {
+			if now.Sub(first) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if first, ok := d.seen[key]; ok && now.Sub(first) < d.window {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
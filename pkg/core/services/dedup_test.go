@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	d := newDedupWindow(10 * time.Second)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := dedupKey(1, "203.0.113.7", "Mozilla/5.0")
+
+	tests := []struct {
+		name   string
+		key    string
+		offset time.Duration
+		want   bool
+	}{
+		{"First visit", key, 0, false},
+		{"Repeat inside window", key, 5 * time.Second, true},
+		{"Other user agent", dedupKey(1, "203.0.113.7", "curl/8.0"), 6 * time.Second, false},
+		{"Other link", dedupKey(2, "203.0.113.7", "Mozilla/5.0"), 7 * time.Second, false},
+		{"Repeat does not extend window", key, 10 * time.Second, false},
+		{"Repeat of new window", key, 19 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.seenRecently(tt.key, start.Add(tt.offset)); got != tt.want {
+				t.Errorf("seenRecently = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
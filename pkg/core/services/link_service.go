@@ -4,19 +4,109 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
 )
 
 type LinkService struct {
-	repo ports.LinkRepository
+	repo          ports.LinkRepository
+	geo           ports.GeoResolver
+	trackedParams map[string]bool
+	retentionDays int
+	archive       ports.VisitArchive
+	visits        *VisitWriter
+	dedup         *dedupWindow
+	broker        *VisitBroker
+	events        ports.EventPublisher
+	clickIDParam  string
+	clickIDKey    []byte // HMAC key signing click IDs
 }
 
-func NewLinkService(repo ports.LinkRepository) *LinkService {
-	return &LinkService{repo: repo}
+// LinkServiceOption configures optional LinkService dependencies
+type LinkServiceOption func(*LinkService)
+
+// WithGeoResolver enables visitor location lookup when recording visits
+func WithGeoResolver(geo ports.GeoResolver) LinkServiceOption {
+	return func(s *LinkService) {
+		s.geo = geo
+	}
+}
+
+// WithTrackedParams whitelists non-UTM query parameters (e.g. "ref", "gclid") to keep on visits
+func WithTrackedParams(keys []string) LinkServiceOption {
+	return func(s *LinkService) {
+		s.trackedParams = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if key = strings.TrimSpace(key); key != "" {
+				s.trackedParams[key] = true
+			}
+		}
+	}
+}
+
+// WithRetention prunes raw visits older than days (0 keeps them forever), archiving
+// them first when archive is non-nil
+func WithRetention(days int, archive ports.VisitArchive) LinkServiceOption {
+	return func(s *LinkService) {
+		s.retentionDays = days
+		s.archive = archive
+	}
+}
+
+// WithVisitWriter queues visits for batched writes instead of writing each one synchronously
+func WithVisitWriter(w *VisitWriter) LinkServiceOption {
+	return func(s *LinkService) {
+		s.visits = w
+	}
+}
+
+// WithDedupWindow counts repeat visits to a link from the same visitor and user agent
+// within window as duplicates instead of clicks
+func WithDedupWindow(window time.Duration) LinkServiceOption {
+	return func(s *LinkService) {
+		if window > 0 {
+			s.dedup = newDedupWindow(window)
+		}
+	}
+}
+
+// WithVisitBroker publishes recorded visits to live stream subscribers
+func WithVisitBroker(b *VisitBroker) LinkServiceOption {
+	return func(s *LinkService) {
+		s.broker = b
+	}
+}
+
+// WithEventPublisher emits link.created/updated/deleted/clicked events (e.g. to webhooks)
+func WithEventPublisher(p ports.EventPublisher) LinkServiceOption {
+	return func(s *LinkService) {
+		s.events = p
+	}
+}
+
+// WithClickIDs issues a click ID on every recorded visit and appends it to the destination
+// URL as the given query parameter, so conversions can be attributed back to the visit.
+// Click IDs are signed with secret; changing it invalidates the ones already issued.
+func WithClickIDs(param, secret string) LinkServiceOption {
+	return func(s *LinkService) {
+		s.clickIDParam = strings.TrimSpace(param)
+		s.clickIDKey = []byte(secret)
+	}
+}
+
+func NewLinkService(repo ports.LinkRepository, opts ...LinkServiceOption) *LinkService {
+	s := &LinkService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *LinkService) Shorten(ctx context.Context, originalURL, title string, tags []string, customCode string) (*domain.Link, error) {
@@ -51,6 +141,7 @@ func (s *LinkService) Shorten(ctx context.Context, originalURL, title string, ta
 	if err := s.repo.Create(ctx, link); err != nil {
 		return nil, err
 	}
+	s.publish(ctx, domain.EventLinkCreated, link)
 
 	return link, nil
 }
@@ -92,12 +183,35 @@ func (s *LinkService) UpdateLink(ctx context.Context, id int64, originalURL, tit
 	if err := s.repo.Update(ctx, link); err != nil {
 		return nil, err
 	}
+	s.publish(ctx, domain.EventLinkUpdated, link)
 
 	return link, nil
 }
 
 func (s *LinkService) DeleteLink(ctx context.Context, id int64) error {
-	return s.repo.Delete(ctx, id)
+	if s.events == nil {
+		return s.repo.Delete(ctx, id)
+	}
+
+	// Fetched first so the event carries the deleted link
+	link, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if link != nil {
+		s.publish(ctx, domain.EventLinkDeleted, link)
+	}
+	return nil
+}
+
+// publish emits an event if a publisher is configured
+func (s *LinkService) publish(ctx context.Context, eventType string, data interface{}) {
+	if s.events != nil {
+		s.events.Publish(ctx, eventType, data)
+	}
 }
 
 func (s *LinkService) ListLinks(ctx context.Context, page, limit int, search string, tag string) ([]domain.Link, int64, error) {
@@ -127,36 +241,345 @@ func (s *LinkService) ListLinks(ctx context.Context, page, limit int, search str
 	return links, count, nil
 }
 
-func (s *LinkService) RecordVisit(ctx context.Context, shortCode, referer, userAgent, ip string) error {
+func (s *LinkService) RecordVisit(ctx context.Context, shortCode string, input domain.VisitInput) (string, error) {
 	link, err := s.repo.GetByShortCode(ctx, shortCode)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if link == nil {
-		return errors.New("link not found")
+		return "", errors.New("link not found")
 	}
+	return s.RecordLinkVisit(ctx, link, input)
+}
 
+// RecordLinkVisit records a visit to an already resolved link, saving the extra lookup on redirects
+func (s *LinkService) RecordLinkVisit(ctx context.Context, link *domain.Link, input domain.VisitInput) (string, error) {
 	// Simple privacy hash (in real app use salt)
 	// For now just storing raw string or doing a dummy hash since verify isn't key
-	ipHash := ip // In production: sha256.Sum256(ip + salt)
+	ipHash := input.IP // In production: sha256.Sum256(ip + salt)
+
+	refererDomain, refererChannel := domain.NormalizeReferrer(input.Referer)
 
 	visit := &domain.Visit{
-		LinkID:    link.ID,
-		Referer:   referer,
-		UserAgent: userAgent,
-		IPHash:    ipHash,
-		CreatedAt: time.Now(),
+		LinkID:         link.ID,
+		Referer:        input.Referer,
+		RefererDomain:  refererDomain,
+		RefererChannel: refererChannel,
+		UserAgent:      input.UserAgent,
+		IPHash:         ipHash,
+		CreatedAt:      time.Now(),
+	}
+	s.captureParams(visit, input.Query)
+	if itemID, err := strconv.ParseInt(input.Query[domain.CollectionItemParam], 10, 64); err == nil && itemID > 0 {
+		visit.CollectionItemID = itemID
 	}
 
-	// Async recording could be better for performance, but sync is safer for now.
-	// To make it non-blocking for the redirect request, we could run in goroutine,
-	// but context cancellation might kill it.
-	// For MVP: Sync.
+	if s.clickIDParam != "" {
+		clickID, err := newClickID(s.clickIDKey, link.ID)
+		if err != nil {
+			return "", err
+		}
+		visit.ClickID = clickID
+	}
+
+	if s.dedup != nil {
+		duplicate, err := s.isDuplicate(ctx, visit)
+		if err != nil {
+			return "", err
+		}
+		if duplicate {
+			// Not stored, but the click ID still attributes conversions to the link
+			visit.Duplicate = true
+			return visit.ClickID, s.writeVisit(ctx, visit)
+		}
+	}
+
+	// Resolve location before the IP is discarded. A failed lookup should never drop the visit.
+	if s.geo != nil {
+		if loc, err := s.geo.Lookup(input.IP); err == nil && loc != nil {
+			visit.Country = loc.Country
+			visit.Region = loc.Region
+			visit.City = loc.City
+		}
+	}
+
+	if err := s.writeVisit(ctx, visit); err != nil {
+		return "", err
+	}
+
+	if s.broker != nil || s.events != nil {
+		event := domain.VisitEvent{
+			LinkID:         link.ID,
+			ShortCode:      link.ShortCode,
+			Tags:           link.Tags,
+			RefererDomain:  visit.RefererDomain,
+			RefererChannel: visit.RefererChannel,
+			Country:        visit.Country,
+			City:           visit.City,
+			UTMSource:      visit.UTMSource,
+			UTMCampaign:    visit.UTMCampaign,
+			CreatedAt:      visit.CreatedAt,
+		}
+		s.publish(ctx, domain.EventLinkClicked, event)
+		if s.broker != nil {
+			s.broker.Publish(event)
+		}
+	}
+	return visit.ClickID, nil
+}
+
+// SubscribeVisits streams recorded visits, optionally of one link (linkID != 0) or of links
+// with a tag, replaying buffered events after lastEventID first
+func (s *LinkService) SubscribeVisits(ctx context.Context, lastEventID, linkID int64, tag string) ([]domain.VisitEvent, <-chan domain.VisitEvent, error) {
+	if s.broker == nil {
+		return nil, nil, ErrStreamUnavailable
+	}
+	return s.broker.Subscribe(ctx, lastEventID, visitEventFilter(linkID, tag))
+}
+
+// writeVisit batches through the queue when configured; sync otherwise (e.g. serverless,
+// where nothing runs after the response is sent)
+func (s *LinkService) writeVisit(ctx context.Context, visit *domain.Visit) error {
+	if s.visits != nil {
+		return s.visits.Enqueue(visit)
+	}
 	return s.repo.RecordVisit(ctx, visit)
 }
 
+// isDuplicate checks the in-process window first. In sync mode stored visits are checked
+// too, since serverless instances don't share memory; queued visits aren't stored yet,
+// so with a visit writer the in-process window is authoritative.
+func (s *LinkService) isDuplicate(ctx context.Context, visit *domain.Visit) (bool, error) {
+	if s.dedup.seenRecently(dedupKey(visit.LinkID, visit.IPHash, visit.UserAgent), visit.CreatedAt) {
+		return true, nil
+	}
+	if s.visits != nil {
+		return false, nil
+	}
+	return s.repo.HasRecentVisit(ctx, visit.LinkID, visit.IPHash, visit.UserAgent, visit.CreatedAt.Add(-s.dedup.window))
+}
+
+// VisitQueueStats reports the visit writer's counters, or nil in synchronous mode
+func (s *LinkService) VisitQueueStats() *domain.VisitQueueStats {
+	if s.visits == nil {
+		return nil
+	}
+	return s.visits.Stats()
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// captureParams copies UTM parameters and whitelisted query parameters onto the visit
+func (s *LinkService) captureParams(visit *domain.Visit, query map[string]string) {
+	for key, value := range query {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		value = truncateUTF8(value, maxParamLength)
+
+		switch key {
+		case "utm_source":
+			visit.UTMSource = value
+		case "utm_medium":
+			visit.UTMMedium = value
+		case "utm_campaign":
+			visit.UTMCampaign = value
+		case "utm_term":
+			visit.UTMTerm = value
+		case "utm_content":
+			visit.UTMContent = value
+		default:
+			if s.trackedParams[key] {
+				if visit.Params == nil {
+					visit.Params = make(map[string]string)
+				}
+				visit.Params[key] = value
+			}
+		}
+	}
+}
+
+// GetLinkStats returns breakdowns for the filtered range (all time if unset) and a
+// zero-filled timeline. Besides the repository filters it understands:
+//   - "granularity": domain.Granularity* (default day)
+//   - "location": *time.Location for bucket boundaries (default UTC)
+//   - "compare": bool, include the preceding period of equal length
+//
+// Without start_date/end_date the timeline covers the last 30 days. Reversed ranges and
+// ranges with too many buckets for the granularity fail with domain.ErrStatsRange*.
+// Results with dimension filters are marked partial when they reach into pruned days.
 func (s *LinkService) GetLinkStats(ctx context.Context, id int64, filters map[string]interface{}) (*domain.LinkStats, error) {
-	return s.repo.GetLinkStats(ctx, id, filters)
+	loc := time.UTC
+	if l, ok := filters["location"].(*time.Location); ok && l != nil {
+		loc = l
+	}
+	granularity := domain.GranularityDay
+	if g, ok := filters["granularity"].(string); ok && g != "" {
+		granularity = g
+	}
+
+	// Default to the end of the current bucket so in-progress clicks are included
+	to := nextBucket(truncateBucket(time.Now(), granularity, loc), granularity)
+	if end, ok := filters["end_date"].(time.Time); ok {
+		to = end
+	}
+	from := truncateBucket(time.Now(), domain.GranularityDay, loc).AddDate(0, 0, -29)
+	if start, ok := filters["start_date"].(time.Time); ok {
+		from = start
+	}
+	if _, ok := approxBucketSize[granularity]; !ok {
+		return nil, fmt.Errorf("invalid granularity %q", granularity)
+	}
+	// Checked on the effective range, so a far-off "to" with the default "from" is caught too
+	if err := checkTimelineRange(from, to, granularity); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.repo.GetLinkStats(ctx, id, filters)
+	if err != nil {
+		return nil, err
+	}
+	stats.From, stats.To = from, to
+	stats.Granularity = granularity
+	stats.Timezone = loc.String()
+
+	hourly, err := s.clickSeries(ctx, id, filters, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats.Timeline = bucketize(hourly, from, to, granularity, loc)
+
+	// Legacy daily_clicks: days with clicks, newest first
+	days := bucketize(hourly, from, to, domain.GranularityDay, loc)
+	stats.DailyClicks = []domain.DailyClick{}
+	for i := len(days) - 1; i >= 0; i-- {
+		if days[i].Count > 0 {
+			stats.DailyClicks = append(stats.DailyClicks, domain.DailyClick{Date: days[i].Start.Format("2006-01-02"), Count: days[i].Count})
+		}
+	}
+
+	if compare, _ := filters["compare"].(bool); compare {
+		prevFrom, prevTo := from.Add(-to.Sub(from)), from
+		prevHourly, err := s.clickSeries(ctx, id, filters, prevFrom, prevTo)
+		if err != nil {
+			return nil, err
+		}
+
+		prev := &domain.PeriodComparison{
+			From:     prevFrom,
+			To:       prevTo,
+			Timeline: bucketize(prevHourly, prevFrom, prevTo, granularity, loc),
+		}
+		prev.TotalClicks = sumBuckets(prev.Timeline)
+		if prev.TotalClicks > 0 {
+			change := float64(sumBuckets(stats.Timeline)-prev.TotalClicks) / float64(prev.TotalClicks) * 100
+			prev.ChangePct = &change
+		}
+		stats.Previous = prev
+	}
+
+	// Rollups cover pruned days, but dimension filters need the raw visits
+	if hasDimensionFilter(filters) {
+		var earliest time.Time // Breakdowns are all-time without start_date
+		if _, ok := filters["start_date"].(time.Time); ok {
+			earliest = from
+			if stats.Previous != nil {
+				earliest = stats.Previous.From
+			}
+		}
+		pruned, err := s.prunedSince(ctx, earliest)
+		if err != nil {
+			return nil, err
+		}
+		if !pruned.IsZero() {
+			stats.Partial, stats.PrunedUntil = true, &pruned
+		}
+	}
+
+	return stats, nil
+}
+
+// CompactStats rolls up raw visits of completed days so stats don't have to scan them.
+// It returns the number of days compacted.
+func (s *LinkService) CompactStats(ctx context.Context) (int, error) {
+	// Leave room for visits that are written shortly after midnight
+	until := time.Now().Add(-rollupGracePeriod)
+	total := 0
+	for {
+		n, err := s.repo.CompactVisits(ctx, until, 31)
+		total += n
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+}
+
+// PruneVisits deletes raw visits older than the retention period, archiving each day
+// first if an archive is configured. Only rolled-up days are pruned, so stats keep
+// working from rollups. It returns the number of visits removed.
+func (s *LinkService) PruneVisits(ctx context.Context) (int64, error) {
+	if s.retentionDays <= 0 {
+		return 0, nil
+	}
+	if _, err := s.CompactStats(ctx); err != nil {
+		return 0, err
+	}
+
+	cutoff := truncateBucket(time.Now(), domain.GranularityDay, time.UTC).AddDate(0, 0, -s.retentionDays)
+	watermark, err := s.repo.CompactedUntil(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if watermark.Before(cutoff) {
+		cutoff = watermark
+	}
+
+	oldest, err := s.repo.OldestVisitTime(ctx)
+	if err != nil || oldest.IsZero() {
+		return 0, err
+	}
+
+	var pruned int64
+	for day := truncateBucket(oldest, domain.GranularityDay, time.UTC); day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if s.archive != nil {
+			dayFilters := map[string]interface{}{"start_date": day, "end_date": next}
+			err := s.archive.WriteDay(ctx, day, func(write func(*domain.Visit) error) error {
+				return s.repo.ForEachVisit(ctx, dayFilters, write)
+			})
+			if err != nil {
+				return pruned, err
+			}
+		}
+
+		n, err := s.repo.DeleteVisits(ctx, day, next)
+		if err != nil {
+			return pruned, err
+		}
+		pruned += n
+	}
+	return pruned, nil
+}
+
+// clickSeries fetches hourly counts for [from, to) with the remaining stats filters applied
+func (s *LinkService) clickSeries(ctx context.Context, id int64, filters map[string]interface{}, from, to time.Time) ([]domain.TimeBucket, error) {
+	rangeFilters := make(map[string]interface{}, len(filters)+2)
+	for k, v := range filters {
+		rangeFilters[k] = v
+	}
+	rangeFilters["start_date"] = from
+	rangeFilters["end_date"] = to
+	return s.repo.GetClickSeries(ctx, id, rangeFilters)
 }
 
 func (s *LinkService) GetDashboard(ctx context.Context, limit int, search, tag, domainFilter string) ([]domain.Link, int64, error) {
@@ -171,6 +594,62 @@ func (s *LinkService) GetDashboard(ctx context.Context, limit int, search, tag,
 	return s.repo.GetDashboardStats(ctx, limit, filters)
 }
 
+func (s *LinkService) GetDashboardGeo(ctx context.Context, search, tag, domainFilter string) (*domain.GeoBreakdown, error) {
+	filters := map[string]interface{}{
+		"search": search,
+		"tag":    tag,
+		"domain": domainFilter,
+	}
+	return s.repo.GetGeoBreakdown(ctx, 10, filters)
+}
+
+const (
+	defaultAggregateLimit = 50
+	maxAggregateLimit     = 500
+)
+
+// GetClickAggregates totals clicks in the "start_date"/"end_date" range per tag, destination
+// domain or creation month ("location" sets the month boundaries), over the links matching
+// the dashboard filters. It returns up to limit groups (months are never cut) and the clicks
+// on all those links.
+func (s *LinkService) GetClickAggregates(ctx context.Context, groupBy string, limit int, filters map[string]interface{}) ([]domain.ClickAggregate, int64, error) {
+	valid := false
+	for _, g := range domain.AggregateGroupings {
+		valid = valid || g == groupBy
+	}
+	if !valid {
+		return nil, 0, errors.New("invalid grouping")
+	}
+	if limit < 1 {
+		limit = defaultAggregateLimit
+	}
+	if limit > maxAggregateLimit {
+		limit = maxAggregateLimit
+	}
+
+	links, err := s.repo.ListStatsLinks(ctx, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	from, _ := filters["start_date"].(time.Time)
+	to, _ := filters["end_date"].(time.Time)
+	clicks, err := s.repo.CountClicksByLink(ctx, 0, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	for _, link := range links {
+		total += clicks[link.ID]
+	}
+	loc, _ := filters["location"].(*time.Location)
+	aggregates := domain.AggregateClicks(links, clicks, groupBy, loc)
+	if groupBy != domain.AggregateByMonth && len(aggregates) > limit {
+		aggregates = aggregates[:limit]
+	}
+	return aggregates, total, nil
+}
+
 func (s *LinkService) GetLinkByShortCode(ctx context.Context, code string) (*domain.Link, error) {
 	link, err := s.repo.GetByShortCode(ctx, code)
 	if err != nil {
@@ -182,6 +661,93 @@ func (s *LinkService) GetLinkByShortCode(ctx context.Context, code string) (*dom
 	return link, nil
 }
 
+const (
+	defaultVisitPage = 100
+	maxVisitPage     = 1000
+)
+
+// ListVisits returns a page of raw visits after cursor (a visit ID; 0 starts from the oldest),
+// and the cursor of the next page, which is 0 once there are no more. Filters are passed to the
+// repository: "link_id", "start_date", "end_date" and "tag".
+func (s *LinkService) ListVisits(ctx context.Context, filters map[string]interface{}, cursor int64, limit int) ([]domain.Visit, int64, error) {
+	if limit < 1 {
+		limit = defaultVisitPage
+	}
+	if limit > maxVisitPage {
+		limit = maxVisitPage
+	}
+
+	page := make(map[string]interface{}, len(filters)+1)
+	for k, v := range filters {
+		page[k] = v
+	}
+	page["after_id"] = cursor
+
+	// Fetch one extra row to know whether another page follows
+	visits, err := s.repo.ListVisits(ctx, limit+1, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(visits) <= limit {
+		return visits, 0, nil
+	}
+	visits = visits[:limit]
+	return visits, visits[limit-1].ID, nil
+}
+
+// ExportVisits streams every raw visit after cursor to fn. It reads page by page so a slow
+// client never holds a database read open for the whole export.
+func (s *LinkService) ExportVisits(ctx context.Context, filters map[string]interface{}, cursor int64, fn func(*domain.Visit) error) error {
+	for {
+		visits, next, err := s.ListVisits(ctx, filters, cursor, maxVisitPage)
+		if err != nil {
+			return err
+		}
+		for i := range visits {
+			if err := fn(&visits[i]); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// VisitsPrunedUntil returns the pruning watermark when the filtered range (open if there is
+// no start_date) begins before it, so pruned visits are missing from a listing or export.
+// Zero otherwise.
+func (s *LinkService) VisitsPrunedUntil(ctx context.Context, filters map[string]interface{}) (time.Time, error) {
+	start, _ := filters["start_date"].(time.Time)
+	return s.prunedSince(ctx, start)
+}
+
+// hasDimensionFilter reports whether stats filters match on raw visit fields
+func hasDimensionFilter(filters map[string]interface{}) bool {
+	for _, key := range domain.StatsDimensionFilters {
+		if value, ok := filters[key].(string); ok && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// prunedSince returns the pruning watermark if it lies after from, zero otherwise
+func (s *LinkService) prunedSince(ctx context.Context, from time.Time) (time.Time, error) {
+	pruned, err := s.repo.PrunedUntil(ctx)
+	if err != nil || !from.Before(pruned) {
+		return time.Time{}, err
+	}
+	return pruned, nil
+}
+
+// rollupGracePeriod delays compaction of a finished day
+const rollupGracePeriod = 15 * time.Minute
+
+// maxParamLength caps stored query parameter values so visits can't be bloated by crafted URLs
+const maxParamLength = 256
+
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 func generateShortCode(length int) (string, error) {
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// visitRepo keeps recorded visits in memory
+type visitRepo struct {
+	ports.LinkRepository
+	visits []*domain.Visit
+}
+
+func (r *visitRepo) RecordVisit(ctx context.Context, visit *domain.Visit) error {
+	r.visits = append(r.visits, visit)
+	return nil
+}
+
+// fakeGeo resolves fixed addresses and fails for the others
+type fakeGeo map[string]*domain.Location
+
+func (g fakeGeo) Lookup(ip string) (*domain.Location, error) {
+	if loc, ok := g[ip]; ok {
+		return loc, nil
+	}
+	return nil, errors.New("lookup failed")
+}
+
+func TestRecordLinkVisitResolvesLocation(t *testing.T) {
+	repo := &visitRepo{}
+	geo := fakeGeo{"203.0.113.7": {Country: "TH", Region: "Bangkok", City: "Bangkok"}}
+	svc := NewLinkService(repo, WithGeoResolver(geo))
+	link := &domain.Link{ID: 7, ShortCode: "abc123"}
+
+	for _, ip := range []string{"203.0.113.7", "198.51.100.1"} {
+		if _, err := svc.RecordLinkVisit(context.Background(), link, domain.VisitInput{IP: ip}); err != nil {
+			t.Fatalf("RecordLinkVisit(%s): %v", ip, err)
+		}
+	}
+
+	if len(repo.visits) != 2 {
+		t.Fatalf("recorded %d visits, want 2; a failed lookup must not drop the visit", len(repo.visits))
+	}
+	if v := repo.visits[0]; v.Country != "TH" || v.Region != "Bangkok" || v.City != "Bangkok" {
+		t.Errorf("resolved visit = %+v", v)
+	}
+	if v := repo.visits[1]; v.Country != "" || v.City != "" {
+		t.Errorf("unresolved visit has a location: %+v", v)
+	}
+}
+
+func TestCaptureParams(t *testing.T) {
+	svc := NewLinkService(nil, WithTrackedParams([]string{"ref", " gclid ", ""}))
+	long := strings.Repeat("a", maxParamLength-1) + "ไทย" // a 3-byte character straddles the limit
+
+	tests := []struct {
+		name   string
+		query  map[string]string
+		want   domain.Visit
+		params map[string]string
+	}{
+		{
+			name:  "utm",
+			query: map[string]string{"utm_source": " newsletter ", "utm_medium": "email", "utm_campaign": "spring", "utm_term": "shoes", "utm_content": "hero"},
+			want:  domain.Visit{UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "spring", UTMTerm: "shoes", UTMContent: "hero"},
+		},
+		{
+			name:  "blank values are skipped",
+			query: map[string]string{"utm_source": "  ", "ref": ""},
+		},
+		{
+			name:   "only whitelisted params are kept",
+			query:  map[string]string{"ref": "twitter", "gclid": "abc", "session": "secret"},
+			params: map[string]string{"ref": "twitter", "gclid": "abc"},
+		},
+		{
+			name:  "long values are cut on a character boundary",
+			query: map[string]string{"utm_campaign": long},
+			want:  domain.Visit{UTMCampaign: strings.Repeat("a", maxParamLength-1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visit domain.Visit
+			svc.captureParams(&visit, tt.query)
+			if visit.UTMSource != tt.want.UTMSource || visit.UTMMedium != tt.want.UTMMedium || visit.UTMCampaign != tt.want.UTMCampaign ||
+				visit.UTMTerm != tt.want.UTMTerm || visit.UTMContent != tt.want.UTMContent {
+				t.Errorf("UTM = %+v, want %+v", visit, tt.want)
+			}
+			if !utf8.ValidString(visit.UTMCampaign) {
+				t.Errorf("utm_campaign is not valid UTF-8: %q", visit.UTMCampaign)
+			}
+			if !maps.Equal(visit.Params, tt.params) {
+				t.Errorf("params = %v, want %v", visit.Params, tt.params)
+			}
+		})
+	}
+}
+
+func TestGetLinkStatsChecksEffectiveRange(t *testing.T) {
+	svc := NewLinkService(nil) // Rejected before the repository is queried
+	farFuture := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    error
+	}{
+		{"far to with default from", map[string]interface{}{"end_date": farFuture, "granularity": domain.GranularityHour}, domain.ErrStatsRangeTooLarge},
+		{"to before default from", map[string]interface{}{"end_date": time.Now().AddDate(0, 0, -60)}, domain.ErrStatsRangeOrder},
+		{"reversed", map[string]interface{}{"start_date": time.Now(), "end_date": time.Now().Add(-time.Hour)}, domain.ErrStatsRangeOrder},
+	}
+	for _, tt := range tests {
+		if _, err := svc.GetLinkStats(context.Background(), 1, tt.filters); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// pruneRepo keeps visits in memory with fixed compaction and pruning watermarks
+type pruneRepo struct {
+	ports.LinkRepository
+	visits      []*domain.Visit
+	compacted   time.Time
+	prunedUntil time.Time
+}
+
+func (r *pruneRepo) CompactVisits(ctx context.Context, until time.Time, maxDays int) (int, error) {
+	return 0, nil
+}
+
+func (r *pruneRepo) CompactedUntil(ctx context.Context) (time.Time, error) { return r.compacted, nil }
+
+func (r *pruneRepo) PrunedUntil(ctx context.Context) (time.Time, error) { return r.prunedUntil, nil }
+
+func (r *pruneRepo) OldestVisitTime(ctx context.Context) (time.Time, error) {
+	var oldest time.Time
+	for _, v := range r.visits {
+		if oldest.IsZero() || v.CreatedAt.Before(oldest) {
+			oldest = v.CreatedAt
+		}
+	}
+	return oldest, nil
+}
+
+func (r *pruneRepo) ForEachVisit(ctx context.Context, filters map[string]interface{}, fn func(*domain.Visit) error) error {
+	start, end := filters["start_date"].(time.Time), filters["end_date"].(time.Time)
+	for _, v := range r.visits {
+		if !v.CreatedAt.Before(start) && v.CreatedAt.Before(end) {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *pruneRepo) DeleteVisits(ctx context.Context, from, to time.Time) (int64, error) {
+	kept := r.visits[:0]
+	for _, v := range r.visits {
+		if v.CreatedAt.Before(from) || !v.CreatedAt.Before(to) {
+			kept = append(kept, v)
+		}
+	}
+	n := int64(len(r.visits) - len(kept))
+	r.visits = kept
+	if to.After(r.prunedUntil) {
+		r.prunedUntil = to
+	}
+	return n, nil
+}
+
+func (r *pruneRepo) GetLinkStats(ctx context.Context, linkID int64, filters map[string]interface{}) (*domain.LinkStats, error) {
+	return &domain.LinkStats{}, nil
+}
+
+func (r *pruneRepo) GetClickSeries(ctx context.Context, linkID int64, filters map[string]interface{}) ([]domain.TimeBucket, error) {
+	return nil, nil
+}
+
+// fakeArchive keeps archived visits by day
+type fakeArchive map[string][]*domain.Visit
+
+func (a fakeArchive) WriteDay(ctx context.Context, day time.Time, fill func(write func(*domain.Visit) error) error) error {
+	return fill(func(v *domain.Visit) error {
+		a[day.Format("2006-01-02")] = append(a[day.Format("2006-01-02")], v)
+		return nil
+	})
+}
+
+func TestPruneVisits(t *testing.T) {
+	today := truncateBucket(time.Now(), domain.GranularityDay, time.UTC)
+	day := func(n int) time.Time { return today.AddDate(0, 0, n) }
+
+	tests := []struct {
+		name      string
+		compacted time.Time
+		pruned    int64
+		archived  []string
+	}{
+		{"up to the retention cutoff", today, 2, []string{day(-5).Format("2006-01-02"), day(-4).Format("2006-01-02")}},
+		{"not past the compaction watermark", day(-4), 1, []string{day(-5).Format("2006-01-02")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &pruneRepo{compacted: tt.compacted, visits: []*domain.Visit{
+				{ID: 1, CreatedAt: day(-5).Add(time.Hour)},
+				{ID: 2, CreatedAt: day(-4).Add(23 * time.Hour)},
+				{ID: 3, CreatedAt: day(-3).Add(time.Minute)}, // Inside the 3-day retention
+				{ID: 4, CreatedAt: day(-1)},
+			}}
+			archive := fakeArchive{}
+			svc := NewLinkService(repo, WithRetention(3, archive))
+
+			pruned, err := svc.PruneVisits(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pruned != tt.pruned || len(repo.visits) != 4-int(tt.pruned) {
+				t.Errorf("pruned %d, %d left; want %d pruned", pruned, len(repo.visits), tt.pruned)
+			}
+			if len(archive) != len(tt.archived) {
+				t.Errorf("archived days %v, want %v", slices.Collect(maps.Keys(archive)), tt.archived)
+			}
+			for _, d := range tt.archived {
+				if len(archive[d]) != 1 {
+					t.Errorf("archive[%s] = %v, want the day's visit", d, archive[d])
+				}
+			}
+		})
+	}
+}
+
+func TestGetLinkStatsMarksPrunedDimensionFilters(t *testing.T) {
+	prunedUntil := truncateBucket(time.Now(), domain.GranularityDay, time.UTC).AddDate(0, 0, -10)
+	svc := NewLinkService(&pruneRepo{prunedUntil: prunedUntil})
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		partial bool
+	}{
+		{"rollups cover unfiltered stats", map[string]interface{}{}, false},
+		{"all-time breakdowns with a filter", map[string]interface{}{"country": "TH"}, true},
+		{"range before the watermark", map[string]interface{}{"country": "TH", "start_date": prunedUntil.AddDate(0, 0, -1)}, true},
+		{"range after the watermark", map[string]interface{}{"utm_source": "news", "start_date": prunedUntil}, false},
+		{"comparison reaching back", map[string]interface{}{"referer": "x", "start_date": prunedUntil.AddDate(0, 0, 5), "compare": true}, true},
+	}
+	for _, tt := range tests {
+		stats, err := svc.GetLinkStats(context.Background(), 1, tt.filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stats.Partial != tt.partial || (stats.PrunedUntil != nil) != tt.partial {
+			t.Errorf("%s: partial = %v, pruned_until = %v; want partial %v", tt.name, stats.Partial, stats.PrunedUntil, tt.partial)
+		}
+	}
+}
+
+// pageRepo lists visits by ID after the after_id filter
+type pageRepo struct {
+	ports.LinkRepository
+	visits []domain.Visit
+	limits []int
+}
+
+func (r *pageRepo) ListVisits(ctx context.Context, limit int, filters map[string]interface{}) ([]domain.Visit, error) {
+	r.limits = append(r.limits, limit)
+	after, _ := filters["after_id"].(int64)
+	var page []domain.Visit
+	for _, v := range r.visits {
+		if v.ID > after && len(page) < limit {
+			page = append(page, v)
+		}
+	}
+	return page, nil
+}
+
+func TestListVisitsPages(t *testing.T) {
+	repo := &pageRepo{}
+	for id := int64(1); id <= 5; id++ {
+		repo.visits = append(repo.visits, domain.Visit{ID: id})
+	}
+	svc := NewLinkService(repo)
+	ctx := context.Background()
+
+	var ids []int64
+	cursor := int64(0)
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination doesn't end")
+		}
+		visits, next, err := svc.ListVisits(ctx, map[string]interface{}{}, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range visits {
+			ids = append(ids, v.ID)
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if !slices.Equal(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("visited %v, want every visit once", ids)
+	}
+	if !slices.Equal(repo.limits, []int{3, 3, 3}) {
+		t.Errorf("repository limits = %v, want one extra row per page", repo.limits)
+	}
+
+	// A last page that is exactly full has no next cursor
+	if _, next, err := svc.ListVisits(ctx, map[string]interface{}{}, 3, 2); err != nil || next != 0 {
+		t.Errorf("next = %d, %v; want 0 after the last visit", next, err)
+	}
+
+	var exported []int64
+	err := svc.ExportVisits(ctx, map[string]interface{}{}, 1, func(v *domain.Visit) error {
+		exported = append(exported, v.ID)
+		return nil
+	})
+	if err != nil || !slices.Equal(exported, []int64{2, 3, 4, 5}) {
+		t.Errorf("exported %v, %v; want the visits after the cursor", exported, err)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+// maxTimelineBuckets bounds the size of a stats timeline
+const maxTimelineBuckets = 2000
+
+// approxBucketSize is the (shortest) duration of each timeline granularity, used to bound range size
+var approxBucketSize = map[string]time.Duration{
+	domain.GranularityHour:  time.Hour,
+	domain.GranularityDay:   23 * time.Hour, // DST days can be 23h
+	domain.GranularityWeek:  7 * 23 * time.Hour,
+	domain.GranularityMonth: 28 * 23 * time.Hour,
+}
+
+// checkTimelineRange rejects empty or reversed ranges and ranges with more than
+// maxTimelineBuckets buckets of granularity
+func checkTimelineRange(from, to time.Time, granularity string) error {
+	if !from.Before(to) {
+		return domain.ErrStatsRangeOrder
+	}
+	if to.Sub(from)/approxBucketSize[granularity] > maxTimelineBuckets {
+		return domain.ErrStatsRangeTooLarge
+	}
+	return nil
+}
+
+// truncateBucket returns the start of the bucket containing t, in loc
+func truncateBucket(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch granularity {
+	case domain.GranularityHour:
+		// Absolute truncation keeps DST transitions unambiguous. Zones with
+		// sub-hour offsets get buckets aligned to UTC hours.
+		return t.Truncate(time.Hour)
+	case domain.GranularityWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case domain.GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket following start
+func nextBucket(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case domain.GranularityHour:
+		return start.Add(time.Hour)
+	case domain.GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case domain.GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// bucketize folds hourly UTC counts into zero-filled buckets covering [from, to)
+func bucketize(hourly []domain.TimeBucket, from, to time.Time, granularity string, loc *time.Location) []domain.TimeBucket {
+	timeline := []domain.TimeBucket{}
+	index := make(map[int64]int)
+	for start := truncateBucket(from, granularity, loc); start.Before(to); start = nextBucket(start, granularity) {
+		index[start.Unix()] = len(timeline)
+		timeline = append(timeline, domain.TimeBucket{Start: start})
+	}
+
+	for _, h := range hourly {
+		if i, ok := index[truncateBucket(h.Start, granularity, loc).Unix()]; ok {
+			timeline[i].Count += h.Count
+		}
+	}
+	return timeline
+}
+
+// sumBuckets returns the total count of a timeline
+func sumBuckets(timeline []domain.TimeBucket) int64 {
+	var total int64
+	for _, b := range timeline {
+		total += b.Count
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestBucketize(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	hourly := []domain.TimeBucket{
+		{Start: time.Date(2024, 3, 1, 16, 0, 0, 0, time.UTC), Count: 2}, // Mar 1 23:00 ICT
+		{Start: time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC), Count: 3}, // Mar 2 00:00 ICT
+		{Start: time.Date(2024, 3, 4, 1, 0, 0, 0, time.UTC), Count: 1},  // Mar 4 08:00 ICT
+	}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, bangkok)
+	to := time.Date(2024, 3, 5, 0, 0, 0, 0, bangkok)
+
+	t.Run("Daily in timezone with zero fill", func(t *testing.T) {
+		got := bucketize(hourly, from, to, domain.GranularityDay, bangkok)
+		want := []int64{2, 3, 0, 1}
+		if len(got) != len(want) {
+			t.Fatalf("got %d buckets, want %d", len(got), len(want))
+		}
+		for i, b := range got {
+			if b.Count != want[i] {
+				t.Errorf("bucket %s = %d, want %d", b.Start, b.Count, want[i])
+			}
+		}
+	})
+
+	t.Run("Weekly starts on Monday", func(t *testing.T) {
+		got := bucketize(hourly, from, to, domain.GranularityWeek, bangkok)
+		if len(got) != 2 {
+			t.Fatalf("got %d buckets, want 2", len(got))
+		}
+		if got[0].Start.Weekday() != time.Monday || got[0].Count != 5 || got[1].Count != 1 {
+			t.Errorf("unexpected weeks: %+v", got)
+		}
+	})
+
+	t.Run("Hourly across DST change", func(t *testing.T) {
+		ny, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Skip("zoneinfo not available")
+		}
+		// 2024-03-10 02:00 EST -> 03:00 EDT: the local day has 23 hours
+		dayStart := time.Date(2024, 3, 10, 0, 0, 0, 0, ny)
+		got := bucketize(nil, dayStart, dayStart.AddDate(0, 0, 1), domain.GranularityHour, ny)
+		if len(got) != 23 {
+			t.Errorf("got %d hourly buckets, want 23", len(got))
+		}
+	})
+}
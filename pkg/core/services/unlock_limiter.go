@@ -0,0 +1,100 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	unlockFreePerClient      = 5  // wrong passwords per client before backoff starts
+	unlockAlertPerCollection = 20 // wrong passwords per collection, from any client, logged as a warning
+	unlockBackoffBase        = time.Second
+	unlockBackoffMax         = 15 * time.Minute
+	unlockAttemptTTL         = time.Hour // failures are forgotten after this long without one
+)
+
+// unlockLimiter throttles password guessing on protected collections. Past a number of
+// free failures, each further failure blocks the key for twice as long as the last one.
+// Keys can also just be counted, without blocking.
+type unlockLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*unlockAttempts
+	lastSweep time.Time
+}
+
+type unlockAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func newUnlockLimiter() *unlockLimiter {
+	return &unlockLimiter{entries: make(map[string]*unlockAttempts)}
+}
+
+// wait returns how long key is still blocked, 0 if it may try now
+func (l *unlockLimiter) wait(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok && now.Before(e.blockedUntil) {
+		return e.blockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// fail records a wrong password for key, which is allowed free failures before backoff
+func (l *unlockLimiter) fail(key string, free int, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.record(key, now)
+	if excess := e.failures - free; excess > 0 {
+		e.blockedUntil = now.Add(unlockBackoff(excess))
+	}
+}
+
+// count records a wrong password for key without ever blocking it, and returns the
+// failures within the TTL
+func (l *unlockLimiter) count(key string, now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.record(key, now).failures
+}
+
+// record adds a failure to key's entry; l.mu must be held
+func (l *unlockLimiter) record(key string, now time.Time) *unlockAttempts {
+	// Sweep idle entries at most once per TTL to bound memory
+	if now.Sub(l.lastSweep) >= unlockAttemptTTL {
+		for k, e := range l.entries {
+			if now.Sub(e.lastFailure) >= unlockAttemptTTL && !now.Before(e.blockedUntil) {
+				delete(l.entries, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.lastFailure) >= unlockAttemptTTL {
+		e = &unlockAttempts{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	return e
+}
+
+// reset forgets the failures of key after a correct password
+func (l *unlockLimiter) reset(key string) {
+	l.mu.Lock()
+	delete(l.entries, key)
+	l.mu.Unlock()
+}
+
+// unlockBackoff is the block after the given failure past the free ones: 1s, 2s, 4s, ... capped at 15m
+func unlockBackoff(excess int) time.Duration {
+	wait := unlockBackoffBase
+	for i := 1; i < excess && wait < unlockBackoffMax; i++ {
+		wait *= 2
+	}
+	return min(wait, unlockBackoffMax)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestUnlockLimiterBacksOff(t *testing.T) {
+	l := newUnlockLimiter()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		l.fail("k", 3, now)
+	}
+	if wait := l.wait("k", now); wait != 0 {
+		t.Fatalf("blocked after the free failures: %v", wait)
+	}
+	l.fail("k", 3, now)
+	if wait := l.wait("k", now); wait != unlockBackoffBase {
+		t.Errorf("wait = %v, want %v", wait, unlockBackoffBase)
+	}
+	now = now.Add(time.Second)
+	l.fail("k", 3, now)
+	if wait := l.wait("k", now); wait != 2*unlockBackoffBase {
+		t.Errorf("wait after another failure = %v, want it doubled", wait)
+	}
+	if wait := l.wait("other", now); wait != 0 {
+		t.Errorf("unrelated key blocked for %v", wait)
+	}
+
+	// Forgotten after a long pause
+	now = now.Add(unlockAttemptTTL)
+	l.fail("k", 3, now)
+	if wait := l.wait("k", now); wait != 0 {
+		t.Errorf("old failures still count: %v", wait)
+	}
+
+	l.reset("k")
+	if _, ok := l.entries["k"]; ok {
+		t.Error("reset kept the entry")
+	}
+	if got := unlockBackoff(30); got != unlockBackoffMax {
+		t.Errorf("unlockBackoff(30) = %v, want the cap", got)
+	}
+}
+
+// cheapPasswordHash is a hashPassword-format hash with a single iteration, to keep tests fast
+func cheapPasswordHash(t *testing.T, password string) string {
+	salt := []byte("0123456789abcdef")
+	key, err := pbkdf2.Key(sha256.New, password, salt, 1, sha256.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passwordScheme + "$1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key)
+}
+
+func TestVerifyPasswordThrottles(t *testing.T) {
+	svc := NewCollectionService(nil)
+	collection := &domain.Collection{ID: 9, Visibility: domain.VisibilityPassword, PasswordHash: cheapPasswordHash(t, "correct horse")}
+
+	for i := 0; i < unlockFreePerClient; i++ {
+		if ok, wait := svc.VerifyPassword(collection, "guess", "203.0.113.7"); ok || wait != 0 {
+			t.Fatalf("attempt %d = %v, %v; want a plain failure", i+1, ok, wait)
+		}
+	}
+	svc.VerifyPassword(collection, "guess", "203.0.113.7")
+	if ok, wait := svc.VerifyPassword(collection, "correct horse", "203.0.113.7"); ok || wait <= 0 {
+		t.Errorf("blocked client = %v, %v; want a wait even with the right password", ok, wait)
+	}
+	if ok, wait := svc.VerifyPassword(collection, "correct horse", "198.51.100.1"); !ok || wait != 0 {
+		t.Errorf("other client = %v, %v; want unlocked", ok, wait)
+	}
+
+	// Guesses spread over many clients are counted but don't lock other visitors out
+	for i := 0; i < 2*unlockAlertPerCollection; i++ {
+		svc.VerifyPassword(collection, "guess", "192.0.2."+strconv.Itoa(i))
+	}
+	if n := svc.unlocks.entries["collection:9"].failures; n != 2*unlockAlertPerCollection+unlockFreePerClient+1 {
+		t.Errorf("collection failures = %d", n)
+	}
+	if ok, wait := svc.VerifyPassword(collection, "correct horse", "198.51.100.2"); !ok || wait != 0 {
+		t.Errorf("visitor after guesses from many clients = %v, %v; want unlocked", ok, wait)
+	}
+}
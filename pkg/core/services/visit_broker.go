@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+var ErrStreamUnavailable = errors.New("live visit stream not available")
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is disconnected
+const subscriberBuffer = 64
+
+// VisitBroker fans recorded visits out to live subscribers in this process and keeps a
+// short history so reconnecting clients can resume from their last event ID.
+type VisitBroker struct {
+	mu      sync.Mutex
+	seq     int64
+	history []domain.VisitEvent // ring of the latest events, oldest first
+	size    int
+	subs    map[*visitSubscriber]struct{}
+	closed  bool
+}
+
+type visitSubscriber struct {
+	ch    chan domain.VisitEvent
+	match func(*domain.VisitEvent) bool
+}
+
+// NewVisitBroker keeps the last historySize events for replay
+func NewVisitBroker(historySize int) *VisitBroker {
+	return &VisitBroker{
+		// Seeding with the start time keeps IDs increasing across restarts,
+		// so a stale Last-Event-ID never looks newer than fresh events
+		seq:  time.Now().UnixMicro(),
+		size: historySize,
+		subs: make(map[*visitSubscriber]struct{}),
+	}
+}
+
+// Publish assigns the event its ID and delivers it without blocking. A subscriber whose
+// buffer is full is disconnected; it can reconnect and replay what it missed.
+func (b *VisitBroker) Publish(event domain.VisitEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	event.ID = b.seq
+	if b.size > 0 {
+		if len(b.history) >= b.size {
+			b.history = b.history[1:]
+		}
+		b.history = append(b.history, event)
+	}
+
+	for sub := range b.subs {
+		if !sub.match(&event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe returns buffered events after lastEventID (0 for none) followed by a channel of
+// new events matching match. The channel is closed when ctx is done, the subscriber falls
+// behind, or the broker closes.
+func (b *VisitBroker) Subscribe(ctx context.Context, lastEventID int64, match func(*domain.VisitEvent) bool) ([]domain.VisitEvent, <-chan domain.VisitEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, ErrStreamUnavailable
+	}
+
+	var replay []domain.VisitEvent
+	if lastEventID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastEventID && match(&event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &visitSubscriber{ch: make(chan domain.VisitEvent, subscriberBuffer), match: match}
+	b.subs[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.removeLocked(sub)
+		b.mu.Unlock()
+	}()
+
+	return replay, sub.ch, nil
+}
+
+// Close disconnects all subscribers, e.g. so open streams don't hold up server shutdown
+func (b *VisitBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.removeLocked(sub)
+	}
+}
+
+func (b *VisitBroker) removeLocked(sub *visitSubscriber) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// visitEventFilter matches events of linkID (0 for any) on links tagged tag ("" for any)
+func visitEventFilter(linkID int64, tag string) func(*domain.VisitEvent) bool {
+	return func(event *domain.VisitEvent) bool {
+		if linkID != 0 && event.LinkID != linkID {
+			return false
+		}
+		return tag == "" || slices.Contains(event.Tags, tag)
+	}
+}
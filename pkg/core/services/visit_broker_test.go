@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+)
+
+func TestVisitBroker(t *testing.T) {
+	t.Run("Filters and replays after Last-Event-ID", func(t *testing.T) {
+		b := NewVisitBroker(10)
+		b.Publish(domain.VisitEvent{LinkID: 1, Tags: []string{"launch"}})
+		b.Publish(domain.VisitEvent{LinkID: 2})
+		b.Publish(domain.VisitEvent{LinkID: 3, Tags: []string{"launch"}})
+
+		// An ID from before this process started replays everything buffered
+		replay, _, err := b.Subscribe(context.Background(), 1, func(*domain.VisitEvent) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(replay) != 3 {
+			t.Errorf("replay from old ID = %d events, want 3", len(replay))
+		}
+
+		all, _, _ := b.Subscribe(context.Background(), b.seq-3, visitEventFilter(0, "launch"))
+		if len(all) != 2 || all[0].LinkID != 1 || all[1].LinkID != 3 {
+			t.Errorf("tag replay = %+v, want links 1 and 3", all)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, events, _ := b.Subscribe(ctx, 0, visitEventFilter(2, ""))
+		b.Publish(domain.VisitEvent{LinkID: 1})
+		b.Publish(domain.VisitEvent{LinkID: 2})
+		if got := <-events; got.LinkID != 2 {
+			t.Errorf("live event for link %d, want 2", got.LinkID)
+		}
+
+		cancel()
+		if _, ok := <-events; ok {
+			t.Error("channel still open after cancel")
+		}
+	})
+
+	t.Run("Disconnects slow subscribers", func(t *testing.T) {
+		b := NewVisitBroker(0)
+		_, events, _ := b.Subscribe(context.Background(), 0, func(*domain.VisitEvent) bool { return true })
+		for i := 0; i < subscriberBuffer+1; i++ {
+			b.Publish(domain.VisitEvent{LinkID: 1})
+		}
+
+		n := 0
+		for range events {
+			n++
+		}
+		if n != subscriberBuffer {
+			t.Errorf("received %d events before disconnect, want %d", n, subscriberBuffer)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+var ErrVisitWriterClosed = errors.New("visit writer closed")
+
+// visitWriteAttempts retries a failed batch (e.g. SQLITE_BUSY) before it is counted as lost
+const visitWriteAttempts = 3
+
+// VisitWriter buffers visits in a bounded queue and writes them to the repository in batches.
+// Enqueue never blocks: when the queue is full the visit is dropped and counted, so a slow
+// database can't stall redirects.
+type VisitWriter struct {
+	repo          ports.LinkRepository
+	queue         chan *domain.Visit
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.RWMutex // guards closed so Enqueue never sends on a closed queue
+	closed bool
+
+	enqueued atomic.Int64
+	written  atomic.Int64
+	dropped  atomic.Int64
+	failed   atomic.Int64
+}
+
+// NewVisitWriter starts a writer that flushes every batchSize visits or every flushInterval,
+// whichever comes first. Call Close to drain the queue on shutdown.
+func NewVisitWriter(repo ports.LinkRepository, queueSize, batchSize int, flushInterval time.Duration) *VisitWriter {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	w := &VisitWriter{
+		repo:          repo,
+		queue:         make(chan *domain.Visit, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue queues a visit for writing without blocking
+func (w *VisitWriter) Enqueue(visit *domain.Visit) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrVisitWriterClosed
+	}
+
+	select {
+	case w.queue <- visit:
+		w.enqueued.Add(1)
+		return nil
+	default:
+		w.dropped.Add(1)
+		return domain.ErrVisitQueueFull
+	}
+}
+
+// Close stops accepting visits and waits until everything queued is written or ctx is done
+func (w *VisitWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the queue counters
+func (w *VisitWriter) Stats() *domain.VisitQueueStats {
+	return &domain.VisitQueueStats{
+		Queued:   len(w.queue),
+		Capacity: cap(w.queue),
+		Enqueued: w.enqueued.Load(),
+		Written:  w.written.Load(),
+		Dropped:  w.dropped.Load(),
+		Failed:   w.failed.Load(),
+	}
+}
+
+func (w *VisitWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*domain.Visit, 0, w.batchSize)
+	var reportedDrops int64
+	for {
+		select {
+		case visit, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, visit)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+
+			if dropped := w.dropped.Load(); dropped > reportedDrops {
+				log.Printf("Visit queue full: dropped %d visits", dropped-reportedDrops)
+				reportedDrops = dropped
+			}
+		}
+	}
+}
+
+func (w *VisitWriter) flush(batch []*domain.Visit) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	for attempt := 1; attempt <= visitWriteAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = w.repo.RecordVisits(ctx, batch)
+		cancel()
+		if err == nil {
+			w.written.Add(int64(len(batch)))
+			return
+		}
+		if attempt < visitWriteAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+
+	w.failed.Add(int64(len(batch)))
+	log.Printf("Failed to write %d visits: %v", len(batch), err)
+}
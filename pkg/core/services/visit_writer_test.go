@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+// batchRepo records the batches passed to RecordVisits; other methods are unused
+type batchRepo struct {
+	ports.LinkRepository
+	mu      sync.Mutex
+	batches [][]*domain.Visit
+	block   chan struct{} // if set, RecordVisits waits on it
+}
+
+func (r *batchRepo) RecordVisits(ctx context.Context, visits []*domain.Visit) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]*domain.Visit(nil), visits...))
+	return nil
+}
+
+func TestVisitWriter(t *testing.T) {
+	t.Run("Batches and flushes on close", func(t *testing.T) {
+		repo := &batchRepo{}
+		w := NewVisitWriter(repo, 100, 4, time.Hour)
+		for i := 0; i < 10; i++ {
+			if err := w.Enqueue(&domain.Visit{LinkID: int64(i)}); err != nil {
+				t.Fatalf("enqueue %d: %v", i, err)
+			}
+		}
+		if err := w.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var sizes []int
+		for _, b := range repo.batches {
+			sizes = append(sizes, len(b))
+		}
+		if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+			t.Errorf("batch sizes = %v, want [4 4 2]", sizes)
+		}
+		if stats := w.Stats(); stats.Written != 10 || stats.Dropped != 0 {
+			t.Errorf("stats = %+v", stats)
+		}
+		if err := w.Enqueue(&domain.Visit{}); !errors.Is(err, ErrVisitWriterClosed) {
+			t.Errorf("enqueue after close = %v, want ErrVisitWriterClosed", err)
+		}
+	})
+
+	t.Run("Drops when full", func(t *testing.T) {
+		repo := &batchRepo{block: make(chan struct{})}
+		w := NewVisitWriter(repo, 2, 1, time.Hour)
+
+		// The first visit is taken off the queue and blocks in RecordVisits
+		_ = w.Enqueue(&domain.Visit{})
+		deadline := time.Now().Add(time.Second)
+		for w.Stats().Queued != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+
+		var full int
+		for i := 0; i < 5; i++ {
+			if errors.Is(w.Enqueue(&domain.Visit{}), domain.ErrVisitQueueFull) {
+				full++
+			}
+		}
+		if full != 3 {
+			t.Errorf("dropped %d visits, want 3", full)
+		}
+
+		close(repo.block)
+		if err := w.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if stats := w.Stats(); stats.Written != 3 || stats.Dropped != 3 {
+			t.Errorf("stats = %+v, want 3 written and 3 dropped", stats)
+		}
+	})
+}
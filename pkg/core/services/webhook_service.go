@@ -0,0 +1,545 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+const (
+	webhookMaxAttempts = 8
+	webhookRetryBase   = 30 * time.Second // doubled after each failed attempt
+	webhookRetryMax    = time.Hour
+	webhookSendTimeout = 10 * time.Second
+	webhookClaimLease  = time.Minute // must exceed webhookSendTimeout
+	webhookClaimBatch  = 50
+	webhookWorkers     = 4
+	webhookCacheTTL    = 30 * time.Second
+	webhookClickBatch  = 500 // queued click events turned into deliveries per insert
+	maxDeliveryPage    = 100
+)
+
+// WebhookService manages webhooks, queues a delivery per subscribed webhook for each
+// published event, and sends due deliveries with retries.
+type WebhookService struct {
+	repo   ports.LinkRepository
+	sender ports.WebhookSender
+
+	// Active webhooks are cached so click events don't query the table on every redirect
+	mu         sync.Mutex
+	active     []domain.Webhook
+	loadedAt   time.Time
+	generation int // bumped by invalidate, so a load racing with a change isn't cached
+
+	// Optional queue taking link.clicked events off the redirect path
+	clicks        chan publishedEvent
+	flushInterval time.Duration
+	clicksDone    chan struct{}
+	clicksMu      sync.RWMutex // guards clicksClosed so Publish never sends on a closed queue
+	clicksClosed  bool
+	droppedClicks atomic.Int64
+
+	retentionDays int // Finished deliveries older than this are pruned; 0 keeps them
+}
+
+// publishedEvent is an event waiting to be turned into deliveries
+type publishedEvent struct {
+	eventType string
+	data      interface{}
+	at        time.Time
+}
+
+// WebhookServiceOption configures optional WebhookService behaviour
+type WebhookServiceOption func(*WebhookService)
+
+// WithClickQueue makes Publish hand link.clicked events to a bounded in-memory queue
+// instead of inserting their deliveries on the redirect. A background goroutine inserts
+// them in batches at least every flushInterval; events are dropped while the queue is
+// full. Call Close to drain it on shutdown.
+func WithClickQueue(size int, flushInterval time.Duration) WebhookServiceOption {
+	return func(s *WebhookService) {
+		if size < 1 {
+			return
+		}
+		if flushInterval <= 0 {
+			flushInterval = time.Second
+		}
+		s.clicks = make(chan publishedEvent, size)
+		s.flushInterval = flushInterval
+	}
+}
+
+// WithDeliveryRetention makes PruneDeliveries delete succeeded and failed deliveries
+// older than days (0 keeps them forever)
+func WithDeliveryRetention(days int) WebhookServiceOption {
+	return func(s *WebhookService) {
+		s.retentionDays = days
+	}
+}
+
+func NewWebhookService(repo ports.LinkRepository, sender ports.WebhookSender, opts ...WebhookServiceOption) *WebhookService {
+	s := &WebhookService{repo: repo, sender: sender}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.clicks != nil {
+		s.clicksDone = make(chan struct{})
+		go s.runClicks()
+	}
+	return s
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, rawURL string, events []string, secret string) (*domain.Webhook, error) {
+	if err := validateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		if secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &domain.Webhook{
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    events,
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	// The secret is only ever shown here
+	return webhook, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error) {
+	webhook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil || webhook == nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook changes the URL, events and/or active flag; empty values are left unchanged
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int64, rawURL string, events []string, active *bool) (*domain.Webhook, error) {
+	webhook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, errors.New("webhook not found")
+	}
+
+	if rawURL != "" {
+		if err := validateWebhookURL(rawURL); err != nil {
+			return nil, err
+		}
+		webhook.URL = rawURL
+	}
+	if events != nil {
+		if webhook.Events, err = normalizeWebhookEvents(events); err != nil {
+			return nil, err
+		}
+	}
+	if active != nil {
+		webhook.Active = *active
+	}
+	webhook.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int64) error {
+	if err := s.repo.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first, optionally by status.
+// Pages hold at most maxDeliveryPage deliveries.
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID int64, status string, page, limit int) ([]domain.WebhookDelivery, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	limit = min(limit, maxDeliveryPage)
+	filters := map[string]interface{}{"status": status}
+
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, webhookID, limit, (page-1)*limit, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.CountWebhookDeliveries(ctx, webhookID, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// Publish queues a delivery of the event for every active webhook subscribed to it.
+// Failures are logged; they never fail the operation that emitted the event. With a
+// click queue, link.clicked events are only enqueued here.
+func (s *WebhookService) Publish(ctx context.Context, eventType string, data interface{}) {
+	event := publishedEvent{eventType: eventType, data: data, at: time.Now()}
+	if eventType == domain.EventLinkClicked && s.clicks != nil {
+		s.enqueueClick(event)
+		return
+	}
+	s.createDeliveries(ctx, []publishedEvent{event})
+}
+
+// createDeliveries inserts the deliveries of events to their subscribed webhooks at once
+func (s *WebhookService) createDeliveries(ctx context.Context, events []publishedEvent) {
+	webhooks, err := s.activeWebhooks(ctx)
+	if err != nil {
+		log.Printf("Webhook events dropped (%d): %v", len(events), err)
+		return
+	}
+
+	var deliveries []*domain.WebhookDelivery
+	for _, event := range events {
+		var payload []byte
+		for _, webhook := range webhooks {
+			if !webhook.Subscribed(event.eventType) {
+				continue
+			}
+			if payload == nil {
+				eventID, err := randomHex(16)
+				if err != nil {
+					log.Printf("Webhook event %s dropped: %v", event.eventType, err)
+					break
+				}
+				body := domain.Event{ID: eventID, Type: event.eventType, CreatedAt: event.at.UTC(), Data: event.data}
+				if payload, err = json.Marshal(body); err != nil {
+					log.Printf("Webhook event %s dropped: %v", event.eventType, err)
+					break
+				}
+			}
+
+			now := time.Now()
+			deliveries = append(deliveries, &domain.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				Event:         event.eventType,
+				Payload:       payload,
+				Status:        domain.DeliveryPending,
+				NextAttemptAt: &now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	if err := s.repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		log.Printf("Webhook deliveries dropped (%d): %v", len(deliveries), err)
+	}
+}
+
+// enqueueClick queues a click event without blocking
+func (s *WebhookService) enqueueClick(event publishedEvent) {
+	s.clicksMu.RLock()
+	defer s.clicksMu.RUnlock()
+	if s.clicksClosed {
+		s.droppedClicks.Add(1)
+		return
+	}
+	select {
+	case s.clicks <- event:
+	default:
+		s.droppedClicks.Add(1)
+	}
+}
+
+// Close stops queueing click events and waits until the queued ones are stored or ctx is done
+func (s *WebhookService) Close(ctx context.Context) error {
+	if s.clicks == nil {
+		return nil
+	}
+	s.clicksMu.Lock()
+	if !s.clicksClosed {
+		s.clicksClosed = true
+		close(s.clicks)
+	}
+	s.clicksMu.Unlock()
+
+	select {
+	case <-s.clicksDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookService) runClicks() {
+	defer close(s.clicksDone)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]publishedEvent, 0, webhookClickBatch)
+	flush := func() {
+		if len(batch) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			s.createDeliveries(ctx, batch)
+			cancel()
+			batch = batch[:0]
+		}
+	}
+	var reportedDrops int64
+	for {
+		select {
+		case event, ok := <-s.clicks:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= webhookClickBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+
+			if dropped := s.droppedClicks.Load(); dropped > reportedDrops {
+				log.Printf("Webhook click queue full: dropped %d events", dropped-reportedDrops)
+				reportedDrops = dropped
+			}
+		}
+	}
+}
+
+// DeliverPending sends every due delivery and returns how many were attempted
+func (s *WebhookService) DeliverPending(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		deliveries, err := s.repo.ClaimDueDeliveries(ctx, time.Now(), webhookClaimLease, webhookClaimBatch)
+		if err != nil || len(deliveries) == 0 {
+			return attempted, err
+		}
+
+		webhooks := make(map[int64]*domain.Webhook)
+		for _, d := range deliveries {
+			if _, ok := webhooks[d.WebhookID]; !ok {
+				if webhooks[d.WebhookID], err = s.repo.GetWebhook(ctx, d.WebhookID); err != nil {
+					return attempted, err
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, webhookWorkers)
+		for i := range deliveries {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(d *domain.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.attempt(ctx, webhooks[d.WebhookID], d)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+
+		attempted += len(deliveries)
+		if err := ctx.Err(); err != nil {
+			return attempted, err
+		}
+	}
+}
+
+// PruneDeliveries deletes finished deliveries older than the retention period and
+// returns how many were removed. Pending deliveries are never pruned.
+func (s *WebhookService) PruneDeliveries(ctx context.Context) (int64, error) {
+	if s.retentionDays <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteFinishedDeliveries(ctx, time.Now().AddDate(0, 0, -s.retentionDays))
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry on failure
+func (s *WebhookService) attempt(ctx context.Context, webhook *domain.Webhook, d *domain.WebhookDelivery) {
+	now := time.Now()
+	d.UpdatedAt = now
+	d.ResponseCode = 0
+
+	var err error
+	if webhook == nil || !webhook.Active {
+		// Disabled while queued: give up without sending
+		d.Status = domain.DeliveryFailed
+		d.NextAttemptAt = nil
+		d.LastError = "webhook disabled"
+		s.saveDelivery(ctx, d)
+		return
+	}
+
+	d.Attempts++
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	headers := map[string]string{
+		"Content-Type":        "application/json",
+		"X-Webhook-Event":     d.Event,
+		"X-Webhook-Delivery":  strconv.FormatInt(d.ID, 10),
+		"X-Webhook-Timestamp": timestamp,
+		"X-Webhook-Signature": "sha256=" + signWebhookPayload(webhook.Secret, timestamp, d.Payload),
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, webhookSendTimeout)
+	d.ResponseCode, err = s.sender.Send(sendCtx, webhook.URL, headers, d.Payload)
+	cancel()
+	if err == nil && (d.ResponseCode < 200 || d.ResponseCode > 299) {
+		err = fmt.Errorf("unexpected status %d", d.ResponseCode)
+	}
+
+	switch {
+	case err == nil:
+		d.Status = domain.DeliverySucceeded
+		d.NextAttemptAt = nil
+		d.LastError = ""
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = domain.DeliveryFailed
+		d.NextAttemptAt = nil
+		d.LastError = err.Error()
+	default:
+		next := now.Add(webhookBackoff(d.Attempts))
+		d.Status = domain.DeliveryPending
+		d.NextAttemptAt = &next
+		d.LastError = err.Error()
+	}
+
+	s.saveDelivery(ctx, d)
+}
+
+// saveDelivery records an attempt even if ctx was cancelled mid-send, so it isn't lost
+func (s *WebhookService) saveDelivery(ctx context.Context, d *domain.WebhookDelivery) {
+	if err := s.repo.UpdateWebhookDelivery(context.WithoutCancel(ctx), d); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+func (s *WebhookService) activeWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	s.mu.Lock()
+	if s.active != nil && time.Since(s.loadedAt) < webhookCacheTTL {
+		active := s.active
+		s.mu.Unlock()
+		return active, nil
+	}
+	generation := s.generation
+	s.mu.Unlock()
+
+	// Loaded without holding the lock, so a slow query doesn't stall every publisher
+	webhooks, err := s.repo.ListWebhooks(ctx, map[string]interface{}{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []domain.Webhook{} // Cache "none" too
+	}
+
+	s.mu.Lock()
+	if s.generation == generation {
+		s.active, s.loadedAt = webhooks, time.Now()
+	}
+	s.mu.Unlock()
+	return webhooks, nil
+}
+
+// invalidate drops the cache after local changes. Other instances pick changes up within webhookCacheTTL.
+func (s *WebhookService) invalidate() {
+	s.mu.Lock()
+	s.active = nil
+	s.generation++
+	s.mu.Unlock()
+}
+
+// signWebhookPayload is hex HMAC-SHA256 of "<timestamp>.<body>"; receivers recompute it and
+// reject stale timestamps to prevent replays
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the wait after the given failed attempt: 30s, 1m, 2m, ... capped at an hour
+func webhookBackoff(attempt int) time.Duration {
+	wait := webhookRetryBase
+	for i := 1; i < attempt && wait < webhookRetryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, webhookRetryMax)
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook URL must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+// normalizeWebhookEvents defaults to all events and rejects unknown types
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return []string{"*"}, nil
+	}
+	for _, e := range events {
+		if e != "*" && !slices.Contains(domain.EventTypes, e) {
+			return nil, errors.New("unknown webhook event: " + e)
+		}
+	}
+	return events, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
+	"github.com/wadjakorntonsri/go-url-shortener/pkg/ports"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	got := signWebhookPayload("secret", "1700000000", []byte(`{"id":"1"}`))
+	want := "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempt); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// webhookRepo serves fixed webhooks and records queued deliveries
+type webhookRepo struct {
+	ports.LinkRepository
+	webhooks []domain.Webhook
+
+	mu      sync.Mutex
+	inserts [][]*domain.WebhookDelivery
+	lists   int
+}
+
+func (r *webhookRepo) ListWebhooks(ctx context.Context, filters map[string]interface{}) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lists++
+	return r.webhooks, nil
+}
+
+func (r *webhookRepo) CreateWebhookDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inserts = append(r.inserts, deliveries)
+	return nil
+}
+
+func TestPublishClickEvents(t *testing.T) {
+	repo := &webhookRepo{webhooks: []domain.Webhook{
+		{ID: 1, Events: []string{"*"}, Active: true},
+		{ID: 2, Events: []string{domain.EventLinkClicked}, Active: true},
+	}}
+	svc := NewWebhookService(repo, nil, WithClickQueue(100, time.Hour))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		svc.Publish(ctx, domain.EventLinkClicked, domain.VisitEvent{LinkID: 7})
+	}
+	svc.Publish(ctx, domain.EventLinkCreated, &domain.Link{ID: 7})
+
+	// Other events are stored right away, here for the "*" webhook
+	repo.mu.Lock()
+	if len(repo.inserts) != 1 || len(repo.inserts[0]) != 1 || repo.inserts[0][0].WebhookID != 1 {
+		t.Errorf("inserts before flush = %v, want link.created for webhook 1", repo.inserts)
+	}
+	repo.mu.Unlock()
+
+	if err := svc.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.inserts) != 2 {
+		t.Fatalf("inserts = %d, want the queued clicks in one batch", len(repo.inserts))
+	}
+	for _, d := range repo.inserts[1] {
+		if d.WebhookID != 2 || d.Event != domain.EventLinkClicked {
+			t.Errorf("click delivery %+v, want only the explicit link.clicked subscriber", d)
+		}
+	}
+	if len(repo.inserts[1]) != 3 {
+		t.Errorf("click deliveries = %d, want 3", len(repo.inserts[1]))
+	}
+	if repo.lists != 1 {
+		t.Errorf("webhooks listed %d times, want the cached list reused", repo.lists)
+	}
+
+	// Closed queues drop instead of blocking
+	svc.Publish(ctx, domain.EventLinkClicked, domain.VisitEvent{LinkID: 7})
+	if svc.droppedClicks.Load() != 1 {
+		t.Errorf("dropped = %d, want 1", svc.droppedClicks.Load())
+	}
+}
+
+// deliveryRepo hands out its deliveries on the first claim and records updates
+type deliveryRepo struct {
+	ports.LinkRepository
+	webhooks map[int64]*domain.Webhook
+	due      []domain.WebhookDelivery
+	mu       sync.Mutex
+	updated  map[int64]domain.WebhookDelivery
+}
+
+func (r *deliveryRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	due := r.due
+	r.due = nil
+	return due, nil
+}
+
+func (r *deliveryRepo) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error) {
+	return r.webhooks[id], nil
+}
+
+func (r *deliveryRepo) UpdateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated[d.ID] = *d
+	return nil
+}
+
+// fakeSender answers with a fixed status per URL, or fails for unknown URLs
+type fakeSender struct {
+	mu     sync.Mutex
+	status map[string]int
+	sent   []string
+}
+
+func (s *fakeSender) Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, headers["X-Webhook-Delivery"])
+	if code, ok := s.status[url]; ok {
+		return code, nil
+	}
+	return 0, errors.New("connection refused")
+}
+
+func TestDeliverPendingRetries(t *testing.T) {
+	repo := &deliveryRepo{
+		webhooks: map[int64]*domain.Webhook{
+			1: {ID: 1, URL: "https://ok.example/", Secret: "s", Active: true},
+			2: {ID: 2, URL: "https://down.example/", Secret: "s", Active: true},
+			3: {ID: 3, URL: "https://error.example/", Secret: "s", Active: true},
+			4: {ID: 4, URL: "https://off.example/", Secret: "s"},
+		},
+		due: []domain.WebhookDelivery{
+			{ID: 10, WebhookID: 1, Status: domain.DeliveryPending},
+			{ID: 11, WebhookID: 2, Status: domain.DeliveryPending, Attempts: 2},
+			{ID: 12, WebhookID: 3, Status: domain.DeliveryPending, Attempts: webhookMaxAttempts - 1},
+			{ID: 13, WebhookID: 4, Status: domain.DeliveryPending},
+		},
+		updated: make(map[int64]domain.WebhookDelivery),
+	}
+	sender := &fakeSender{status: map[string]int{"https://ok.example/": 204, "https://error.example/": 500}}
+	svc := NewWebhookService(repo, sender)
+
+	start := time.Now()
+	attempted, err := svc.DeliverPending(context.Background())
+	if err != nil || attempted != 4 {
+		t.Fatalf("DeliverPending = %d, %v; want 4 attempted", attempted, err)
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("sent %v, want nothing sent to the inactive webhook", sender.sent)
+	}
+
+	if d := repo.updated[10]; d.Status != domain.DeliverySucceeded || d.Attempts != 1 || d.ResponseCode != 204 || d.NextAttemptAt != nil {
+		t.Errorf("succeeded delivery = %+v", d)
+	}
+	d := repo.updated[11]
+	if d.Status != domain.DeliveryPending || d.Attempts != 3 || d.LastError != "connection refused" || d.NextAttemptAt == nil {
+		t.Fatalf("retried delivery = %+v", d)
+	}
+	if wait := d.NextAttemptAt.Sub(start); wait < webhookBackoff(3) || wait > webhookBackoff(3)+time.Minute {
+		t.Errorf("retry in %v, want about %v", wait, webhookBackoff(3))
+	}
+	if d := repo.updated[12]; d.Status != domain.DeliveryFailed || d.Attempts != webhookMaxAttempts || d.ResponseCode != 500 || d.NextAttemptAt != nil {
+		t.Errorf("exhausted delivery = %+v, want failed after the last attempt", d)
+	}
+	if d := repo.updated[13]; d.Status != domain.DeliveryFailed || d.Attempts != 0 || d.LastError != "webhook disabled" {
+		t.Errorf("disabled delivery = %+v", d)
+	}
+}
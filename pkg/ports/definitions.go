@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/wadjakorntonsri/go-url-shortener/pkg/core/domain"
 )
@@ -19,33 +20,88 @@ type LinkRepository interface {
 
 	// Stats
 	RecordVisit(ctx context.Context, visit *domain.Visit) error
+	RecordVisits(ctx context.Context, visits []*domain.Visit) error // Batch insert, one transaction
+	HasRecentVisit(ctx context.Context, linkID int64, ipHash, userAgent string, since time.Time) (bool, error)
 	GetLinkStats(ctx context.Context, linkID int64, filters map[string]interface{}) (*domain.LinkStats, error)
+	GetClickSeries(ctx context.Context, linkID int64, filters map[string]interface{}) ([]domain.TimeBucket, error)
 	GetDashboardStats(ctx context.Context, limit int, filters map[string]interface{}) ([]domain.Link, int64, error)
+	GetGeoBreakdown(ctx context.Context, limit int, filters map[string]interface{}) (*domain.GeoBreakdown, error)
+	ListStatsLinks(ctx context.Context, filters map[string]interface{}) ([]domain.Link, error)
+	CompactVisits(ctx context.Context, until time.Time, maxDays int) (int, error) // Roll up whole days of raw visits
+	CompactedUntil(ctx context.Context) (time.Time, error)                        // Visits before this are rolled up
+	OldestVisitTime(ctx context.Context) (time.Time, error)                       // Zero if there are no visits
+	ForEachVisit(ctx context.Context, filters map[string]interface{}, fn func(*domain.Visit) error) error
+	ListVisits(ctx context.Context, limit int, filters map[string]interface{}) ([]domain.Visit, error) // Raw visits in ID order
+	DeleteVisits(ctx context.Context, from, to time.Time) (int64, error)                               // Raw visits in [from, to)
+	PrunedUntil(ctx context.Context) (time.Time, error)                                                // Raw visits before this may be deleted
+	RecordConversion(ctx context.Context, conversion *domain.Conversion) (bool, error)                 // False if already recorded
 
 	// Collections
 	CreateCollection(ctx context.Context, collection *domain.Collection) error
 	GetCollection(ctx context.Context, id int64) (*domain.Collection, error)
 	GetCollectionBySlug(ctx context.Context, slug string) (*domain.Collection, error)
 	UpdateCollection(ctx context.Context, collection *domain.Collection) error
-	DeleteCollection(ctx context.Context, id int64) error
+	TouchCollection(ctx context.Context, id int64, at time.Time) error // Bumps updated_at
+	DeleteCollection(ctx context.Context, id int64) error              // Also deletes its items and page views
 	ListCollections(ctx context.Context, limit, offset int, filters map[string]interface{}) ([]domain.Collection, error)
-	AddLinkToCollection(ctx context.Context, collectionID, linkID int64) error
+	CreateCollectionItem(ctx context.Context, item *domain.CollectionItem, position int) error // 1-based; 0 appends
 	RemoveLinkFromCollection(ctx context.Context, collectionID, linkID int64) error
-	UpdateLinkOrder(ctx context.Context, collectionID, linkID int64, newOrder int) error
-	GetCollectionLinks(ctx context.Context, collectionID int64) ([]domain.Link, error)
+	ReorderCollectionLinks(ctx context.Context, collectionID int64, linkIDs []int64) error        // linkIDs must match the visible links
+	ListCollectionItems(ctx context.Context, collectionID int64) ([]domain.CollectionItem, error) // Visible items in page order
+	GetCollectionItem(ctx context.Context, collectionID, itemID int64) (*domain.CollectionItem, error)
+	UpdateCollectionItem(ctx context.Context, item *domain.CollectionItem) error // Content and style only
+	DeleteCollectionItem(ctx context.Context, collectionID, itemID int64) error
+	ReorderCollectionItems(ctx context.Context, collectionID int64, itemIDs []int64) error // itemIDs must match the visible items
+	RecordCollectionView(ctx context.Context, view *domain.CollectionView) error
+	GetCollectionStats(ctx context.Context, collectionID int64, from, to time.Time) (*domain.CollectionStats, error) // Zero from/to are open bounds
+
+	// Webhooks
+	CreateWebhook(ctx context.Context, webhook *domain.Webhook) error
+	GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context, filters map[string]interface{}) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error
+	DeleteWebhook(ctx context.Context, id int64) error // Also deletes its deliveries
+	CreateWebhookDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int, filters map[string]interface{}) ([]domain.WebhookDelivery, error)
+	CountWebhookDeliveries(ctx context.Context, webhookID int64, filters map[string]interface{}) (int64, error)
+	DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int64, error)
+
+	// Alerts
+	CreateAlertRule(ctx context.Context, rule *domain.AlertRule) error
+	GetAlertRule(ctx context.Context, id int64) (*domain.AlertRule, error)
+	ListAlertRules(ctx context.Context, filters map[string]interface{}) ([]domain.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *domain.AlertRule) error // Also resets its firing state
+	DeleteAlertRule(ctx context.Context, id int64) error
+	ListFiringAlerts(ctx context.Context, ruleID int64) (map[int64]time.Time, error) // link ID -> triggered at
+	SetAlertFiring(ctx context.Context, ruleID, linkID int64, at time.Time) error
+	ClearAlertFiring(ctx context.Context, ruleID, linkID int64) error
+	LinkClickTotals(ctx context.Context, linkID int64) (map[int64]int64, error)                       // Lifetime clicks; linkID 0 for all links
+	CountClicksByLink(ctx context.Context, linkID int64, from, to time.Time) (map[int64]int64, error) // Clicks in [from, to); a zero bound is open
 } // LinkRepository ends here
 
 // CollectionService defines business logic for collections
 type CollectionService interface {
-	CreateCollection(ctx context.Context, title, slug, description string) (*domain.Collection, error)
+	CreateCollection(ctx context.Context, input *domain.Collection) (*domain.Collection, error)
 	GetCollection(ctx context.Context, id int64) (*domain.Collection, error)
 	GetCollectionBySlug(ctx context.Context, slug string) (*domain.Collection, error)
-	UpdateCollection(ctx context.Context, id int64, title, slug, description string) (*domain.Collection, error)
+	UpdateCollection(ctx context.Context, id int64, input *domain.Collection) (*domain.Collection, error) // Replaces the editable fields
 	DeleteCollection(ctx context.Context, id int64) error
-	ListCollections(ctx context.Context, page, limit int, search string) ([]domain.Collection, int64, error)
-	AddLink(ctx context.Context, collectionID, linkID int64) error
+	ListCollections(ctx context.Context, page, limit int, search, visibility string, template *bool) ([]domain.Collection, int64, error)
+	CloneCollection(ctx context.Context, id int64, opts domain.CopyOptions) (*domain.Collection, error) // New draft with the same items
+	ExportCollection(ctx context.Context, id int64) (*domain.CollectionExport, error)
+	ImportCollection(ctx context.Context, export *domain.CollectionExport, opts domain.CopyOptions) (*domain.Collection, error)
+	VerifyPassword(collection *domain.Collection, password, clientIP string) (bool, time.Duration) // Unlocks password-protected pages; a wait while throttled
+	RecordView(ctx context.Context, collection *domain.Collection, input domain.VisitInput) error
+	GetStats(ctx context.Context, id int64, from, to time.Time) (*domain.CollectionStats, error)
 	RemoveLink(ctx context.Context, collectionID, linkID int64) error
 	ReorderLinks(ctx context.Context, collectionID int64, linkIDs []int64) error
+	AddItem(ctx context.Context, collectionID int64, item *domain.CollectionItem, position int) (*domain.CollectionItem, error)
+	GetItem(ctx context.Context, collectionID, itemID int64) (*domain.CollectionItem, error)
+	UpdateItem(ctx context.Context, collectionID, itemID int64, input *domain.CollectionItem) (*domain.CollectionItem, error)
+	RemoveItem(ctx context.Context, collectionID, itemID int64) error
+	ReorderItems(ctx context.Context, collectionID int64, itemIDs []int64) error
 }
 
 // LinkService defines the business logic operations
@@ -57,8 +113,65 @@ type LinkService interface {
 	ListLinks(ctx context.Context, page, limit int, search string, tag string) ([]domain.Link, int64, error)
 
 	// Stats
-	RecordVisit(ctx context.Context, shortCode, referer, userAgent, ip string) error
+	RecordVisit(ctx context.Context, shortCode string, input domain.VisitInput) (string, error)      // Returns the click ID, if issued
+	RecordLinkVisit(ctx context.Context, link *domain.Link, input domain.VisitInput) (string, error) // When the link is already resolved
+	DestinationURL(link *domain.Link, clickID string) string                                         // Redirect target carrying the click ID
+	RecordConversion(ctx context.Context, input domain.ConversionInput) (*domain.Conversion, bool, error)
+	VisitQueueStats() *domain.VisitQueueStats // Nil when visits are written synchronously
 	GetLinkStats(ctx context.Context, id int64, filters map[string]interface{}) (*domain.LinkStats, error)
 	GetDashboard(ctx context.Context, limit int, search, tag, domainFilter string) ([]domain.Link, int64, error)
+	GetDashboardGeo(ctx context.Context, search, tag, domainFilter string) (*domain.GeoBreakdown, error)
+	GetClickAggregates(ctx context.Context, groupBy string, limit int, filters map[string]interface{}) ([]domain.ClickAggregate, int64, error)
 	GetLinkByShortCode(ctx context.Context, code string) (*domain.Link, error)
+	ListVisits(ctx context.Context, filters map[string]interface{}, cursor int64, limit int) ([]domain.Visit, int64, error)
+	ExportVisits(ctx context.Context, filters map[string]interface{}, cursor int64, fn func(*domain.Visit) error) error
+	VisitsPrunedUntil(ctx context.Context, filters map[string]interface{}) (time.Time, error) // Non-zero if pruning cut into the range
+	SubscribeVisits(ctx context.Context, lastEventID, linkID int64, tag string) ([]domain.VisitEvent, <-chan domain.VisitEvent, error)
+}
+
+// WebhookService manages webhook endpoints and their delivery log
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, url string, events []string, secret string) (*domain.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, url string, events []string, active *bool) (*domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, webhookID int64, status string, page, limit int) ([]domain.WebhookDelivery, int64, error)
+}
+
+// AlertService manages alert rules
+type AlertService interface {
+	CreateAlertRule(ctx context.Context, rule *domain.AlertRule) (*domain.AlertRule, error)
+	GetAlertRule(ctx context.Context, id int64) (*domain.AlertRule, error)
+	ListAlertRules(ctx context.Context, linkID int64) ([]domain.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, id int64, rule *domain.AlertRule) (*domain.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id int64) error
+}
+
+// EventPublisher receives domain events emitted by services. Publishing never fails the
+// operation that emitted the event.
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType string, data interface{})
+}
+
+// WebhookSender POSTs a payload to a webhook URL and returns the response status code
+type WebhookSender interface {
+	Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error)
+}
+
+// Mailer sends plain-text email notifications
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// GeoResolver resolves a visitor IP address to a location
+type GeoResolver interface {
+	Lookup(ip string) (*domain.Location, error)
+}
+
+// VisitArchive stores raw visits removed by the retention policy
+type VisitArchive interface {
+	// WriteDay archives one UTC day of visits, produced by calling fill with a write func.
+	// The archive must be durable when it returns nil.
+	WriteDay(ctx context.Context, day time.Time, fill func(write func(*domain.Visit) error) error) error
 }